@@ -0,0 +1,309 @@
+package api
+
+import (
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/debug"
+	"github.com/sirion/fanmi/app/status"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// Server provides the HTTP/JSON API and the web dashboard
+type Server struct {
+	config *configuration.Configuration
+	token  string
+
+	mutex       sync.Mutex
+	devices     map[string]status.Status
+	subscribers map[chan status.Status]bool
+}
+
+// StatusResponse is returned by GET /api/status
+type StatusResponse struct {
+	Active     bool            `json:"active"`
+	PowerMode  string          `json:"powerMode"`
+	PowerModes []string        `json:"powerModes"`
+	Curve      string          `json:"curve"`
+	Curves     []string        `json:"curves"`
+	Devices    []status.Status `json:"devices"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewServer(config *configuration.Configuration) (*Server, error) {
+	token, err := readOrCreateToken(config.API.TokenFile, config.API.Group)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		config:      config,
+		token:       token,
+		devices:     make(map[string]status.Status),
+		subscribers: make(map[chan status.Status]bool),
+	}, nil
+}
+
+// ListenAndServe serves the API at the given address, it only returns on error
+func (s *Server) ListenAndServe(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		// Never listen on all interfaces by accident
+		host = "localhost"
+	}
+
+	return http.ListenAndServe(net.JoinHostPort(host, port), s.Handler())
+}
+
+// Handler returns the handler for all API endpoints and the dashboard
+func (s *Server) Handler() http.Handler {
+	web, _ := fs.Sub(webFiles, "web")
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServerFS(web))
+	mux.HandleFunc("GET /api/status", s.auth(s.getStatus))
+	mux.HandleFunc("GET /api/events", s.auth(s.getEvents))
+	mux.HandleFunc("GET /api/curves", s.auth(s.getCurves))
+	mux.HandleFunc("GET /api/curves/{name}", s.auth(s.getCurve))
+	mux.HandleFunc("PUT /api/curves/{name}", s.auth(s.putCurve))
+	mux.HandleFunc("DELETE /api/curves/{name}", s.auth(s.deleteCurve))
+	mux.HandleFunc("PUT /api/curve", s.auth(s.putCurrentCurve))
+	mux.HandleFunc("PUT /api/powermode", s.auth(s.putPowerMode))
+	mux.HandleFunc("PUT /api/active", s.auth(s.putActive))
+	return mux
+}
+
+// Update implements status.Listener
+func (s *Server) Update(st status.Status) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.devices[st.Device] = st
+	for subscriber := range s.subscribers {
+		select {
+		case subscriber <- st:
+		default:
+			// Slow client, it will get the next update
+		}
+	}
+}
+
+// auth only allows requests with the token as bearer token or as "token" query parameter (for EventSource)
+func (s *Server) auth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid or missing token"))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.status())
+}
+
+func (s *Server) status() StatusResponse {
+	s.mutex.Lock()
+	devices := make([]status.Status, 0, len(s.devices))
+	for _, device := range s.devices {
+		devices = append(devices, device)
+	}
+	s.mutex.Unlock()
+
+	sort.Slice(devices, func(a, b int) bool {
+		return devices[a].Device < devices[b].Device
+	})
+
+	s.config.RLock()
+	defer s.config.RUnlock()
+
+	return StatusResponse{
+		Active:     s.config.Active,
+		PowerMode:  s.config.PowerMode,
+		PowerModes: configuration.PowerModes,
+		Curve:      s.config.CurrentCurve,
+		Curves:     s.config.CurveNames,
+		Devices:    devices,
+	}
+}
+
+// getEvents sends every status update as server-sent event
+func (s *Server) getEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	updates := make(chan status.Status, 8)
+	s.mutex.Lock()
+	s.subscribers[updates] = true
+	s.mutex.Unlock()
+	defer (func() {
+		s.mutex.Lock()
+		delete(s.subscribers, updates)
+		s.mutex.Unlock()
+	})()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case update := <-updates:
+			data, err := json.Marshal(update)
+			if err != nil {
+				return
+			}
+			_, err = fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// The curves are copied, so a slow client does not hold the lock the fan control waits for
+func (s *Server) getCurves(w http.ResponseWriter, r *http.Request) {
+	s.config.RLock()
+	curves := make(map[string]configuration.Values, len(s.config.Curves))
+	for name, curve := range s.config.Curves {
+		curves[name] = slices.Clone(curve)
+	}
+	s.config.RUnlock()
+
+	writeJSON(w, http.StatusOK, curves)
+}
+
+func (s *Server) getCurve(w http.ResponseWriter, r *http.Request) {
+	s.config.RLock()
+	curve, ok := s.config.Curves[r.PathValue("name")]
+	curve = slices.Clone(curve)
+	s.config.RUnlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("curve '%s' not found", r.PathValue("name")))
+		return
+	}
+	writeJSON(w, http.StatusOK, curve)
+}
+
+func (s *Server) putCurve(w http.ResponseWriter, r *http.Request) {
+	curve := configuration.Values{}
+	err := json.NewDecoder(r.Body).Decode(&curve)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = s.config.PutCurve(r.PathValue("name"), curve)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, curve)
+}
+
+func (s *Server) deleteCurve(w http.ResponseWriter, r *http.Request) {
+	err := s.config.DeleteCurve(r.PathValue("name"))
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) putCurrentCurve(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		Curve string `json:"curve"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.config.RLock()
+	_, ok := s.config.Curves[body.Curve]
+	s.config.RUnlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("curve '%s' not found", body.Curve))
+		return
+	}
+
+	s.config.SetCurve(body.Curve)
+	writeJSON(w, http.StatusOK, s.status())
+}
+
+func (s *Server) putPowerMode(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		PowerMode string `json:"powerMode"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !slices.Contains(configuration.PowerModes, body.PowerMode) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown power mode '%s'", body.PowerMode))
+		return
+	}
+
+	s.config.SetPowerMode(body.PowerMode)
+	writeJSON(w, http.StatusOK, s.status())
+}
+
+func (s *Server) putActive(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		Active bool `json:"active"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.config.SetActive(body.Active)
+	debug.Log("Active set to %t via API\n", body.Active)
+	writeJSON(w, http.StatusOK, s.status())
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, err error) {
+	writeJSON(w, statusCode, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/status"
+)
+
+func newTestServer() *Server {
+	config := &configuration.Configuration{
+		Active:       true,
+		CurrentCurve: "default",
+		CurveNames:   []string{"default"},
+		Curves: map[string]configuration.Values{
+			"default": {{Temp: 40, Speed: 0}, {Temp: 90, Speed: 1}},
+		},
+	}
+	config.Curve = config.Curves["default"]
+
+	return &Server{
+		config:      config,
+		token:       "secret",
+		devices:     make(map[string]status.Status),
+		subscribers: make(map[chan status.Status]bool),
+	}
+}
+
+func TestServer_Handler(t *testing.T) {
+	server := newTestServer()
+	server.Update(status.Status{Device: "card0", Temperature: 50, State: status.StateActive})
+	handler := server.Handler()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"No token", "GET", "/api/status", "", "", http.StatusUnauthorized, "invalid or missing token"},
+		{"Wrong token", "GET", "/api/status", "wrong", "", http.StatusUnauthorized, "invalid or missing token"},
+		{"Dashboard without token", "GET", "/", "", "", http.StatusOK, "<title>FanMi</title>"},
+		{"Status", "GET", "/api/status", "secret", "", http.StatusOK, `"device":"card0"`},
+		{"Put curve", "PUT", "/api/curves/quiet", "secret", `[{"Temp":80,"Speed":1},{"Temp":50,"Speed":0}]`, http.StatusOK, `[{"Temp":50,"Speed":0},{"Temp":80,"Speed":1}]`},
+		{"Get curve", "GET", "/api/curves/quiet", "secret", "", http.StatusOK, `{"Temp":50,"Speed":0}`},
+		{"Empty curve", "PUT", "/api/curves/empty", "secret", `[]`, http.StatusBadRequest, "curve has no entries"},
+		{"Invalid curve", "PUT", "/api/curves/loud", "secret", `[{"Temp":80,"Speed":1.5}]`, http.StatusBadRequest, "curves.loud[0].Speed: 1.5 > 1.0"},
+		{"Select curve", "PUT", "/api/curve", "secret", `{"curve":"quiet"}`, http.StatusOK, `"curve":"quiet"`},
+		{"Select unknown curve", "PUT", "/api/curve", "secret", `{"curve":"loud"}`, http.StatusNotFound, "not found"},
+		{"Delete current curve", "DELETE", "/api/curves/quiet", "secret", "", http.StatusConflict, "currently used"},
+		{"Delete curve", "DELETE", "/api/curves/default", "secret", "", http.StatusNoContent, ""},
+		{"Unknown power mode", "PUT", "/api/powermode", "secret", `{"powerMode":"turbo"}`, http.StatusBadRequest, "unknown power mode"},
+		{"Power mode", "PUT", "/api/powermode", "secret", `{"powerMode":"low"}`, http.StatusOK, `"powerMode":"low"`},
+		{"Inactive", "PUT", "/api/active?token=secret", "", `{"active":false}`, http.StatusOK, `"active":false`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				request.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %d, want %d (%s)", tt.method, tt.path, recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s body = %s, want %s", tt.method, tt.path, recorder.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	if _, ok := server.config.Curves["default"]; ok {
+		t.Errorf("curve 'default' was not deleted")
+	}
+	if mode, pending := server.config.PendingPowerMode("card0"); mode != "low" || !pending {
+		t.Errorf("power mode = %s (pending: %t), want low", mode, pending)
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// readOrCreateToken returns the token stored in tokenFile. If the file does not exist, a new random token is
+// written. The file is owned by root and only readable by the given group.
+func readOrCreateToken(tokenFile, group string) (string, error) {
+	gid := 0
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return "", fmt.Errorf("error looking up group %s: %s", group, err.Error())
+		}
+		gid, err = strconv.Atoi(g.Gid)
+		if err != nil {
+			return "", fmt.Errorf("error looking up group %s: %s", group, err.Error())
+		}
+	}
+
+	data, err := os.ReadFile(tokenFile)
+	if err == nil {
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", fmt.Errorf("token file %s is empty", tokenFile)
+		}
+		return token, restrictAccess(tokenFile, gid)
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("error reading token file %s: %s", tokenFile, err.Error())
+	}
+
+	random := make([]byte, 32)
+	_, err = rand.Read(random)
+	if err != nil {
+		return "", fmt.Errorf("error creating token: %s", err.Error())
+	}
+	token := hex.EncodeToString(random)
+
+	tokenDir := path.Dir(tokenFile)
+	if _, err := os.Stat(tokenDir); os.IsNotExist(err) {
+		err = os.MkdirAll(tokenDir, 0750)
+		if err != nil {
+			return "", fmt.Errorf("error creating token directory %s: %s", tokenDir, err.Error())
+		}
+		err = restrictAccess(tokenDir, gid)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = os.WriteFile(tokenFile, []byte(token+"\n"), 0640)
+	if err != nil {
+		return "", fmt.Errorf("error writing token file %s: %s", tokenFile, err.Error())
+	}
+
+	return token, restrictAccess(tokenFile, gid)
+}
+
+// restrictAccess makes the file owned by root and the given group, only the group may read it
+func restrictAccess(filePath string, gid int) error {
+	err := os.Chown(filePath, 0, gid)
+	if err != nil {
+		return fmt.Errorf("error changing owner of %s: %s", filePath, err.Error())
+	}
+
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %s", filePath, err.Error())
+	}
+	mode := os.FileMode(0640)
+	if stat.IsDir() {
+		mode = 0750
+	}
+	err = os.Chmod(filePath, mode)
+	if err != nil {
+		return fmt.Errorf("error changing permissions of %s: %s", filePath, err.Error())
+	}
+	return nil
+}
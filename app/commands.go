@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/importer"
+	"github.com/sirion/fanmi/app/telemetry"
+)
+
+// commands are invoked with the first CLI argument as name and return the exit code
+var commands = map[string]func(args []string) int{
+	"config":   configCommand,
+	"import":   importCommand,
+	"stats":    statsCommand,
+	"validate": validateCommand,
+}
+
+// statsCommand summarizes telemetry files
+func statsCommand(args []string) int {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	bandWidth := flags.Int("band", 10, "Width of the temperature bands in °C")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: fanmi stats [-band degrees] telemetry-file...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 || *bandWidth <= 0 {
+		flags.Usage()
+		return configuration.ExitCodeReadTelemetry
+	}
+
+	records := make([]telemetry.Record, 0)
+	for _, filePath := range flags.Args() {
+		file, err := os.Open(filePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading telemetry file: %s\n", err.Error())
+			return configuration.ExitCodeReadTelemetry
+		}
+		fileRecords, err := telemetry.ReadRecords(file)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading telemetry file %s: %s\n", filePath, err.Error())
+			return configuration.ExitCodeReadTelemetry
+		}
+		records = append(records, fileRecords...)
+	}
+
+	// Rotated files might be given in any order
+	sort.SliceStable(records, func(a, b int) bool {
+		return records[a].Time.Before(records[b].Time)
+	})
+
+	telemetry.Print(os.Stdout, telemetry.Summarize(records, *bandWidth), *bandWidth)
+	return 0
+}
+
+// validateCommand checks a configuration file and prints all problems
+func validateCommand(args []string) int {
+	defaultConfigPath, _ := configuration.DefaultConfigPath()
+
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := flags.String("config", defaultConfigPath, "Path to the configuration file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: fanmi validate [-config file]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading config file: %s\n", err.Error())
+		return configuration.ExitCodeUserConfigFile
+	}
+
+	config, err := configuration.ParseLayers([]configuration.Layer{{Origin: *configPath, Data: data, Format: configuration.FormatOf(*configPath)}})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing config file: %s\n", err.Error())
+		return configuration.ExitCodeUserParseConfig
+	}
+
+	err = config.Validate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return configuration.ExitCodeInvalidConfig
+	}
+
+	for _, migration := range config.Migrations() {
+		fmt.Fprintf(os.Stderr, "Migrated configuration %s, use \"fanmi config convert\" to update the file\n", migration)
+	}
+	fmt.Printf("%s is valid\n", *configPath)
+	return 0
+}
+
+// configCommand shows or converts configuration files and prints the JSON Schema
+func configCommand(args []string) int {
+	if len(args) > 0 && args[0] == "show" {
+		return configShowCommand(args[1:])
+	} else if len(args) > 0 && args[0] == "convert" {
+		return configConvertCommand(args[1:])
+	} else if len(args) > 0 && args[0] == "schema" {
+		data, _ := json.MarshalIndent(configuration.Schema(), "", "\t")
+		fmt.Println(string(data))
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, "Usage: fanmi config show [-effective] [-config file] [options]")
+	fmt.Fprintln(os.Stderr, "       fanmi config convert [-to json|yaml|toml] input-file [output-file]")
+	fmt.Fprintln(os.Stderr, "       fanmi config schema")
+	return configuration.ExitCodeUserConfigFile
+}
+
+// configShowCommand shows the configuration merged from all layers, environment variables and the options that
+// override it, secrets are masked
+func configShowCommand(args []string) int {
+	defaultConfigPath, _ := configuration.DefaultConfigPath()
+
+	flags := flag.NewFlagSet("config show", flag.ExitOnError)
+	configPath := flags.String("config", defaultConfigPath, "Path to the user configuration file")
+	effective := flags.Bool("effective", false, "Show every value with the file it was set in")
+	configuration.RegisterOverrides(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: fanmi config show [-effective] [-config file] [options]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	overrides, err := configuration.OverrideLayers(configuration.TelemetryConfiguration{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing options: %s\n", err.Error())
+		return configuration.ExitCodeUserParseConfig
+	}
+	config, warnings, err := configuration.Load(*configPath, overrides...)
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "%s, ignoring it\n", warning.Error())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing config file: %s\n", err.Error())
+		return configuration.ExitCodeUserParseConfig
+	}
+
+	if *effective {
+		config.PrintEffective(os.Stdout)
+	} else {
+		fmt.Println(string(config.Show()))
+	}
+	return 0
+}
+
+// configConvertCommand translates a configuration file between JSON, YAML and TOML
+func configConvertCommand(args []string) int {
+	flags := flag.NewFlagSet("config convert", flag.ExitOnError)
+	format := flags.String("to", "", "Output format: json, yaml or toml (default from output file extension)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: fanmi config convert [-to json|yaml|toml] input-file [output-file]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || flags.NArg() > 2 || (flags.NArg() == 1 && *format == "") {
+		flags.Usage()
+		return configuration.ExitCodeUserConfigFile
+	}
+	inputPath := flags.Arg(0)
+	outputPath := flags.Arg(1)
+	if *format == "" {
+		*format = configuration.FormatOf(outputPath)
+	}
+
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading config file: %s\n", err.Error())
+		return configuration.ExitCodeUserConfigFile
+	}
+
+	data, err = configuration.ToJSON(data, configuration.FormatOf(inputPath))
+	if err == nil {
+		var migrations []string
+		data, migrations, err = configuration.Migrate(data)
+		for _, migration := range migrations {
+			fmt.Fprintf(os.Stderr, "Migrated configuration from %s\n", migration)
+		}
+	}
+	if err == nil {
+		// Make sure the result can be read by fanmi
+		_, err = configuration.Parse(data)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing config file: %s\n", err.Error())
+		return configuration.ExitCodeUserParseConfig
+	}
+	if *format == configuration.FormatJSON {
+		buffer := &bytes.Buffer{}
+		err = json.Indent(buffer, data, "", "\t")
+		data = append(buffer.Bytes(), '\n')
+	} else {
+		data, err = configuration.FromJSON(data, *format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error converting config file: %s\n", err.Error())
+		return configuration.ExitCodeUserParseConfig
+	}
+
+	if outputPath == "" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	err = os.WriteFile(outputPath, data, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing config file: %s\n", err.Error())
+		return configuration.ExitCodeWriteFile
+	}
+	return 0
+}
+
+// importCommand translates the configuration of another fan-control tool into a fanmi configuration
+func importCommand(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	from := flags.String("from", "", "Input format: "+strings.Join(importer.Formats, ", ")+" (default detected from file)")
+	format := flags.String("to", "", "Output format: json, yaml or toml (default from output file extension)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: fanmi import [-from format] [-to json|yaml|toml] input-file [output-file]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return configuration.ExitCodeUserConfigFile
+	}
+	inputPath := flags.Arg(0)
+	outputPath := flags.Arg(1)
+	if *format == "" {
+		*format = configuration.FormatOf(outputPath)
+	}
+
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %s\n", err.Error())
+		return configuration.ExitCodeUserConfigFile
+	}
+
+	result, err := importer.Import(inputPath, data, *from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing %s: %s\n", inputPath, err.Error())
+		return configuration.ExitCodeUserParseConfig
+	}
+	for _, setting := range result.Untranslated {
+		fmt.Fprintf(os.Stderr, "Not translated: %s\n", setting)
+	}
+
+	data, err = result.JSON()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing %s: %s\n", inputPath, err.Error())
+		return configuration.ExitCodeInvalidConfig
+	}
+	if *format == configuration.FormatJSON {
+		buffer := &bytes.Buffer{}
+		err = json.Indent(buffer, data, "", "\t")
+		data = append(buffer.Bytes(), '\n')
+	} else {
+		data, err = configuration.FromJSON(data, *format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error converting config file: %s\n", err.Error())
+		return configuration.ExitCodeUserParseConfig
+	}
+
+	if outputPath == "" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	err = os.WriteFile(outputPath, data, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing config file: %s\n", err.Error())
+		return configuration.ExitCodeWriteFile
+	}
+	return 0
+}
@@ -5,72 +5,332 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"path"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirion/fanmi/app/debug"
+	"github.com/sirion/fanmi/app/units"
 )
 
 type Configuration struct {
+	// Schema version of the configuration, older versions are migrated when read
+	Version         int     `json:"version"`
 	CheckIntervalMs uint32  `json:"checkIntervalMs"`
 	MinChange       float32 `json:"minChange"`
 	PowerMode       string  `json:"powerMode"`
-	MaxStepUp       float32 `json:"maxStepUp"`
-	MaxStepDown     float32 `json:"maxStepDown"`
+	// Entry of pp_power_profile_mode by name (e.g. "COMPUTE"), empty to keep the mode of the driver
+	PowerProfileMode string  `json:"powerProfileMode"`
+	MaxStepUp        float32 `json:"maxStepUp"`
+	MaxStepDown      float32 `json:"maxStepDown"`
+	FailsafeTemp     float32 `json:"failsafeTemp"`
+	// Save settings changed in the GUI immediately
+	AutoSave bool `json:"autoSave"`
+	// Hide the main window in the system tray when it is closed
+	MinimizeToTray bool `json:"minimizeToTray"`
 
 	// Curve Mode
 	Curves       map[string]Values `json:"curves"`
 	CurrentCurve string            `json:"curve"`
+	// Settings of single devices by name (e.g. "card1")
+	Devices map[string]DeviceConfiguration `json:"devices"`
+	// Power limit of all devices in W, 0 keeps the limit of the driver
+	PowerCap float32 `json:"powerCap"`
+	// Combinations of curve and power limit by name (e.g. "quiet")
+	Profiles map[string]Profile `json:"profiles"`
+	// Profile applied to all devices at start-up
+	Profile string `json:"profile"`
 
-	PowerModeChanged bool     `json:"-"`
-	Running          bool     `json:"-"`
-	Active           bool     `json:"-"`
-	UI               string   `json:"-"`
-	CurveNames       []string `json:"-"`
-	Curve            Values   `json:"-"`
+	Metrics   MetricsConfiguration   `json:"metrics"`
+	API       APIConfiguration       `json:"api"`
+	MQTT      MQTTConfiguration      `json:"mqtt"`
+	Log       LogConfiguration       `json:"log"`
+	Telemetry TelemetryConfiguration `json:"telemetry"`
+	// Desktop notifications on thermal and fan events
+	Notifications NotificationsConfiguration `json:"notifications"`
+	Console       ConsoleConfiguration       `json:"console"`
+
+	Running    bool     `json:"-"`
+	Active     bool     `json:"-"`
+	UI         string   `json:"-"`
+	CurveNames []string `json:"-"`
+	Curve      Values   `json:"-"`
+	// Speed (0-1) of all devices at start-up instead of the curve, negative to use the curves
+	ManualSpeed float32 `json:"-"`
+	// Time after which the curves are used again, 0 to keep the manual speed
+	ManualDuration time.Duration `json:"-"`
+
+	// Guards curves against concurrent modification
+	sync.RWMutex `json:"-"`
+
+	// Keys in the configuration file that do not exist
+	unknownKeys ValidationErrors
+	// Path of the user configuration file, used for reloading and saving
+	filePath string
+	// Layer that set each value, by lower case JSON path
+	origins map[string]string
+	// Layers from environment variables and CLI options, applied again on reload
+	overrides []Layer
+	// Migrations applied to the layers while parsing
+	migrations []string
+	// Number of changes of PowerMode and the change each device wrote last, see PendingPowerMode
+	powerModeChanges  int
+	appliedPowerModes map[string]int
+	// Devices switched off in the user interface
+	inactiveDevices map[string]bool
+	// End of the pause of devices paused for a while
+	pausedDevices map[string]time.Time
+	// Fixed speeds set by the user instead of the curve
+	deviceSpeeds map[string]fixedSpeed
+}
+
+// fixedSpeed is used instead of the curve of a device until it expires
+type fixedSpeed struct {
+	// Speed (0-1)
+	speed float32
+	// The curve is used again afterwards, never if zero
+	until time.Time
+}
+
+// DeviceConfiguration overrides settings for a single device
+type DeviceConfiguration struct {
+	// Name of the curve used instead of the global curve
+	Curve string `json:"curve,omitempty"`
+	// Power limit in W used instead of the global power limit
+	PowerCap float32 `json:"powerCap,omitempty"`
+	// Entry of pp_power_profile_mode used instead of the global one
+	PowerProfileMode string `json:"powerProfileMode,omitempty"`
+}
+
+// Profile pairs a curve with a power limit
+type Profile struct {
+	// Name of the curve, the global curve if empty
+	Curve string `json:"curve"`
+	// Power limit in W, 0 keeps the limit of the driver
+	PowerCap float32 `json:"powerCap"`
+}
+
+// MetricsConfiguration configures the optional Prometheus exporter
+type MetricsConfiguration struct {
+	// Address to listen on (e.g. "localhost:9654"), the exporter is disabled if empty
+	Listen string `json:"listen"`
+}
+
+// APIConfiguration configures the optional HTTP/JSON API and web dashboard
+type APIConfiguration struct {
+	// Address to listen on (e.g. "localhost:8765"), the API is disabled if empty. Without host only localhost is used.
+	Listen string `json:"listen"`
+	// File containing the access token, it is created if it does not exist
+	TokenFile string `json:"tokenFile"`
+	// Group that is allowed to read the token file
+	Group string `json:"group"`
+}
+
+// MQTTConfiguration configures publishing to and receiving commands from a MQTT broker
+type MQTTConfiguration struct {
+	// Broker URL (e.g. "tcp://localhost:1883"), MQTT is disabled if empty
+	Broker   string `json:"broker"`
+	ClientID string `json:"clientId"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Topic for the state of each device, "{device}" is replaced by the device name
+	StateTopic string `json:"stateTopic"`
+	// Commands are received on the sub-topics "curve", "powermode" and "active"
+	CommandTopic string `json:"commandTopic"`
+	// Publish Home Assistant discovery messages
+	Discovery       bool   `json:"discovery"`
+	DiscoveryPrefix string `json:"discoveryPrefix"`
+}
+
+// LogConfiguration configures the log output
+type LogConfiguration struct {
+	// "debug", "info", "warn" or "error"
+	Level string `json:"level"`
+	// "stderr", "journald" (stderr with priority prefixes) or "file"
+	Sink string `json:"sink"`
+	// Path of the log file for sink "file"
+	File string `json:"file"`
+}
+
+// TelemetryConfiguration configures writing one record per device and check cycle to a file
+type TelemetryConfiguration struct {
+	// Telemetry is disabled if empty
+	File string `json:"file"`
+	// "csv" or "jsonl", derived from the file extension if empty
+	Format string `json:"format"`
+	// Size in MB after which the file is rotated, 0 disables rotation
+	MaxSize int `json:"maxSize"`
+	// Number of rotated files to keep
+	MaxFiles int `json:"maxFiles"`
+}
+
+// NotificationsConfiguration configures desktop notifications (org.freedesktop.Notifications) on the session bus
+type NotificationsConfiguration struct {
+	Enabled bool `json:"enabled"`
+	// Seconds before a rule notifies about the same device again
+	Cooldown int `json:"cooldown"`
+	// Maximal number of notifications per minute, 0 for no limit
+	RateLimit int                `json:"rateLimit"`
+	Rules     []NotificationRule `json:"rules"`
+}
+
+// NotificationRule sends a notification when its event occurs on a device
+type NotificationRule struct {
+	// "temperature", "stall", "failsafe" or "writeError"
+	Event string `json:"event"`
+	// Warning temperature in °C for event "temperature"
+	Temp float32 `json:"temp"`
+	// Name of the device (e.g. "card1"), all devices if empty
+	Device string `json:"device"`
+	// "low", "normal" or "critical"
+	Urgency string `json:"urgency"`
+}
+
+// UnmarshalJSON replaces the whole rule, otherwise rules in the file would keep the values of the default rules
+func (r *NotificationRule) UnmarshalJSON(data []byte) error {
+	type rule NotificationRule
+	value := rule{}
+	err := json.Unmarshal(data, &value)
+	*r = NotificationRule(value)
+	return err
+}
+
+// ConsoleConfiguration configures the console UI
+type ConsoleConfiguration struct {
+	// Action of each key (e.g. "space": "toggle"), an empty action removes the default binding of the key
+	Keys map[string]string `json:"keys"`
+	// Minutes the action "pause" hands the fan to the driver
+	PauseMinutes int `json:"pauseMinutes"`
+	// Minutes after which speeds set with "speed-up" and "speed-down" return to the curve, 0 to keep them
+	ManualMinutes int `json:"manualMinutes"`
+	// Change of the actions "speed-up" and "speed-down" in %
+	SpeedStep float32 `json:"speedStep"`
+	// Change of the actions "power-cap-up" and "power-cap-down" in W
+	PowerCapStep float32 `json:"powerCapStep"`
 }
 
 func ReadConfig() *Configuration {
 	// Read CLI options
 	var ui string
 
-	userConfigDir, err := os.UserConfigDir()
+	defaultConfigPath, err := DefaultConfigPath()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error locating config directory for current user: %s", err.Error())
-		os.Exit(ExitCodeUserConfigDir)
+		// The system-wide configuration is still used
+		fmt.Fprintf(os.Stderr, "Error locating config directory for current user: %s\n", err.Error())
 	}
-	configDir := path.Join(userConfigDir, "fanmi")
-	defaultConfigPath := path.Join(configDir, "config.json")
 	configPath := ""
 
-	flag.StringVar(&ui, "ui", "graphic", `Which UI to use, either "graphic", "console" or "none"`)
+	flag.StringVar(&ui, "ui", "graphic", `Which UI to use, either "graphic", "console", "tui" or "none"`)
 	flag.StringVar(&configPath, "config", defaultConfigPath, `Path to the (optional) configuration file`)
-	flag.BoolVar(&debug.DebugOutput, "v", debug.DebugOutput, `Print debug information to stdout`)
+	flag.BoolVar(&debug.DebugOutput, "v", debug.DebugOutput, `Print debug information (same as log level "debug")`)
+	telemetry := TelemetryConfiguration{}
+	flag.StringVar(&telemetry.File, "log-telemetry", "", `Append one telemetry record per check cycle and device to this file`)
+	flag.StringVar(&telemetry.Format, "telemetry-format", "", `Format of the telemetry file: "csv" or "jsonl" (default from file extension)`)
+	flag.IntVar(&telemetry.MaxSize, "telemetry-max-size", 0, `Size in MB after which the telemetry file is rotated (default from configuration file)`)
+	manualSpeed := flag.String("manual-speed", "", "Fix the fan speed of all cards to this `%` instead of using the curve")
+	manualDuration := flag.Duration("manual-for", 0, "Use the curve again after this `duration` (e.g. \"10m\"), 0 keeps the manual speed")
+	RegisterOverrides(flag.CommandLine)
 	help := flag.Bool("help", false, "Show this help (add -v for more information)")
 	flag.Parse()
 
+	overrides, err := OverrideLayers(telemetry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing options: %s\n", err.Error())
+		os.Exit(ExitCodeUserParseConfig)
+	}
+	speed := float32(-1)
+	if *manualSpeed != "" {
+		speed, err = ParseManualSpeed(*manualSpeed)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing options: %s\n", err.Error())
+			os.Exit(ExitCodeUserParseConfig)
+		}
+	}
+	debug.Setup("", "", "")
+
+	config := loadLayers(configPath, defaultConfigPath, overrides)
+	for _, migration := range config.Migrations() {
+		fmt.Fprintf(os.Stderr, "Migrated configuration %s, use \"fanmi config convert\" to update the file\n", migration)
+	}
 	if *help {
+		showOverrideDefaults(flag.CommandLine, config)
 		showHelp(debug.DebugOutput)
-		os.Exit(0)
 	}
-
-	config := &defaultConfig
 	config.Active = true
 	config.Running = true
 	config.UI = ui
-	config.loadFromFile(configPath, defaultConfigPath)
+	config.ManualSpeed = speed
+	config.ManualDuration = *manualDuration
+	config.deriveTelemetryFormat()
+
+	err = config.Validate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%s\n", err.Error())
+		os.Exit(ExitCodeInvalidConfig)
+	}
+
+	err = debug.Setup(config.Log.Level, config.Log.Sink, config.Log.File)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up log output: %s\n", err.Error())
+	}
+	config.applyProfile()
 	config.prepareCurves()
-	// Make sure the configured mode is set before reading current mode
-	config.PowerModeChanged = config.PowerMode != ""
+	// Make sure the configured mode is set on all devices before reading their current mode
+	if config.PowerMode != "" {
+		config.powerModeChanges = 1
+	}
 
 	debug.LogJSON("Configuration:\n", config, "\n\n")
 
 	return config
 }
 
+// ParseManualSpeed reads a speed in percent, with or without "%"
+func ParseManualSpeed(text string) (float32, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "%")), 32)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("invalid speed '%s', use a value from 0 to 100%%", text)
+	}
+	return units.FromPercent(float32(percent)), nil
+}
+
+// Parse reads the configuration data on top of the default configuration. The curves are not prepared, so
+// the result can be validated.
+func Parse(data []byte) (*Configuration, error) {
+	return ParseLayers([]Layer{{Data: data}})
+}
+
+// Migrations returns the migrations applied to older configuration files, e.g.
+// "/etc/fanmi/config.json from version 1 to 2: the curve was moved to curves.default"
+func (c *Configuration) Migrations() []string {
+	return c.migrations
+}
+
+// defaults returns a copy of the default configuration
+func defaults() *Configuration {
+	data, _ := json.Marshal(&defaultConfig)
+	config := &Configuration{}
+	json.Unmarshal(data, config)
+	config.Running = defaultConfig.Running
+	config.Active = defaultConfig.Active
+	config.UI = defaultConfig.UI
+	config.ManualSpeed = defaultConfig.ManualSpeed
+	return config
+}
+
 func showHelp(verbose bool) {
-	defaultConfigJSON, _ := json.MarshalIndent(defaultConfig, "", "\t")
+	defaultConfigJSON, _ := json.MarshalIndent(&defaultConfig, "", "\t")
 
+	fmt.Println(`Usage: fanmi [options]`)
+	fmt.Println(`       fanmi stats [-band degrees] telemetry-file...`)
+	fmt.Println(`       fanmi validate [-config file]`)
+	fmt.Println(`       fanmi config show [-effective] [-config file] [options]`)
+	fmt.Println(`       fanmi config convert [-to json|yaml|toml] input-file [output-file]`)
+	fmt.Println(`       fanmi config schema`)
+	fmt.Println(`       fanmi import [-from amdgpu-fancontrol|corectrl|fancontrol] [-to json|yaml|toml] input-file [output-file]`)
+	fmt.Println(``)
 	fmt.Println(`CLI Options:`)
 	flag.PrintDefaults()
 
@@ -90,6 +350,10 @@ func showHelp(verbose bool) {
 		fmt.Printf("| %3d | Could not find user config directory          |\n", ExitCodeUserConfigDir)
 		fmt.Printf("| %3d | Could not read configuration file             |\n", ExitCodeUserConfigFile)
 		fmt.Printf("| %3d | Could not parse configuration file            |\n", ExitCodeUserParseConfig)
+		fmt.Printf("| %3d | No fan curves found                           |\n", ExitCodeNoCurves)
+		fmt.Printf("| %3d | Could not read from standard input            |\n", ExitCodeReadStdIn)
+		fmt.Printf("| %3d | Could not read telemetry file                 |\n", ExitCodeReadTelemetry)
+		fmt.Printf("| %3d | Invalid configuration                         |\n", ExitCodeInvalidConfig)
 		fmt.Printf("+-----+-----------------------------------------------+\n")
 
 		fmt.Println(``)
@@ -100,13 +364,94 @@ func showHelp(verbose bool) {
 	os.Exit(0)
 }
 
+// deriveTelemetryFormat uses the extension of the telemetry file if no format is set
+func (c *Configuration) deriveTelemetryFormat() {
+	t := &c.Telemetry
+	if t.Format == "" && strings.HasSuffix(t.File, ".csv") {
+		t.Format = "csv"
+	} else if t.Format == "" {
+		t.Format = "jsonl"
+	}
+}
+
+// SetActive switches the control of all devices, it can be called from any goroutine
+func (c *Configuration) SetActive(active bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.Active = active
+}
+
+// SetCheckIntervalMs changes the interval of the checks, it is used from the next check on
+func (c *Configuration) SetCheckIntervalMs(ms uint32) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.CheckIntervalMs = ms
+}
+
+// SetMinChange changes the temperature change needed for a different speed
+func (c *Configuration) SetMinChange(minChange float32) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.MinChange = minChange
+}
+
+// SetMaxSteps changes the maximal change of the fan speed per check upwards and downwards
+func (c *Configuration) SetMaxSteps(up, down float32) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.MaxStepUp = up
+	c.MaxStepDown = down
+}
+
+// SetAutoSave switches saving every change to the configuration file
+func (c *Configuration) SetAutoSave(autoSave bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.AutoSave = autoSave
+}
+
+// SetMinimizeToTray switches hiding the window in the system tray instead of closing it
+func (c *Configuration) SetMinimizeToTray(minimize bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.MinimizeToTray = minimize
+}
+
+// MinimizesToTray returns whether closing the window hides it in the system tray
+func (c *Configuration) MinimizesToTray() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.MinimizeToTray
+}
+
+// SetPowerMode selects the power mode of all devices, each device writes it with its next check
 func (c *Configuration) SetPowerMode(mode string) {
-	c.PowerModeChanged = mode != c.PowerMode
+	c.Lock()
+	defer c.Unlock()
+
+	if mode == c.PowerMode {
+		return
+	}
 	c.PowerMode = mode
-	debug.Log("Power mode changed to %s (%t)\n", c.PowerMode, c.PowerModeChanged)
+	c.powerModeChanges++
+	debug.Log("Power mode changed to %s\n", mode)
 }
 
 func (c *Configuration) SetCurve(curveName string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.setCurve(curveName)
+}
+
+func (c *Configuration) setCurve(curveName string) {
 	ok := false
 	c.Curve, ok = c.Curves[curveName]
 
@@ -127,6 +472,9 @@ func (c *Configuration) SetCurve(curveName string) {
 }
 
 func (c *Configuration) NextCurve() {
+	c.Lock()
+	defer c.Unlock()
+
 	i := -1
 	name := ""
 	for i, name = range c.CurveNames {
@@ -141,43 +489,121 @@ func (c *Configuration) NextCurve() {
 		i = i + 1
 	}
 
-	c.SetCurve(c.CurveNames[i])
+	c.setCurve(c.CurveNames[i])
 }
 
-func (config *Configuration) loadFromFile(configPath, defaultConfigPath string) {
-	configLoaded := false
-	var data []byte
-	var err error
+// PutCurve adds a new curve or replaces an existing one
+func (c *Configuration) PutCurve(curveName string, curve Values) error {
+	errs := ValidateCurve(curveName, curve)
+	if len(errs) > 0 {
+		return errs
+	}
+	sort.Sort(curve)
 
-	if configPath != "" {
-		// Load user provided configuration
-		debug.Log("Loading configuration file %s\n", configPath)
-		data, err = os.ReadFile(configPath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading config file: %s, trying default config path\n", err.Error())
-		} else {
-			configLoaded = true
+	c.Lock()
+	defer c.Unlock()
+
+	c.Curves[curveName] = curve
+	if c.CurrentCurve == curveName {
+		c.Curve = curve
+	}
+	c.updateCurveNames()
+
+	debug.Log("Curve %s stored\n", curveName)
+	return nil
+}
+
+// RenameCurve changes the name of a curve, the current curve stays selected under its new name
+func (c *Configuration) RenameCurve(curveName, newName string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	curve, ok := c.Curves[curveName]
+	if !ok {
+		return fmt.Errorf("curve '%s' not found", curveName)
+	}
+	if newName == "" {
+		return fmt.Errorf("curve name must not be empty")
+	}
+	if _, ok := c.Curves[newName]; ok {
+		return fmt.Errorf("curve '%s' already exists", newName)
+	}
+
+	delete(c.Curves, curveName)
+	c.Curves[newName] = curve
+	if c.CurrentCurve == curveName {
+		c.CurrentCurve = newName
+	}
+	for device, deviceConfig := range c.Devices {
+		if deviceConfig.Curve == curveName {
+			deviceConfig.Curve = newName
+			c.Devices[device] = deviceConfig
+		}
+	}
+	for name, profile := range c.Profiles {
+		if profile.Curve == curveName {
+			profile.Curve = newName
+			c.Profiles[name] = profile
+		}
+	}
+	c.updateCurveNames()
+
+	debug.Log("Curve %s renamed to %s\n", curveName, newName)
+	return nil
+}
+
+// DeleteCurve removes a curve, the current curve and curves used by a device or profile cannot be deleted
+func (c *Configuration) DeleteCurve(curveName string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.Curves[curveName]; !ok {
+		return fmt.Errorf("curve '%s' not found", curveName)
+	}
+	if c.CurrentCurve == curveName {
+		return fmt.Errorf("curve '%s' is currently used", curveName)
+	}
+	for device, deviceConfig := range c.Devices {
+		if deviceConfig.Curve == curveName {
+			return fmt.Errorf("curve '%s' is used by %s", curveName, device)
 		}
 	}
-	if !configLoaded {
-		debug.Log("Loading default configuration file %s\n", defaultConfigPath)
-		data, err = os.ReadFile(defaultConfigPath)
-		noConfigFile := os.IsNotExist(err)
-		if err != nil && !noConfigFile {
-			fmt.Fprintf(os.Stderr, "Error reading default config file %s: %s, fallback to defaults\n", configPath, err.Error())
-		} else if !noConfigFile {
-			configLoaded = true
+	for name, profile := range c.Profiles {
+		if profile.Curve == curveName {
+			return fmt.Errorf("curve '%s' is used by profile '%s'", curveName, name)
 		}
 	}
 
-	if configLoaded {
-		// Parse configuration
-		err = json.Unmarshal(data, config)
+	delete(c.Curves, curveName)
+	c.updateCurveNames()
+
+	debug.Log("Curve %s deleted\n", curveName)
+	return nil
+}
+
+// loadLayers reads the system-wide configuration and the user configuration and applies the overrides from
+// environment and CLI options. If the given configuration file cannot be read, the default user configuration is
+// used instead.
+func loadLayers(configPath, defaultConfigPath string, overrides []Layer) *Configuration {
+	userPath := configPath
+	if configPath != defaultConfigPath {
+		debug.Log("Loading configuration file %s\n", configPath)
+		_, err := os.Stat(configPath)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error parsing config file: %s\n", err.Error())
-			os.Exit(ExitCodeUserParseConfig)
+			fmt.Fprintf(os.Stderr, "Error reading config file: %s, trying default config path\n", err.Error())
+			userPath = defaultConfigPath
 		}
 	}
+
+	config, warnings, err := Load(userPath, overrides...)
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "%s, ignoring it\n", warning.Error())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing config file: %s\n", err.Error())
+		os.Exit(ExitCodeUserParseConfig)
+	}
+	return config
 }
 
 func (config *Configuration) prepareCurves() {
@@ -205,7 +631,11 @@ func (config *Configuration) prepareCurves() {
 		config.Curve = curve
 	}
 
-	// Sorted list of available curveNames
+	config.updateCurveNames()
+}
+
+// updateCurveNames creates the sorted list of available curve names
+func (config *Configuration) updateCurveNames() {
 	config.CurveNames = make([]string, 0, len(config.Curves))
 	for key := range config.Curves {
 		config.CurveNames = append(config.CurveNames, key)
@@ -213,5 +643,4 @@ func (config *Configuration) prepareCurves() {
 	sort.Slice(config.CurveNames, func(a, b int) bool {
 		return config.CurveNames[a] < config.CurveNames[b]
 	})
-
 }
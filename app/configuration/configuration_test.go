@@ -0,0 +1,151 @@
+package configuration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfiguration_RenameCurve(t *testing.T) {
+	tests := []struct {
+		name        string
+		curveName   string
+		newName     string
+		wantErr     string
+		wantNames   []string
+		wantCurrent string
+		wantProfile string
+	}{
+		{
+			name:        "Current curve",
+			curveName:   "quiet",
+			newName:     "silent",
+			wantNames:   []string{"loud", "silent"},
+			wantCurrent: "silent",
+			wantProfile: "loud",
+		},
+		{
+			name:        "Other curve",
+			curveName:   "loud",
+			newName:     "turbo",
+			wantNames:   []string{"quiet", "turbo"},
+			wantCurrent: "quiet",
+			wantProfile: "turbo",
+		},
+		{
+			name:      "Unknown curve",
+			curveName: "silent",
+			newName:   "turbo",
+			wantErr:   "curve 'silent' not found",
+		},
+		{
+			name:      "Existing name",
+			curveName: "quiet",
+			newName:   "loud",
+			wantErr:   "curve 'loud' already exists",
+		},
+		{
+			name:      "Empty name",
+			curveName: "quiet",
+			newName:   "",
+			wantErr:   "curve name must not be empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Configuration{
+				CurrentCurve: "quiet",
+				Curves: map[string]Values{
+					"quiet": {{Temp: 50, Speed: 0.2}},
+					"loud":  {{Temp: 50, Speed: 0.8}},
+				},
+				Profiles: map[string]Profile{"night": {Curve: "loud"}},
+			}
+			config.updateCurveNames()
+
+			err := config.RenameCurve(tt.curveName, tt.newName)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("RenameCurve() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenameCurve() error = %s", err.Error())
+			}
+			if !reflect.DeepEqual(config.CurveNames, tt.wantNames) {
+				t.Errorf("CurveNames = %v, want %v", config.CurveNames, tt.wantNames)
+			}
+			if config.CurrentCurve != tt.wantCurrent {
+				t.Errorf("CurrentCurve = %s, want %s", config.CurrentCurve, tt.wantCurrent)
+			}
+			if got := config.Profiles["night"].Curve; got != tt.wantProfile {
+				t.Errorf("Profiles[night].Curve = %s, want %s", got, tt.wantProfile)
+			}
+		})
+	}
+}
+
+func TestConfiguration_DeleteCurve(t *testing.T) {
+	tests := []struct {
+		name      string
+		curveName string
+		wantErr   string
+		wantNames []string
+	}{
+		{
+			name:      "Unused curve",
+			curveName: "loud",
+			wantNames: []string{"night", "quiet", "turbo"},
+		},
+		{
+			name:      "Unknown curve",
+			curveName: "silent",
+			wantErr:   "curve 'silent' not found",
+		},
+		{
+			name:      "Current curve",
+			curveName: "quiet",
+			wantErr:   "curve 'quiet' is currently used",
+		},
+		{
+			name:      "Device curve",
+			curveName: "turbo",
+			wantErr:   "curve 'turbo' is used by card1",
+		},
+		{
+			name:      "Profile curve",
+			curveName: "night",
+			wantErr:   "curve 'night' is used by profile 'sleep'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Configuration{
+				CurrentCurve: "quiet",
+				Curves: map[string]Values{
+					"quiet": {{Temp: 50, Speed: 0.2}},
+					"loud":  {{Temp: 50, Speed: 0.8}},
+					"turbo": {{Temp: 50, Speed: 1}},
+					"night": {{Temp: 50, Speed: 0.1}},
+				},
+				Devices:  map[string]DeviceConfiguration{"card1": {Curve: "turbo"}},
+				Profiles: map[string]Profile{"sleep": {Curve: "night"}},
+			}
+			config.updateCurveNames()
+
+			err := config.DeleteCurve(tt.curveName)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("DeleteCurve() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DeleteCurve() error = %s", err.Error())
+			}
+			if !reflect.DeepEqual(config.CurveNames, tt.wantNames) {
+				t.Errorf("CurveNames = %v, want %v", config.CurveNames, tt.wantNames)
+			}
+		})
+	}
+}
@@ -1,5 +1,10 @@
 package configuration
 
+import (
+	"regexp"
+	"slices"
+)
+
 const (
 	ExitCodeOpenDevice           = 1
 	ExitCodeFindDevice           = 2
@@ -15,29 +20,136 @@ const (
 	ExitCodeUserParseConfig      = 12
 	ExitCodeNoCurves             = 13
 	ExitCodeReadStdIn            = 14
+	ExitCodeReadTelemetry        = 15
+	ExitCodeInvalidConfig        = 16
 )
 
 // const (
 // 	ModeCurve = "curve"
 // )
 
+// PowerLevels documented for power_dpm_force_performance_level. The kernel does not list the levels a card
+// accepts (e.g. perf_determinism only exists on some server cards), so this list is static and a level a card
+// rejects is reported when it is written.
+var PowerLevels = []string{
+	"auto",
+	"low",
+	"high",
+	"manual",
+	"profile_standard",
+	"profile_min_sclk",
+	"profile_min_mclk",
+	"profile_peak",
+	"perf_determinism",
+	"profile_exit",
+}
+
+// PowerModes that can be selected by the user, profile_exit leaves a profile_* level and is never reported by the
+// driver
+var PowerModes = slices.Clone(PowerLevels[:len(PowerLevels)-1])
+
+// Names of pp_power_profile_mode entries like 3D_FULL_SCREEN
+var powerProfileModePattern = regexp.MustCompile(`^[A-Z0-9_]*[A-Z][A-Z0-9_]*$`)
+
+// LogLevels that can be configured in log.level
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// LogSinks that can be configured in log.sink
+var LogSinks = []string{"stderr", "journald", "file"}
+
+// TelemetryFormats that can be configured in telemetry.format
+var TelemetryFormats = []string{"csv", "jsonl"}
+
+// NotificationEvents that can be configured in notifications.rules[].event
+var NotificationEvents = []string{"temperature", "stall", "failsafe", "writeError"}
+
+// NotificationUrgencies that can be configured in notifications.rules[].urgency
+var NotificationUrgencies = []string{"low", "normal", "critical"}
+
+// ConsoleActions that can be bound to keys in console.keys
+var ConsoleActions = []string{
+	"select-previous", "select-next", "toggle", "next-curve", "previous-curve", "power-auto", "power-low", "power-high",
+	"speed-up", "speed-down", "power-cap-up", "power-cap-down", "next-profile", "next-power-profile-mode", "resume",
+	"pause", "help", "quit",
+}
+
 var defaultConfig = Configuration{
+	Version:         CurrentVersion,
 	Running:         true,
 	Active:          true,
 	UI:              "graphic",
+	ManualSpeed:     -1,
 	CheckIntervalMs: 3000,
 	PowerMode:       "auto",
 	MinChange:       2.0,
 	MaxStepUp:       4,
 	MaxStepDown:     2,
+	FailsafeTemp:    95,
 	CurrentCurve:    "",
+	API: APIConfiguration{
+		TokenFile: "/run/fanmi/api-token",
+	},
+	MQTT: MQTTConfiguration{
+		ClientID:        "fanmi",
+		StateTopic:      "fanmi/{device}/state",
+		CommandTopic:    "fanmi/set",
+		DiscoveryPrefix: "homeassistant",
+	},
+	Log: LogConfiguration{
+		Level: "warn",
+		Sink:  "stderr",
+	},
+	Telemetry: TelemetryConfiguration{
+		MaxSize:  10,
+		MaxFiles: 3,
+	},
+	Notifications: NotificationsConfiguration{
+		Cooldown:  300,
+		RateLimit: 5,
+		Rules: []NotificationRule{
+			{Event: "temperature", Temp: 85, Urgency: "normal"},
+			{Event: "stall", Urgency: "critical"},
+			{Event: "failsafe", Urgency: "critical"},
+			{Event: "writeError", Urgency: "normal"},
+		},
+	},
+	Console: ConsoleConfiguration{
+		Keys: map[string]string{
+			"up":     "select-previous",
+			"k":      "select-previous",
+			"down":   "select-next",
+			"j":      "select-next",
+			"tab":    "select-next",
+			"space":  "toggle",
+			"c":      "next-curve",
+			"C":      "previous-curve",
+			"a":      "power-auto",
+			"l":      "power-low",
+			"h":      "power-high",
+			"+":      "speed-up",
+			"=":      "speed-up",
+			"-":      "speed-down",
+			"]":      "power-cap-up",
+			"[":      "power-cap-down",
+			"P":      "next-profile",
+			"m":      "next-power-profile-mode",
+			"r":      "resume",
+			"p":      "pause",
+			"?":      "help",
+			"q":      "quit",
+			"ctrl-c": "quit",
+		},
+		PauseMinutes: 10,
+		SpeedStep:    5,
+		PowerCapStep: 10,
+	},
 	Curves: map[string]Values{
 		"default": {
-			{40, 0},
-			{60, 0.2},
-			{80, 0.5},
-			{85, 0.7},
-			{90, 1},
+			{Temp: 40, Speed: 0},
+			{Temp: 60, Speed: 0.2},
+			{Temp: 80, Speed: 0.5},
+			{Temp: 85, Speed: 0.7},
+			{Temp: 90, Speed: 1},
 		},
 	},
 }
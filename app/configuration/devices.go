@@ -0,0 +1,293 @@
+package configuration
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirion/fanmi/app/debug"
+	"github.com/sirion/fanmi/app/units"
+)
+
+// DeviceCurve returns the name and curve used by the device, the global curve if the device has none of its own
+func (c *Configuration) DeviceCurve(device string) (string, Values) {
+	c.RLock()
+	defer c.RUnlock()
+
+	if name := c.Devices[device].Curve; name != "" {
+		if curve, ok := c.Curves[name]; ok {
+			return name, curve
+		}
+	}
+	return c.CurrentCurve, c.Curve
+}
+
+// SetDeviceCurve selects a curve for a single device, an empty name selects the global curve again
+func (c *Configuration) SetDeviceCurve(device, curveName string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.Curves[curveName]; curveName != "" && !ok {
+		debug.Log("Selected fan curve '%s' not found\n", curveName)
+		return
+	}
+
+	deviceConfig := c.Devices[device]
+	deviceConfig.Curve = curveName
+	c.setDevice(device, deviceConfig)
+
+	debug.Log("Curve of %s changed to %s\n", device, curveName)
+}
+
+// setDevice stores the settings of a device, the lock must be held
+func (c *Configuration) setDevice(device string, deviceConfig DeviceConfiguration) {
+	if c.Devices == nil {
+		c.Devices = make(map[string]DeviceConfiguration)
+	}
+	if deviceConfig == (DeviceConfiguration{}) {
+		delete(c.Devices, device)
+	} else {
+		c.Devices[device] = deviceConfig
+	}
+	if len(c.Devices) == 0 {
+		c.Devices = nil
+	}
+}
+
+// PendingPowerMode returns the power mode the device has to write and true, if the mode was selected since the
+// device wrote it last (or at start-up). Every device gets every change once.
+func (c *Configuration) PendingPowerMode(device string) (string, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.appliedPowerModes[device] == c.powerModeChanges {
+		return "", false
+	}
+	if c.appliedPowerModes == nil {
+		c.appliedPowerModes = make(map[string]int)
+	}
+	c.appliedPowerModes[device] = c.powerModeChanges
+	return c.PowerMode, c.PowerMode != ""
+}
+
+// DevicePowerCap returns the power limit of the device in W, 0 to keep the limit of the driver
+func (c *Configuration) DevicePowerCap(device string) float32 {
+	c.RLock()
+	defer c.RUnlock()
+
+	if watts := c.Devices[device].PowerCap; watts > 0 {
+		return watts
+	}
+	return c.PowerCap
+}
+
+// SetDevicePowerCap sets the power limit of a single device in W, 0 selects the global power limit again
+func (c *Configuration) SetDevicePowerCap(device string, watts float32) {
+	c.Lock()
+	defer c.Unlock()
+
+	deviceConfig := c.Devices[device]
+	deviceConfig.PowerCap = max(watts, 0)
+	c.setDevice(device, deviceConfig)
+
+	debug.Log("Power limit of %s changed to %.0f W\n", device, deviceConfig.PowerCap)
+}
+
+// DevicePowerProfileMode returns the name of the pp_power_profile_mode entry of the device, an empty string to keep
+// the mode of the driver
+func (c *Configuration) DevicePowerProfileMode(device string) string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if mode := c.Devices[device].PowerProfileMode; mode != "" {
+		return mode
+	}
+	return c.PowerProfileMode
+}
+
+// SetDevicePowerProfileMode selects an entry of pp_power_profile_mode for a single device, an empty name selects the
+// global mode again
+func (c *Configuration) SetDevicePowerProfileMode(device, mode string) {
+	c.Lock()
+	defer c.Unlock()
+
+	deviceConfig := c.Devices[device]
+	deviceConfig.PowerProfileMode = mode
+	c.setDevice(device, deviceConfig)
+
+	debug.Log("Power profile mode of %s changed to %s\n", device, mode)
+}
+
+// ProfileNames returns the sorted names of all profiles
+func (c *Configuration) ProfileNames() []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile sets curve and power limit of the profile for a single device
+func (c *Configuration) ApplyProfile(device, profileName string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	profile, ok := c.Profiles[profileName]
+	if !ok {
+		return fmt.Errorf("profile '%s' not found", profileName)
+	}
+	if _, ok := c.Curves[profile.Curve]; profile.Curve != "" && !ok {
+		return fmt.Errorf("curve '%s' of profile '%s' not found", profile.Curve, profileName)
+	}
+	deviceConfig := c.Devices[device]
+	deviceConfig.Curve = profile.Curve
+	deviceConfig.PowerCap = profile.PowerCap
+	c.setDevice(device, deviceConfig)
+
+	debug.Log("Profile of %s changed to %s\n", device, profileName)
+	return nil
+}
+
+// DeviceProfile returns the name of the first profile matching curve and power limit of the device, an empty
+// string if there is none
+func (c *Configuration) DeviceProfile(device string) string {
+	curveName, _ := c.DeviceCurve(device)
+	watts := c.DevicePowerCap(device)
+
+	names := c.ProfileNames()
+
+	c.RLock()
+	defer c.RUnlock()
+
+	for _, name := range names {
+		profile := c.Profiles[name]
+		if profile.Curve == "" {
+			profile.Curve = c.CurrentCurve
+		}
+		if profile.PowerCap <= 0 {
+			profile.PowerCap = c.PowerCap
+		}
+		if profile.Curve == curveName && profile.PowerCap == watts {
+			return name
+		}
+	}
+	return ""
+}
+
+// applyProfile uses curve and power limit of the start-up profile for all devices
+func (c *Configuration) applyProfile() {
+	profile, ok := c.Profiles[c.Profile]
+	if !ok {
+		return
+	}
+	if profile.Curve != "" {
+		c.CurrentCurve = profile.Curve
+	}
+	c.PowerCap = profile.PowerCap
+}
+
+// DeviceActive checks whether fanmi controls the fan of the device
+func (c *Configuration) DeviceActive(device string) bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.Active && !c.inactiveDevices[device] && !time.Now().Before(c.pausedDevices[device])
+}
+
+// SetDeviceActive switches the control of a single device, it stays off while Active is false
+func (c *Configuration) SetDeviceActive(device string, active bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.inactiveDevices == nil {
+		c.inactiveDevices = make(map[string]bool)
+	}
+	if active {
+		delete(c.inactiveDevices, device)
+	} else {
+		c.inactiveDevices[device] = true
+	}
+	delete(c.pausedDevices, device)
+}
+
+// PauseDevice switches the control of a single device off for the duration
+func (c *Configuration) PauseDevice(device string, duration time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.pausedDevices == nil {
+		c.pausedDevices = make(map[string]time.Time)
+	}
+	delete(c.inactiveDevices, device)
+	c.pausedDevices[device] = time.Now().Add(duration)
+
+	debug.Log("%s paused for %s\n", device, duration)
+}
+
+// DevicePausedUntil returns the end of the pause of the device, the zero time if it is not paused
+func (c *Configuration) DevicePausedUntil(device string) time.Time {
+	c.RLock()
+	defer c.RUnlock()
+
+	until := c.pausedDevices[device]
+	if !time.Now().Before(until) {
+		return time.Time{}
+	}
+	return until
+}
+
+// DeviceSpeed returns the fixed speed (0-1) set by the user for the device instead of the curve
+func (c *Configuration) DeviceSpeed(device string) (float32, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	fixed, ok := c.deviceSpeeds[device]
+	if !ok || (!fixed.until.IsZero() && !time.Now().Before(fixed.until)) {
+		return 0, false
+	}
+	return fixed.speed, true
+}
+
+// DeviceSpeedUntil returns when the curve is used again for the device, the zero time if the speed is not fixed or
+// does not expire
+func (c *Configuration) DeviceSpeedUntil(device string) time.Time {
+	c.RLock()
+	defer c.RUnlock()
+
+	fixed := c.deviceSpeeds[device]
+	if !time.Now().Before(fixed.until) {
+		return time.Time{}
+	}
+	return fixed.until
+}
+
+// SetDeviceSpeed fixes the speed (0-1) of the device for the duration, 0 until ResetDeviceSpeed is called
+func (c *Configuration) SetDeviceSpeed(device string, speed float32, duration time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.deviceSpeeds == nil {
+		c.deviceSpeeds = make(map[string]fixedSpeed)
+	}
+	fixed := fixedSpeed{speed: units.Clamp(speed)}
+	if duration != 0 {
+		fixed.until = time.Now().Add(duration)
+	}
+	c.deviceSpeeds[device] = fixed
+
+	debug.Log("Speed of %s fixed to %.0f%% for %s\n", device, units.Percent(fixed.speed), duration)
+}
+
+// ResetDeviceSpeed lets the curve control the speed of the device again
+func (c *Configuration) ResetDeviceSpeed(device string) {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.deviceSpeeds, device)
+
+	debug.Log("Speed of %s controlled by curve\n", device)
+}
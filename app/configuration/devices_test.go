@@ -0,0 +1,287 @@
+package configuration
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfiguration_DeviceCurve(t *testing.T) {
+	tests := []struct {
+		name      string
+		devices   map[string]DeviceConfiguration
+		set       map[string]string
+		device    string
+		wantCurve string
+		wantSpeed float32
+	}{
+		{
+			name:      "Global curve",
+			device:    "card0",
+			wantCurve: "quiet",
+			wantSpeed: 0.2,
+		},
+		{
+			name:      "Device curve",
+			devices:   map[string]DeviceConfiguration{"card1": {Curve: "loud"}},
+			device:    "card1",
+			wantCurve: "loud",
+			wantSpeed: 0.8,
+		},
+		{
+			name:      "Other device",
+			devices:   map[string]DeviceConfiguration{"card1": {Curve: "loud"}},
+			device:    "card0",
+			wantCurve: "quiet",
+			wantSpeed: 0.2,
+		},
+		{
+			name:      "Set device curve",
+			set:       map[string]string{"card0": "loud"},
+			device:    "card0",
+			wantCurve: "loud",
+			wantSpeed: 0.8,
+		},
+		{
+			name:      "Reset device curve",
+			devices:   map[string]DeviceConfiguration{"card0": {Curve: "loud"}},
+			set:       map[string]string{"card0": ""},
+			device:    "card0",
+			wantCurve: "quiet",
+			wantSpeed: 0.2,
+		},
+		{
+			name:      "Unknown curve is ignored",
+			set:       map[string]string{"card0": "turbo"},
+			device:    "card0",
+			wantCurve: "quiet",
+			wantSpeed: 0.2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Configuration{
+				CurrentCurve: "quiet",
+				Curves: map[string]Values{
+					"quiet": {{Temp: 50, Speed: 0.2}},
+					"loud":  {{Temp: 50, Speed: 0.8}},
+				},
+				Devices: tt.devices,
+			}
+			config.Curve = config.Curves["quiet"]
+			for device, curveName := range tt.set {
+				config.SetDeviceCurve(device, curveName)
+			}
+
+			gotCurve, curve := config.DeviceCurve(tt.device)
+			if gotCurve != tt.wantCurve {
+				t.Errorf("DeviceCurve() name = %s, want %s", gotCurve, tt.wantCurve)
+			}
+			if curve[0].Speed != tt.wantSpeed {
+				t.Errorf("DeviceCurve() speed = %v, want %v", curve[0].Speed, tt.wantSpeed)
+			}
+		})
+	}
+}
+
+func TestConfiguration_DeviceActive(t *testing.T) {
+	config := &Configuration{Active: true}
+	config.SetDeviceActive("card1", false)
+
+	if !config.DeviceActive("card0") {
+		t.Errorf("DeviceActive(card0) = false, want true")
+	}
+	if config.DeviceActive("card1") {
+		t.Errorf("DeviceActive(card1) = true, want false")
+	}
+
+	config.Active = false
+	config.SetDeviceActive("card1", true)
+	if config.DeviceActive("card1") {
+		t.Errorf("DeviceActive(card1) = true while inactive, want false")
+	}
+}
+
+func TestConfiguration_PauseDevice(t *testing.T) {
+	config := &Configuration{Active: true}
+	config.SetDeviceActive("card0", false)
+	config.PauseDevice("card0", time.Minute)
+	config.PauseDevice("card1", -time.Second)
+
+	if config.DeviceActive("card0") {
+		t.Errorf("DeviceActive(card0) = true while paused, want false")
+	}
+	if config.DevicePausedUntil("card0").IsZero() {
+		t.Errorf("DevicePausedUntil(card0) is zero while paused")
+	}
+	if !config.DeviceActive("card1") || !config.DevicePausedUntil("card1").IsZero() {
+		t.Errorf("card1 is still paused after the pause ended")
+	}
+
+	config.SetDeviceActive("card0", true)
+	if !config.DeviceActive("card0") || !config.DevicePausedUntil("card0").IsZero() {
+		t.Errorf("card0 is still paused after activating it")
+	}
+}
+
+func TestConfiguration_SetDeviceSpeed(t *testing.T) {
+	config := &Configuration{}
+	config.SetDeviceSpeed("card0", 1.2, 0)
+	config.SetDeviceSpeed("card1", 0.6, time.Minute)
+	config.SetDeviceSpeed("card2", 0.6, -time.Second)
+
+	if speed, ok := config.DeviceSpeed("card0"); !ok || speed != 1 {
+		t.Errorf("DeviceSpeed(card0) = %v, %t, want 1, true", speed, ok)
+	}
+	if !config.DeviceSpeedUntil("card0").IsZero() {
+		t.Errorf("DeviceSpeedUntil(card0) = %s, want zero time", config.DeviceSpeedUntil("card0"))
+	}
+	if speed, ok := config.DeviceSpeed("card1"); !ok || speed != 0.6 || config.DeviceSpeedUntil("card1").IsZero() {
+		t.Errorf("DeviceSpeed(card1) = %v, %t until %s, want 0.6, true until in a minute", speed, ok, config.DeviceSpeedUntil("card1"))
+	}
+	if _, ok := config.DeviceSpeed("card2"); ok {
+		t.Errorf("DeviceSpeed(card2) is set after it expired")
+	}
+	if _, ok := config.DeviceSpeed("card3"); ok {
+		t.Errorf("DeviceSpeed(card3) is set")
+	}
+
+	config.ResetDeviceSpeed("card0")
+	if _, ok := config.DeviceSpeed("card0"); ok {
+		t.Errorf("DeviceSpeed(card0) is set after ResetDeviceSpeed()")
+	}
+}
+
+func TestParseManualSpeed(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    float32
+		wantErr bool
+	}{
+		{"60", 0.6, false},
+		{" 45% ", 0.45, false},
+		{"0", 0, false},
+		{"100%", 1, false},
+		{"120", 0, true},
+		{"-5", 0, true},
+		{"fast", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseManualSpeed(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseManualSpeed(%q) error = %v, wantErr %t", tt.text, err, tt.wantErr)
+		} else if got != tt.want {
+			t.Errorf("ParseManualSpeed(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestConfiguration_ApplyProfile(t *testing.T) {
+	config := &Configuration{
+		CurrentCurve: "default",
+		Curves: map[string]Values{
+			"default": {{Temp: 50, Speed: 0.5}},
+			"low":     {{Temp: 50, Speed: 0.2}},
+		},
+		PowerCap: 200,
+		Profiles: map[string]Profile{
+			"quiet":    {Curve: "low", PowerCap: 150},
+			"standard": {},
+			"broken":   {Curve: "turbo"},
+		},
+	}
+
+	if got := config.DeviceProfile("card0"); got != "standard" {
+		t.Errorf("DeviceProfile() = %s, want standard", got)
+	}
+
+	err := config.ApplyProfile("card0", "quiet")
+	if err != nil {
+		t.Fatalf("ApplyProfile() error = %s", err.Error())
+	}
+	if curveName, _ := config.DeviceCurve("card0"); curveName != "low" {
+		t.Errorf("DeviceCurve() = %s, want low", curveName)
+	}
+	if got := config.DevicePowerCap("card0"); got != 150 {
+		t.Errorf("DevicePowerCap() = %v, want 150", got)
+	}
+	if got := config.DevicePowerCap("card1"); got != 200 {
+		t.Errorf("DevicePowerCap() of other device = %v, want 200", got)
+	}
+	if got := config.DeviceProfile("card0"); got != "quiet" {
+		t.Errorf("DeviceProfile() = %s, want quiet", got)
+	}
+
+	config.SetDevicePowerCap("card0", 160)
+	if got := config.DeviceProfile("card0"); got != "" {
+		t.Errorf("DeviceProfile() after changing the power limit = %s, want none", got)
+	}
+
+	if config.ApplyProfile("card0", "turbo") == nil {
+		t.Errorf("ApplyProfile() of unknown profile returned no error")
+	}
+	if config.ApplyProfile("card0", "broken") == nil {
+		t.Errorf("ApplyProfile() with unknown curve returned no error")
+	}
+
+	err = config.ApplyProfile("card0", "standard")
+	if err != nil {
+		t.Fatalf("ApplyProfile() error = %s", err.Error())
+	}
+	if config.Devices != nil {
+		t.Errorf("Devices = %v after applying the standard profile, want nil", config.Devices)
+	}
+}
+
+func TestConfiguration_DevicePowerProfileMode(t *testing.T) {
+	config := &Configuration{
+		PowerProfileMode: "3D_FULL_SCREEN",
+		Profiles:         map[string]Profile{"quiet": {PowerCap: 150}},
+	}
+	config.SetDevicePowerProfileMode("card0", "COMPUTE")
+
+	if got := config.DevicePowerProfileMode("card0"); got != "COMPUTE" {
+		t.Errorf("DevicePowerProfileMode(card0) = %s, want COMPUTE", got)
+	}
+	if got := config.DevicePowerProfileMode("card1"); got != "3D_FULL_SCREEN" {
+		t.Errorf("DevicePowerProfileMode(card1) = %s, want 3D_FULL_SCREEN", got)
+	}
+
+	// Profiles do not change the power profile mode
+	config.ApplyProfile("card0", "quiet")
+	if got := config.DevicePowerProfileMode("card0"); got != "COMPUTE" {
+		t.Errorf("DevicePowerProfileMode(card0) after applying a profile = %s, want COMPUTE", got)
+	}
+
+	config.SetDevicePowerProfileMode("card0", "")
+	config.SetDevicePowerCap("card0", 0)
+	if config.Devices != nil {
+		t.Errorf("Devices = %v after resetting all settings, want nil", config.Devices)
+	}
+}
+
+func TestConfiguration_PendingPowerMode(t *testing.T) {
+	config := &Configuration{}
+	if _, ok := config.PendingPowerMode("card0"); ok {
+		t.Errorf("PendingPowerMode(card0) = true without a power mode, want false")
+	}
+
+	config.SetPowerMode("low")
+	for _, device := range []string{"card0", "card1"} {
+		if mode, ok := config.PendingPowerMode(device); !ok || mode != "low" {
+			t.Errorf("PendingPowerMode(%s) = %s, %t, want low, true", device, mode, ok)
+		}
+		if _, ok := config.PendingPowerMode(device); ok {
+			t.Errorf("PendingPowerMode(%s) = true after writing the mode, want false", device)
+		}
+	}
+
+	config.SetPowerMode("low")
+	if _, ok := config.PendingPowerMode("card0"); ok {
+		t.Errorf("PendingPowerMode(card0) = true after selecting the same mode, want false")
+	}
+
+	config.SetPowerMode("high")
+	if mode, ok := config.PendingPowerMode("card1"); !ok || mode != "high" {
+		t.Errorf("PendingPowerMode(card1) = %s, %t, want high, true", mode, ok)
+	}
+}
@@ -0,0 +1,114 @@
+package configuration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported configuration file formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// Extensions of configuration files in the order they are looked for
+var configExtensions = []string{".json", ".yaml", ".yml", ".toml"}
+
+// FormatOf returns the format of a configuration file by its extension, JSON for unknown extensions
+func FormatOf(filePath string) string {
+	switch strings.ToLower(path.Ext(filePath)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// ToJSON converts a configuration in the given format to JSON, so all formats share the same parsing and validation
+func ToJSON(data []byte, format string) ([]byte, error) {
+	var value any
+	var err error
+
+	switch format {
+	case FormatJSON, "":
+		return data, nil
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &value)
+	case FormatTOML:
+		value = make(map[string]any)
+		_, err = toml.Decode(string(data), &value)
+	default:
+		return nil, fmt.Errorf("unknown configuration format '%s'", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if value == nil {
+		// Empty YAML file
+		return []byte("{}"), nil
+	}
+	return json.Marshal(value)
+}
+
+// FromJSON converts a JSON configuration to the given format
+func FromJSON(data []byte, format string) ([]byte, error) {
+	if format == FormatJSON || format == "" {
+		return data, nil
+	}
+
+	var value map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// Keep integers as integers
+	decoder.UseNumber()
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	value = normalizeNumbers(value).(map[string]any)
+
+	buffer := &bytes.Buffer{}
+	switch format {
+	case FormatYAML:
+		encoder := yaml.NewEncoder(buffer)
+		encoder.SetIndent(2)
+		err = encoder.Encode(value)
+	case FormatTOML:
+		encoder := toml.NewEncoder(buffer)
+		encoder.Indent = ""
+		err = encoder.Encode(value)
+	default:
+		err = fmt.Errorf("unknown configuration format '%s'", format)
+	}
+	return buffer.Bytes(), err
+}
+
+// normalizeNumbers replaces json.Number with int64 or float64, which the encoders understand
+func normalizeNumbers(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, element := range v {
+			v[key] = normalizeNumbers(element)
+		}
+	case []any:
+		for i, element := range v {
+			v[i] = normalizeNumbers(element)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
@@ -0,0 +1,67 @@
+package configuration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToJSON(t *testing.T) {
+	want := Values{{Temp: 50, Speed: 0.2}, {Temp: 80, Speed: 0.6}}
+
+	tests := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{"JSON", FormatJSON, `{"checkIntervalMs": 2000, "curve": "quiet", "curves": {"quiet": [{"Temp": 50, "Speed": 0.2}, {"temp": 80, "speed": 0.6}]}}`},
+		{"YAML", FormatYAML, "# Night\ncheckIntervalMs: 2000\ncurve: quiet\ncurves:\n  quiet:\n    - temp: 50 # idle\n      speed: 0.2\n    - Temp: 80\n      Speed: 0.6\n"},
+		{"TOML", FormatTOML, "checkIntervalMs = 2000\ncurve = \"quiet\"\n\n[curves]\n# Night\nquiet = [{temp = 50, speed = 0.2}, {temp = 80, speed = 0.6}]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ToJSON([]byte(tt.data), tt.format)
+			if err != nil {
+				t.Fatalf("ToJSON() error = %s", err.Error())
+			}
+			config, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse() error = %s", err.Error())
+			}
+			if err = config.Validate(); err != nil {
+				t.Errorf("Validate() error = %s", err.Error())
+			}
+			if config.CheckIntervalMs != 2000 || !reflect.DeepEqual(config.Curves["quiet"], want) {
+				t.Errorf("Parse() = interval %d, curve %v", config.CheckIntervalMs, config.Curves["quiet"])
+			}
+
+			// Round trip
+			converted, err := FromJSON(data, tt.format)
+			if err != nil {
+				t.Fatalf("FromJSON() error = %s", err.Error())
+			}
+			data, err = ToJSON(converted, tt.format)
+			if err != nil {
+				t.Fatalf("ToJSON() of converted data error = %s\n%s", err.Error(), converted)
+			}
+			config, _ = Parse(data)
+			if config.CheckIntervalMs != 2000 || !reflect.DeepEqual(config.Curves["quiet"], want) {
+				t.Errorf("round trip = interval %d, curve %v", config.CheckIntervalMs, config.Curves["quiet"])
+			}
+		})
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	formats := map[string]string{
+		"config.json":      FormatJSON,
+		"/etc/fanmi/x.yml": FormatYAML,
+		"config.YAML":      FormatYAML,
+		"config.toml":      FormatTOML,
+		"config":           FormatJSON,
+	}
+	for filePath, want := range formats {
+		if got := FormatOf(filePath); got != want {
+			t.Errorf("FormatOf(%s) = %s, want %s", filePath, got, want)
+		}
+	}
+}
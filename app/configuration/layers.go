@@ -0,0 +1,245 @@
+package configuration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// System-wide configuration, read before the configuration of the user
+var (
+	SystemConfigPath = "/etc/fanmi/config.json"
+	SystemConfigDir  = "/etc/fanmi/conf.d"
+)
+
+// Origin of values that are not set in any configuration file
+const (
+	OriginDefault = "default"
+	OriginCLI     = "cli"
+)
+
+// Layer is the content of one configuration file, later layers override earlier ones
+type Layer struct {
+	Origin string
+	Data   []byte
+	// Format of the data, JSON if empty
+	Format string
+}
+
+// DefaultConfigPath returns the path of the configuration file of the user who started fanmi. When running via
+// sudo or SUID this is not the configuration of root. If there is no config.json, but a YAML or TOML file, that
+// one is used.
+func DefaultConfigPath() (string, error) {
+	var configDir string
+	uid, _ := invokingUser()
+	if uid != os.Geteuid() {
+		account, err := user.LookupId(strconv.Itoa(uid))
+		if err != nil {
+			return "", err
+		}
+		configDir = path.Join(account.HomeDir, ".config", "fanmi")
+	} else {
+		userConfigDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		configDir = path.Join(userConfigDir, "fanmi")
+	}
+
+	for _, extension := range configExtensions {
+		configPath := path.Join(configDir, "config"+extension)
+		if _, err := os.Stat(configPath); err == nil {
+			return configPath, nil
+		}
+	}
+	return path.Join(configDir, "config.json"), nil
+}
+
+// readLayers reads the system configuration, all files in conf.d (sorted by name) and the user configuration.
+// Missing files are skipped, other problems are returned as warnings.
+func readLayers(userPath string) ([]Layer, []error) {
+	layers := make([]Layer, 0)
+	warnings := make([]error, 0)
+
+	read := func(filePath string) {
+		data, err := os.ReadFile(filePath)
+		if os.IsNotExist(err) {
+			return
+		} else if err != nil {
+			warnings = append(warnings, fmt.Errorf("error reading config file: %s", err.Error()))
+			return
+		}
+		layers = append(layers, Layer{Origin: filePath, Data: data, Format: FormatOf(filePath)})
+	}
+
+	read(SystemConfigPath)
+	confFiles := make([]string, 0)
+	for _, extension := range configExtensions {
+		files, _ := filepath.Glob(path.Join(SystemConfigDir, "*"+extension))
+		confFiles = append(confFiles, files...)
+	}
+	sort.Strings(confFiles)
+	for _, confFile := range confFiles {
+		read(confFile)
+	}
+	if userPath != "" {
+		read(userPath)
+	}
+
+	return layers, warnings
+}
+
+// Load reads and parses all configuration layers, followed by the given overrides. Files that cannot be read are
+// skipped and returned as warnings.
+func Load(userPath string, overrides ...Layer) (config *Configuration, warnings []error, err error) {
+	layers, warnings := readLayers(userPath)
+	config, err = ParseLayers(append(layers, overrides...))
+	// The user file is watched and saved to even if it does not exist (yet)
+	config.filePath = userPath
+	config.overrides = overrides
+	return config, warnings, err
+}
+
+// ParseLayers applies the layers on top of the default configuration and remembers which layer set each value
+func ParseLayers(layers []Layer) (*Configuration, error) {
+	config := defaults()
+	config.origins = make(map[string]string)
+
+	for _, layer := range layers {
+		data, err := ToJSON(layer.Data, layer.Format)
+		if err == nil {
+			var applied []string
+			data, applied, err = Migrate(data)
+			for _, migration := range applied {
+				config.migrations = append(config.migrations, strings.TrimSpace(layer.Origin+" from "+migration))
+			}
+		}
+		if err == nil {
+			err = json.Unmarshal(data, config)
+		}
+		if err != nil {
+			if layer.Origin == "" {
+				return config, err
+			}
+			return config, fmt.Errorf("%s: %s", layer.Origin, err.Error())
+		}
+
+		for _, unknown := range findUnknownKeys(data) {
+			if layer.Origin != "" {
+				unknown.Message += " in " + layer.Origin
+			}
+			config.unknownKeys = append(config.unknownKeys, unknown)
+		}
+
+		for valuePath := range flatten(data, "") {
+			config.origins[strings.ToLower(valuePath)] = layer.Origin
+		}
+	}
+
+	return config, nil
+}
+
+// Origin returns the layer that set the value at the path, e.g. "curves.quiet" or "mqtt.broker"
+func (c *Configuration) Origin(valuePath string) string {
+	valuePath = strings.ToLower(valuePath)
+	for {
+		if origin, ok := c.origins[valuePath]; ok {
+			return origin
+		}
+		i := strings.LastIndex(valuePath, ".")
+		if i < 0 {
+			return OriginDefault
+		}
+		valuePath = valuePath[:i]
+	}
+}
+
+// Values that are masked when the configuration is shown
+var secretPaths = [][]string{{"mqtt", "password"}}
+
+const secretMask = "********"
+
+// Show returns the configuration as indented JSON, secrets are masked
+func (c *Configuration) Show() []byte {
+	c.RLock()
+	data, _ := json.Marshal(c)
+	c.RUnlock()
+
+	buffer := &bytes.Buffer{}
+	json.Indent(buffer, maskSecrets(data), "", "\t")
+	return buffer.Bytes()
+}
+
+// PrintEffective writes every value of the configuration together with its origin, secrets are masked
+func (c *Configuration) PrintEffective(w io.Writer) {
+	c.RLock()
+	data, _ := json.Marshal(c)
+	c.RUnlock()
+
+	values := flatten(maskSecrets(data), "")
+
+	paths := make([]string, 0, len(values))
+	for valuePath := range values {
+		paths = append(paths, valuePath)
+	}
+	sort.Strings(paths)
+
+	for _, valuePath := range paths {
+		fmt.Fprintf(w, "%-28s %-32s %s\n", valuePath, c.Origin(valuePath), values[valuePath])
+	}
+}
+
+// maskSecrets replaces the secrets that are set in the JSON configuration, the order of the properties is kept
+func maskSecrets(data []byte) []byte {
+	for _, secretPath := range secretPaths {
+		data = maskValue(data, secretPath)
+	}
+	return data
+}
+
+func maskValue(data []byte, valuePath []string) []byte {
+	properties, err := readProperties(data)
+	if err != nil {
+		return data
+	}
+	buffer := &bytes.Buffer{}
+	buffer.WriteString("{")
+	for i, p := range properties {
+		if p.key == valuePath[0] && len(valuePath) > 1 {
+			p.value = maskValue(p.value, valuePath[1:])
+		} else if p.key == valuePath[0] && string(p.value) != `""` {
+			p.value = json.RawMessage(strconv.Quote(secretMask))
+		}
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(strconv.Quote(p.key) + ":")
+		buffer.Write(p.value)
+	}
+	buffer.WriteString("}")
+	return buffer.Bytes()
+}
+
+// flatten returns the JSON encoded leaf values of objects by their path, arrays (like curves) are leaves
+func flatten(data []byte, prefix string) map[string]string {
+	values := make(map[string]string)
+	object := make(map[string]json.RawMessage)
+	if json.Unmarshal(data, &object) != nil {
+		values[prefix] = string(data)
+		return values
+	}
+	for key, element := range object {
+		for valuePath, data := range flatten(element, strings.TrimPrefix(prefix+"."+key, ".")) {
+			values[valuePath] = data
+		}
+	}
+	return values
+}
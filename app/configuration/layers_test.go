@@ -0,0 +1,103 @@
+package configuration
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// useSystemConfig points the system-wide configuration to a temporary directory
+func useSystemConfig(t *testing.T) string {
+	dir := t.TempDir()
+	systemConfigPath, systemConfigDir := SystemConfigPath, SystemConfigDir
+	SystemConfigPath = path.Join(dir, "config.json")
+	SystemConfigDir = path.Join(dir, "conf.d")
+	t.Cleanup(func() {
+		SystemConfigPath, SystemConfigDir = systemConfigPath, systemConfigDir
+	})
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := useSystemConfig(t)
+	userPath := path.Join(dir, "user.json")
+	files := map[string]string{
+		SystemConfigPath: `{"minChange": 3, "maxStepUp": 5, "mqtt": {"broker": "tcp://nas:1883"}}`,
+		path.Join(SystemConfigDir, "10-curves.json"): `{"curves": {"quiet": [{"Temp": 50, "Speed": 0.3}]}, "maxStepUp": 6}`,
+		path.Join(SystemConfigDir, "20-ignored.txt"): `{"maxStepUp": 100}`,
+		userPath: `{"curve": "quiet", "mqtt": {"username": "fan"}, "minChange": 1}`,
+	}
+	os.MkdirAll(SystemConfigDir, 0755)
+	for filePath, data := range files {
+		os.WriteFile(filePath, []byte(data), 0644)
+	}
+
+	config, warnings, err := Load(userPath)
+	if err != nil || len(warnings) > 0 {
+		t.Fatalf("Load() error = %v, warnings = %v", err, warnings)
+	}
+
+	origins := map[string]string{
+		"minChange":       userPath,
+		"maxStepUp":       path.Join(SystemConfigDir, "10-curves.json"),
+		"mqtt.broker":     SystemConfigPath,
+		"mqtt.username":   userPath,
+		"mqtt.clientId":   OriginDefault,
+		"curves.quiet":    path.Join(SystemConfigDir, "10-curves.json"),
+		"curves.default":  OriginDefault,
+		"checkIntervalMs": OriginDefault,
+	}
+	for valuePath, want := range origins {
+		if got := config.Origin(valuePath); got != want {
+			t.Errorf("Origin(%s) = %s, want %s", valuePath, got, want)
+		}
+	}
+	if config.MinChange != 1 || config.MaxStepUp != 6 || config.MQTT.Broker != "tcp://nas:1883" || config.MQTT.Username != "fan" {
+		t.Errorf("Load() = minChange %f, maxStepUp %f, mqtt %+v", config.MinChange, config.MaxStepUp, config.MQTT)
+	}
+
+	output := &strings.Builder{}
+	config.PrintEffective(output)
+	if !strings.Contains(output.String(), "mqtt.broker") || !strings.Contains(output.String(), SystemConfigPath) {
+		t.Errorf("PrintEffective() = %s", output.String())
+	}
+}
+
+func TestLoad_unknownKeys(t *testing.T) {
+	useSystemConfig(t)
+	os.WriteFile(SystemConfigPath, []byte(`{"checkInterval": 1000}`), 0644)
+
+	config, _, _ := Load("")
+	err := config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "checkInterval: unknown key in "+SystemConfigPath) {
+		t.Errorf("Validate() error = %v, want unknown key in %s", err, SystemConfigPath)
+	}
+}
+
+func TestConfiguration_Show(t *testing.T) {
+	useSystemConfig(t)
+	os.WriteFile(SystemConfigPath, []byte(`{"mqtt": {"broker": "tcp://localhost:1883", "password": "secret"}, "checkIntervalMs": 2000}`), 0644)
+
+	config, _, err := Load("", Layer{Origin: OriginCLI, Data: []byte(`{"checkIntervalMs": 500}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shown := string(config.Show())
+	if strings.Contains(shown, "secret") || !strings.Contains(shown, `"password": "********"`) {
+		t.Errorf("Show() = %s, want the password masked", shown)
+	}
+	if !strings.Contains(shown, `"checkIntervalMs": 500`) {
+		t.Errorf("Show() = %s, want the CLI override", shown)
+	}
+
+	output := &strings.Builder{}
+	config.PrintEffective(output)
+	if strings.Contains(output.String(), "secret") {
+		t.Errorf("PrintEffective() = %s, want the password masked", output.String())
+	}
+	if !strings.Contains(output.String(), "checkIntervalMs") || !strings.Contains(output.String(), "cli") {
+		t.Errorf("PrintEffective() = %s, want checkIntervalMs from cli", output.String())
+	}
+}
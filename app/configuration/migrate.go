@@ -0,0 +1,123 @@
+package configuration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CurrentVersion of the configuration schema
+const CurrentVersion = 2
+
+// migration upgrades a configuration from one version to the next
+type migration struct {
+	from        int
+	description string
+	// migrate changes the layout and returns whether anything had to be changed
+	migrate func(config map[string]any) bool
+}
+
+// migrations in the order they are applied. Added keys (like maxStepUp in v0.4) do not need a migration, only
+// changed layouts.
+var migrations = []migration{
+	// Before v0.3 there was a single curve, given as array in "curve"
+	{from: 1, description: "the curve was moved to curves.default", migrate: migrateSingleCurve},
+}
+
+// Migrate upgrades the JSON configuration to the current schema version. Files without version are detected by
+// their layout. The descriptions of the migrations that changed the configuration are returned, if there are none
+// only the version is raised.
+func Migrate(data []byte) ([]byte, []string, error) {
+	var config map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// Keep integers as integers
+	decoder.UseNumber()
+	if decoder.Decode(&config) != nil {
+		// Reported when the data is parsed
+		return data, nil, nil
+	}
+
+	version := detectVersion(config)
+	if version < 1 || version >= CurrentVersion {
+		// Newer or invalid versions are reported by Validate
+		return data, nil, nil
+	}
+
+	applied := make([]string, 0)
+	for _, m := range migrations {
+		if m.from < version {
+			continue
+		}
+		if m.migrate(config) {
+			applied = append(applied, fmt.Sprintf("version %d to %d: %s", m.from, m.from+1, m.description))
+		}
+	}
+	setKey(config, "version", CurrentVersion)
+
+	data, err := json.Marshal(normalizeNumbers(config))
+	return data, applied, err
+}
+
+// detectVersion returns the "version" of the configuration or determines it by the layout
+func detectVersion(config map[string]any) int {
+	if value, ok := getKey(config, "version"); ok {
+		number, ok := value.(json.Number)
+		if !ok {
+			return 0
+		}
+		version, err := number.Int64()
+		if err != nil {
+			return 0
+		}
+		return int(version)
+	}
+
+	if curve, ok := getKey(config, "curve"); ok {
+		if _, ok := curve.([]any); ok {
+			return 1
+		}
+	}
+	return CurrentVersion
+}
+
+func migrateSingleCurve(config map[string]any) bool {
+	curve, ok := getKey(config, "curve")
+	if !ok {
+		return false
+	}
+	entries, ok := curve.([]any)
+	if !ok {
+		return false
+	}
+
+	curves, ok := getKey(config, "curves")
+	curveMap, isMap := curves.(map[string]any)
+	if !ok || !isMap {
+		curveMap = make(map[string]any)
+	}
+	curveMap["default"] = entries
+	setKey(config, "curves", curveMap)
+	setKey(config, "curve", "default")
+	return true
+}
+
+// getKey returns the value of a key, matched case-insensitively like encoding/json does
+func getKey(config map[string]any, key string) (any, bool) {
+	for k, value := range config {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// setKey replaces all spellings of the key
+func setKey(config map[string]any, key string, value any) {
+	for k := range config {
+		if strings.EqualFold(k, key) {
+			delete(config, k)
+		}
+	}
+	config[key] = value
+}
@@ -0,0 +1,88 @@
+package configuration
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name           string
+		data           string
+		wantMigrations int
+		wantCurve      string
+		wantCurves     []string
+		wantInvalid    string
+	}{
+		{
+			name:       "Current without version",
+			data:       `{"curves": {"quiet": [{"Temp": 40, "Speed": 0}]}, "curve": "quiet"}`,
+			wantCurve:  "quiet",
+			wantCurves: []string{"default", "quiet"},
+		},
+		{
+			name:           "Single curve without version",
+			data:           `{"checkIntervalMs": 1000, "curve": [{"Temp": 50, "Speed": 0.1}, {"Temp": 90, "Speed": 1}]}`,
+			wantMigrations: 1,
+			wantCurve:      "default",
+			wantCurves:     []string{"default"},
+		},
+		{
+			name:           "Single curve with version",
+			data:           `{"version": 1, "Curve": [{"Temp": 50, "Speed": 0.1}], "curves": {"loud": [{"Temp": 50, "Speed": 1}]}}`,
+			wantMigrations: 1,
+			wantCurve:      "default",
+			wantCurves:     []string{"default", "loud"},
+		},
+		{
+			name:       "Old version with current layout",
+			data:       `{"version": 1, "curves": {"quiet": [{"Temp": 40, "Speed": 0}]}, "curve": "quiet"}`,
+			wantCurve:  "quiet",
+			wantCurves: []string{"default", "quiet"},
+		},
+		{
+			name:        "Newer version",
+			data:        `{"version": 3}`,
+			wantInvalid: "version: 3 is newer than version 2 supported by this fanmi",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseLayers([]Layer{{Data: []byte(tt.data)}})
+			if err != nil {
+				t.Fatalf("ParseLayers() error = %s", err.Error())
+			}
+
+			err = config.Validate()
+			if tt.wantInvalid != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantInvalid) {
+					t.Fatalf("Validate() error = %v, want %s", err, tt.wantInvalid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %s", err.Error())
+			}
+
+			if len(config.Migrations()) != tt.wantMigrations {
+				t.Errorf("Migrations() = %v, want %d", config.Migrations(), tt.wantMigrations)
+			}
+			if config.Version != CurrentVersion {
+				t.Errorf("Version = %d, want %d", config.Version, CurrentVersion)
+			}
+			if config.CurrentCurve != tt.wantCurve {
+				t.Errorf("CurrentCurve = %s, want %s", config.CurrentCurve, tt.wantCurve)
+			}
+			names := make([]string, 0, len(config.Curves))
+			for name := range config.Curves {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.wantCurves) {
+				t.Errorf("Curves = %v, want %v", names, tt.wantCurves)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package configuration
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sirion/fanmi/app/units"
+)
+
+// Origin of values set via FANMI_* environment variables
+const OriginEnv = "env"
+
+// Name of the curve defined with -curve-points or FANMI_CURVE_POINTS
+const CustomCurveName = "custom"
+
+// override is a configuration value that can be set via CLI option and environment variable
+type override struct {
+	key    string
+	flag   string
+	env    string
+	usage  string
+	number bool
+	// Value given on the command line, nil if not set
+	cli *string
+}
+
+var overrides = []*override{
+	{key: "checkIntervalMs", flag: "checkIntervalMs", env: "FANMI_CHECK_INTERVAL_MS", number: true, usage: "How often to measure (and update) fan speed in `milliseconds`"},
+	{key: "minChange", flag: "minChange", env: "FANMI_MIN_CHANGE", number: true, usage: "Minimal temperature change in `°C` before a different speed is set"},
+	{key: "maxStepUp", flag: "maxStepUp", env: "FANMI_MAX_STEP_UP", number: true, usage: "Maximal upwards change of the fan speed per check in `%`"},
+	{key: "maxStepDown", flag: "maxStepDown", env: "FANMI_MAX_STEP_DOWN", number: true, usage: "Maximal downwards change of the fan speed per check in `%`"},
+	{key: "powerMode", flag: "powerMode", env: "FANMI_POWER_MODE", usage: "Power `mode` of the graphics card"},
+	{key: "powerProfileMode", flag: "powerProfileMode", env: "FANMI_POWER_PROFILE_MODE", usage: "Entry of pp_power_profile_mode by `name`, e.g. \"COMPUTE\""},
+	{key: "curve", flag: "curve", env: "FANMI_CURVE", usage: "`name` of the curve active at start-up"},
+	{key: "profile", flag: "profile", env: "FANMI_PROFILE", usage: "`name` of the profile applied to all cards at start-up"},
+	{key: "powerCap", flag: "powerCap", env: "FANMI_POWER_CAP", number: true, usage: "Power limit of all cards in `W`, 0 keeps the limit of the driver"},
+	{key: "curves", flag: "curve-points", env: "FANMI_CURVE_POINTS", usage: "Inline curve of `temp:speed` points, speeds in % or with \"rpm\" suffix, e.g. \"40:0,60:20,80:50,90:100\""},
+}
+
+// overrideFlag stores the value of an override given on the command line
+type overrideFlag struct {
+	override *override
+}
+
+func (f *overrideFlag) String() string {
+	if f.override == nil || f.override.cli == nil {
+		return ""
+	}
+	return *f.override.cli
+}
+
+func (f *overrideFlag) Set(value string) error {
+	_, err := f.override.parse(value)
+	if err != nil {
+		return err
+	}
+	f.override.cli = &value
+	return nil
+}
+
+// RegisterOverrides adds a CLI option for every override, OverrideLayers returns their values
+func RegisterOverrides(flags *flag.FlagSet) {
+	for _, o := range overrides {
+		flags.Var(&overrideFlag{o}, o.flag, o.usage)
+	}
+}
+
+// showOverrideDefaults sets the effective value and its origin as default of the CLI options, so the help shows
+// them
+func showOverrideDefaults(flags *flag.FlagSet, config *Configuration) {
+	data, _ := json.Marshal(config)
+	values := flatten(data, "")
+
+	for _, o := range overrides {
+		f := flags.Lookup(o.flag)
+		if o.key == "curves" {
+			f.Usage = fmt.Sprintf("%s, or %s", o.usage, o.env)
+			continue
+		}
+		f.DefValue = strings.Trim(values[o.key], `"`)
+		f.Usage = fmt.Sprintf("%s, or %s (set by %s)", o.usage, o.env, config.Origin(o.key))
+	}
+}
+
+// OverrideLayers returns the layers for the values set via environment variables and CLI options
+func OverrideLayers(telemetry TelemetryConfiguration) ([]Layer, error) {
+	envValues := make(map[string]string)
+	cliValues := make(map[string]string)
+	for _, o := range overrides {
+		if value := os.Getenv(o.env); value != "" {
+			envValues[o.flag] = value
+		}
+		if o.cli != nil {
+			cliValues[o.flag] = *o.cli
+		}
+	}
+
+	env, err := overrideLayer(OriginEnv, envValues, TelemetryConfiguration{})
+	if err != nil {
+		return nil, err
+	}
+	cli, err := overrideLayer(OriginCLI, cliValues, telemetry)
+	if err != nil {
+		return nil, err
+	}
+	return []Layer{env, cli}, nil
+}
+
+// overrideLayer converts the values by flag name into a JSON layer
+func overrideLayer(origin string, values map[string]string, telemetry TelemetryConfiguration) (Layer, error) {
+	layer := make(map[string]any)
+	for _, o := range overrides {
+		value, ok := values[o.flag]
+		if !ok {
+			continue
+		}
+		parsed, err := o.parse(value)
+		if err != nil {
+			if origin == OriginEnv {
+				return Layer{}, fmt.Errorf("%s: %s", o.env, err.Error())
+			}
+			return Layer{}, fmt.Errorf("-%s: %s", o.flag, err.Error())
+		}
+		layer[o.key] = parsed
+	}
+
+	// The inline curve is used unless another one is selected explicitly
+	if _, ok := layer["curves"]; ok {
+		if _, ok := layer["curve"]; !ok {
+			layer["curve"] = CustomCurveName
+		}
+	}
+
+	telemetryValues := make(map[string]any)
+	if telemetry.File != "" {
+		telemetryValues["file"] = telemetry.File
+	}
+	if telemetry.Format != "" {
+		telemetryValues["format"] = telemetry.Format
+	}
+	if telemetry.MaxSize != 0 {
+		telemetryValues["maxSize"] = telemetry.MaxSize
+	}
+	if len(telemetryValues) > 0 {
+		layer["telemetry"] = telemetryValues
+	}
+
+	data, err := json.Marshal(layer)
+	return Layer{Origin: origin, Data: data}, err
+}
+
+// parse converts the text value into the JSON value of the configuration
+func (o *override) parse(value string) (any, error) {
+	if o.key == "curves" {
+		curve, err := ParseCurvePoints(value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]Values{CustomCurveName: curve}, nil
+	}
+	if o.number {
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a number", value)
+		}
+		return number, nil
+	}
+	return value, nil
+}
+
+// ParseCurvePoints reads a curve in the form "40:0,60:20,80:50,90:100" with speeds in percent, or in revolutions
+// per minute with "rpm" suffix ("40:0rpm,80:2000rpm")
+func ParseCurvePoints(points string) (Values, error) {
+	curve := make(Values, 0)
+	for _, point := range strings.Split(points, ",") {
+		temp, speed, ok := strings.Cut(strings.TrimSpace(point), ":")
+		if !ok {
+			return nil, fmt.Errorf("curve point '%s' is not in the form temp:speed", point)
+		}
+
+		t, err := strconv.ParseFloat(strings.TrimSpace(temp), 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid temperature in curve point '%s'", point)
+		}
+		entry := Entry{Temp: float32(t)}
+
+		speed = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(speed), "%"))
+		if rpm, ok := strings.CutSuffix(speed, "rpm"); ok {
+			entry.RPM, err = strconv.Atoi(strings.TrimSpace(rpm))
+			entry.InRPM = true
+		} else {
+			var s float64
+			s, err = strconv.ParseFloat(speed, 32)
+			entry.Speed = units.FromPercent(float32(s))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid speed in curve point '%s'", point)
+		}
+
+		curve = append(curve, entry)
+	}
+	return curve, nil
+}
@@ -0,0 +1,174 @@
+package configuration
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCurvePoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		points  string
+		want    Values
+		wantErr string
+	}{
+		{
+			name:   "Percent",
+			points: "40:0,60:20,80:50,90:100",
+			want:   Values{{Temp: 40, Speed: 0}, {Temp: 60, Speed: 0.2}, {Temp: 80, Speed: 0.5}, {Temp: 90, Speed: 1}},
+		},
+		{
+			name:   "Spaces and percent signs",
+			points: " 40 : 10% , 70:45.5% ",
+			want:   Values{{Temp: 40, Speed: 0.1}, {Temp: 70, Speed: 0.455}},
+		},
+		{
+			name:   "RPM",
+			points: "40:800rpm,80:2000rpm",
+			want:   Values{{Temp: 40, RPM: 800, InRPM: true}, {Temp: 80, RPM: 2000, InRPM: true}},
+		},
+		{
+			name:   "RPM from 0",
+			points: "40:0rpm,80:2000rpm",
+			want:   Values{{Temp: 40, RPM: 0, InRPM: true}, {Temp: 80, RPM: 2000, InRPM: true}},
+		},
+		{
+			name:    "Missing speed",
+			points:  "40:0,60",
+			wantErr: "curve point '60' is not in the form temp:speed",
+		},
+		{
+			name:    "Invalid speed",
+			points:  "40:fast",
+			wantErr: "invalid speed in curve point '40:fast'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCurvePoints(tt.points)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ParseCurvePoints() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCurvePoints() error = %s", err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCurvePoints() = %v, want %v", got, tt.want)
+			}
+			if errs := ValidateCurve("custom", got); len(errs) > 0 {
+				t.Errorf("ValidateCurve() = %s", errs.Error())
+			}
+		})
+	}
+}
+
+func TestOverrideLayers(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		cli          map[string]string
+		wantErr      string
+		wantInvalid  string
+		wantInterval uint32
+		wantCurve    string
+		wantOrigins  map[string]string
+	}{
+		{
+			name:         "Environment",
+			env:          map[string]string{"FANMI_CHECK_INTERVAL_MS": "500", "FANMI_POWER_MODE": "low"},
+			wantInterval: 500,
+			wantCurve:    "",
+			wantOrigins:  map[string]string{"checkIntervalMs": OriginEnv, "powerMode": OriginEnv, "minChange": OriginDefault},
+		},
+		{
+			name:         "CLI takes precedence",
+			env:          map[string]string{"FANMI_CHECK_INTERVAL_MS": "500", "FANMI_MIN_CHANGE": "2"},
+			cli:          map[string]string{"checkIntervalMs": "250"},
+			wantInterval: 250,
+			wantOrigins:  map[string]string{"checkIntervalMs": OriginCLI, "minChange": OriginEnv},
+		},
+		{
+			name:         "Inline curve is selected",
+			cli:          map[string]string{"curve-points": "40:0,60:20,80:50,90:100"},
+			wantInterval: 3000,
+			wantCurve:    CustomCurveName,
+			wantOrigins:  map[string]string{"curve": OriginCLI, "curves.custom": OriginCLI},
+		},
+		{
+			name:         "Explicit curve wins over inline curve",
+			env:          map[string]string{"FANMI_CURVE_POINTS": "40:0,90:100"},
+			cli:          map[string]string{"curve": "default"},
+			wantInterval: 3000,
+			wantCurve:    "default",
+			wantOrigins:  map[string]string{"curve": OriginCLI, "curves.custom": OriginEnv},
+		},
+		{
+			name:    "Invalid number",
+			env:     map[string]string{"FANMI_MAX_STEP_UP": "much"},
+			wantErr: "FANMI_MAX_STEP_UP: 'much' is not a number",
+		},
+		{
+			name:        "Validated like the file",
+			cli:         map[string]string{"checkIntervalMs": "10", "curve-points": "40:0,90:150"},
+			wantInvalid: "checkIntervalMs: 10 < 100",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, o := range overrides {
+				t.Setenv(o.env, tt.env[o.env])
+				o.cli = nil
+				if value, ok := tt.cli[o.flag]; ok {
+					o.cli = &value
+				}
+			}
+			t.Cleanup(func() {
+				for _, o := range overrides {
+					o.cli = nil
+				}
+			})
+
+			layers, err := OverrideLayers(TelemetryConfiguration{})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("OverrideLayers() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OverrideLayers() error = %s", err.Error())
+			}
+
+			config, err := ParseLayers(layers)
+			if err != nil {
+				t.Fatalf("ParseLayers() error = %s", err.Error())
+			}
+			err = config.Validate()
+			if tt.wantInvalid != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantInvalid) {
+					t.Fatalf("Validate() error = %v, want %s", err, tt.wantInvalid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %s", err.Error())
+			}
+
+			if config.CheckIntervalMs != tt.wantInterval {
+				t.Errorf("CheckIntervalMs = %d, want %d", config.CheckIntervalMs, tt.wantInterval)
+			}
+			if config.CurrentCurve != tt.wantCurve {
+				t.Errorf("CurrentCurve = %s, want %s", config.CurrentCurve, tt.wantCurve)
+			}
+			for path, want := range tt.wantOrigins {
+				if got := config.Origin(path); got != want {
+					t.Errorf("Origin(%s) = %s, want %s", path, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package configuration
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirion/fanmi/app/debug"
+)
+
+// Editors often write a file in several steps, wait for them to finish before reading it
+const reloadDelay = 500 * time.Millisecond
+
+// Reload reads the configuration file again and swaps curves, limits and the check interval into the running
+// configuration. If the new configuration is invalid, the current one stays active and the problems are returned.
+func (c *Configuration) Reload() error {
+	layers, warnings := readLayers(c.filePath)
+	if len(warnings) > 0 {
+		return warnings[0]
+	}
+
+	// Environment and CLI options still take precedence over the files
+	config, err := ParseLayers(append(layers, c.overrides...))
+	if err != nil {
+		return fmt.Errorf("error parsing config file: %s", err.Error())
+	}
+	err = config.Validate()
+	if err != nil {
+		return err
+	}
+	for name := range config.Curves {
+		sort.Sort(config.Curves[name])
+	}
+	config.applyProfile()
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.CheckIntervalMs = config.CheckIntervalMs
+	c.MinChange = config.MinChange
+	c.MaxStepUp = config.MaxStepUp
+	c.MaxStepDown = config.MaxStepDown
+	c.FailsafeTemp = config.FailsafeTemp
+	c.Curves = config.Curves
+	c.Devices = config.Devices
+	c.PowerCap = config.PowerCap
+	c.PowerProfileMode = config.PowerProfileMode
+	c.Profiles = config.Profiles
+	c.Profile = config.Profile
+	c.Notifications = config.Notifications
+	c.Console = config.Console
+	c.updateCurveNames()
+	c.origins = config.origins
+
+	// Keep the curve selected by the user if it still exists
+	curveName := c.CurrentCurve
+	if _, ok := c.Curves[curveName]; !ok {
+		curveName = config.CurrentCurve
+	}
+	if curveName == "" {
+		curveName = c.CurveNames[0]
+	}
+	c.setCurve(curveName)
+
+	debug.Logger.Info("configuration reloaded", "file", c.filePath)
+	return nil
+}
+
+// Watch reloads the configuration whenever one of the configuration files changes. The result of every reload is
+// passed to the done function. The directories are watched, so editors replacing a file are noticed as well.
+func (c *Configuration) Watch(done func(error)) (stop func(), err error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// The system-wide directories are optional
+	watcher.Add(path.Dir(SystemConfigPath))
+	watcher.Add(SystemConfigDir)
+	if c.filePath != "" {
+		err = watcher.Add(path.Dir(c.filePath))
+		if err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("error watching %s: %s", path.Dir(c.filePath), err.Error())
+		}
+	}
+
+	go (func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !c.isConfigFile(event.Name) || event.Op == fsnotify.Chmod {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDelay, func() {
+					done(c.Reload())
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				debug.Logger.Warn("error watching configuration file", "file", c.filePath, "error", err.Error())
+			}
+		}
+	})()
+
+	return func() { watcher.Close() }, nil
+}
+
+// isConfigFile checks whether the file is one of the configuration layers
+func (c *Configuration) isConfigFile(filePath string) bool {
+	filePath = path.Clean(filePath)
+	return filePath == path.Clean(SystemConfigPath) ||
+		(path.Dir(filePath) == path.Clean(SystemConfigDir) && slices.Contains(configExtensions, path.Ext(filePath))) ||
+		(c.filePath != "" && filePath == path.Clean(c.filePath))
+}
@@ -0,0 +1,54 @@
+package configuration
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestConfiguration_Reload(t *testing.T) {
+	useSystemConfig(t)
+	filePath := path.Join(t.TempDir(), "config.json")
+	write := func(data string) {
+		err := os.WriteFile(filePath, []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"curve": "quiet", "curves": {"quiet": [{"Temp": 50, "Speed": 0.2}], "loud": [{"Temp": 50, "Speed": 0.8}]}}`)
+	config, _ := Parse([]byte(`{}`))
+	config.filePath = filePath
+	config.PowerMode = "low"
+	err := config.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %s", err.Error())
+	}
+	if config.CurrentCurve != "quiet" || config.Curve[0].Speed != 0.2 || len(config.CurveNames) != 3 {
+		t.Errorf("Reload() curve = %s %v (%v)", config.CurrentCurve, config.Curve, config.CurveNames)
+	}
+
+	// The curve selected by the user is kept, new limits are used
+	config.SetCurve("loud")
+	write(`{"checkIntervalMs": 1000, "maxStepUp": 10, "curve": "quiet", "curves": {"quiet": [{"Temp": 50, "Speed": 0.3}], "loud": [{"Temp": 60, "Speed": 1}, {"Temp": 40, "Speed": 0.5}]}}`)
+	err = config.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %s", err.Error())
+	}
+	if config.CurrentCurve != "loud" || config.Curve[0].Temp != 40 || config.CheckIntervalMs != 1000 || config.MaxStepUp != 10 {
+		t.Errorf("Reload() = %s %v interval %d step %f", config.CurrentCurve, config.Curve, config.CheckIntervalMs, config.MaxStepUp)
+	}
+	if config.PowerMode != "low" {
+		t.Errorf("Reload() changed power mode to %s", config.PowerMode)
+	}
+
+	// Invalid configurations are rejected
+	write(`{"checkIntervalMs": 10, "curves": {"loud": [{"Temp": 60, "Speed": 2}]}}`)
+	err = config.Reload()
+	if err == nil {
+		t.Fatalf("Reload() returned no error for invalid configuration")
+	}
+	if config.CheckIntervalMs != 1000 || config.Curve[1].Speed != 1 {
+		t.Errorf("Reload() changed configuration despite error: interval %d curve %v", config.CheckIntervalMs, config.Curve)
+	}
+}
@@ -0,0 +1,314 @@
+package configuration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+
+	"github.com/sirion/fanmi/app/debug"
+)
+
+// Properties that can be changed while fanmi is running and are written by Save
+var savedKeys = []string{"version", "checkIntervalMs", "minChange", "maxStepUp", "maxStepDown", "powerMode", "curve", "curves", "devices", "autoSave", "minimizeToTray"}
+
+type property struct {
+	key   string
+	value json.RawMessage
+}
+
+// Save writes the settings that can be changed at runtime back to the configuration file. Unknown properties and
+// the order of the existing properties are kept, properties not in the file are only added if they differ from
+// the system-wide configuration. Values set via CLI options or environment variables only apply to this run and
+// are not saved. Nothing is written if fanmi would not start with the result. The file is replaced atomically and
+// belongs to the user who started fanmi.
+func (c *Configuration) Save() error {
+	if c.filePath == "" {
+		return fmt.Errorf("no configuration file to save to")
+	}
+
+	data, err := os.ReadFile(c.filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading config file: %s", err.Error())
+	}
+	original := data
+	// Comments in YAML and TOML files are lost
+	format := FormatOf(c.filePath)
+	data, err = ToJSON(data, format)
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %s", c.filePath, err.Error())
+	}
+	_, migrations, _ := Migrate(data)
+
+	// Values not in the user file only need to be saved if they differ from the system-wide configuration
+	layers, _ := readLayers("")
+	base, err := ParseLayers(layers)
+	if err != nil {
+		base = defaults()
+	}
+
+	data, err = c.merge(data, base)
+	if err == nil {
+		err = validateSaved(layers, c.filePath, data)
+	}
+	if err == nil {
+		data, err = FromJSON(data, format)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving config file %s: %s", c.filePath, err.Error())
+	}
+
+	if len(migrations) > 0 {
+		// The file is written in the current layout, the older one is kept
+		err = backup(c.filePath, original)
+		if err != nil {
+			return fmt.Errorf("error saving backup of config file %s: %s", c.filePath, err.Error())
+		}
+	}
+	err = writeFileAtomic(c.filePath, data)
+	if err != nil {
+		return fmt.Errorf("error saving config file %s: %s", c.filePath, err.Error())
+	}
+
+	debug.Logger.Info("configuration saved", "file", c.filePath)
+	return nil
+}
+
+// merge replaces the saved properties in the original file content
+func (c *Configuration) merge(original []byte, base *Configuration) ([]byte, error) {
+	properties, err := readProperties(original)
+	if err != nil {
+		return nil, err
+	}
+
+	c.RLock()
+	current, err := json.Marshal(c)
+	c.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	// A single curve is selected on start-up, so it does not need to be saved
+	if base.CurrentCurve == "" && len(base.Curves) == 1 {
+		for name := range base.Curves {
+			base.CurrentCurve = name
+		}
+	}
+	baseData, err := json.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+
+	currentValues := make(map[string]json.RawMessage)
+	baseValues := make(map[string]json.RawMessage)
+	json.Unmarshal(current, &currentValues)
+	json.Unmarshal(baseData, &baseValues)
+
+	for _, key := range savedKeys {
+		if c.isOverride(key) {
+			continue
+		}
+		found := false
+		for i := range properties {
+			if properties[i].key == key {
+				properties[i].value = c.withoutOverrides(key, currentValues[key], properties[i].value)
+				found = true
+			}
+		}
+		value := c.withoutOverrides(key, currentValues[key], nil)
+		if !found && !bytes.Equal(value, baseValues[key]) {
+			properties = append(properties, property{key, value})
+		}
+	}
+
+	indent := detectIndent(original)
+	buffer := &bytes.Buffer{}
+	buffer.WriteString("{\n")
+	for i, p := range properties {
+		buffer.WriteString(indent + strconv.Quote(p.key) + ": ")
+		err = json.Indent(buffer, p.value, indent, indent)
+		if err != nil {
+			return nil, err
+		}
+		if i < len(properties)-1 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString("\n")
+	}
+	buffer.WriteString("}\n")
+
+	return buffer.Bytes(), nil
+}
+
+// backup writes the original content of a file to the file with suffix ".bak", an existing backup is kept
+func backup(filePath string, data []byte) error {
+	backupPath := filePath + ".bak"
+	if _, err := os.Stat(backupPath); err == nil {
+		return nil
+	}
+	debug.Logger.Info("configuration migrated, keeping the old file", "file", backupPath)
+	return writeFileAtomic(backupPath, data)
+}
+
+// isOverride checks whether the value at the path was set via CLI option or environment variable
+func (c *Configuration) isOverride(valuePath string) bool {
+	origin := c.Origin(valuePath)
+	return origin == OriginCLI || origin == OriginEnv
+}
+
+// withoutOverrides replaces the properties of an object that were set via CLI option or environment variable
+// (e.g. the curve of -curve-points) with their original value, or removes them if they were not in the file
+func (c *Configuration) withoutOverrides(key string, value json.RawMessage, original json.RawMessage) json.RawMessage {
+	object := make(map[string]json.RawMessage)
+	if json.Unmarshal(value, &object) != nil {
+		return value
+	}
+	originalObject := make(map[string]json.RawMessage)
+	json.Unmarshal(original, &originalObject)
+
+	changed := false
+	for name := range object {
+		if !c.isOverride(key + "." + name) {
+			continue
+		}
+		changed = true
+		if originalValue, ok := originalObject[name]; ok {
+			object[name] = originalValue
+		} else {
+			delete(object, name)
+		}
+	}
+	if !changed {
+		return value
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return value
+	}
+	return data
+}
+
+// validateSaved checks the configuration that results from the saved file on top of the system-wide layers, so a
+// value fanmi would exit on (like a too short interval) is never written
+func validateSaved(layers []Layer, filePath string, data []byte) error {
+	config, err := ParseLayers(append(slices.Clone(layers), Layer{Origin: filePath, Data: data}))
+	if err == nil {
+		// Unknown keys are kept as they are in the file, they are not introduced by saving
+		config.unknownKeys = nil
+		err = config.Validate()
+	}
+	if err != nil {
+		return fmt.Errorf("not saving invalid configuration:\n%s", err.Error())
+	}
+	return nil
+}
+
+// readProperties returns the top level properties of a JSON object in their original order
+func readProperties(data []byte) ([]property, error) {
+	properties := make([]property, 0)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return properties, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token != json.Delim('{') {
+		return nil, fmt.Errorf("configuration is not a JSON object")
+	}
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		p := property{key: token.(string)}
+		err = decoder.Decode(&p.value)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, p)
+	}
+	return properties, nil
+}
+
+// detectIndent returns the indentation of the first property, four spaces if there is none
+func detectIndent(data []byte) string {
+	lines := bytes.Split(data, []byte("\n"))
+	for _, line := range lines[1:] {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) > 0 && len(trimmed) < len(line) {
+			return string(line[:len(line)-len(trimmed)])
+		}
+	}
+	return "    "
+}
+
+// writeFileAtomic writes the data to a temporary file and renames it, so readers never see a partial file
+func writeFileAtomic(filePath string, data []byte) error {
+	uid, gid := invokingUser()
+	mode := os.FileMode(0644)
+	if stat, err := os.Stat(filePath); err == nil {
+		mode = stat.Mode().Perm()
+	}
+
+	dirPath := path.Dir(filePath)
+	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
+		err = os.MkdirAll(dirPath, 0755)
+		if err != nil {
+			return err
+		}
+		chown(dirPath, uid, gid)
+	}
+
+	file, err := os.CreateTemp(dirPath, "."+path.Base(filePath)+".*")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+	defer os.Remove(tempPath)
+
+	_, err = io.Copy(file, bytes.NewReader(data))
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tempPath, mode)
+	if err != nil {
+		return err
+	}
+	err = chown(tempPath, uid, gid)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempPath, filePath)
+}
+
+// invokingUser returns the real user and group, when started via sudo the ones of the user calling sudo
+func invokingUser() (int, int) {
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		sudoUID, errUID := strconv.Atoi(os.Getenv("SUDO_UID"))
+		sudoGID, errGID := strconv.Atoi(os.Getenv("SUDO_GID"))
+		if errUID == nil && errGID == nil {
+			uid, gid = sudoUID, sudoGID
+		}
+	}
+	return uid, gid
+}
+
+// chown changes the owner if running as root (e.g. SUID), otherwise files belong to the user anyway
+func chown(filePath string, uid, gid int) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	return os.Chown(filePath, uid, gid)
+}
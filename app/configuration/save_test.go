@@ -0,0 +1,154 @@
+package configuration
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestConfiguration_Save(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		want     string
+	}{
+		{
+			name:     "New file",
+			original: "",
+			want:     "{\n    \"checkIntervalMs\": 1000,\n    \"powerMode\": \"low\"\n}\n",
+		},
+		{
+			name:     "Keep order, unknown keys and indentation",
+			original: "{\n\t\"powerMode\": \"high\",\n\t\"comment\": \"my settings\",\n\t\"minChange\": 2\n}\n",
+			want:     "{\n\t\"powerMode\": \"low\",\n\t\"comment\": \"my settings\",\n\t\"minChange\": 2,\n\t\"checkIntervalMs\": 1000\n}\n",
+		},
+		{
+			name:     "Nested values",
+			original: "{\n  \"mqtt\": {\"broker\": \"tcp://localhost:1883\"},\n  \"checkIntervalMs\": 3000\n}",
+			want:     "{\n  \"mqtt\": {\n    \"broker\": \"tcp://localhost:1883\"\n  },\n  \"checkIntervalMs\": 1000,\n  \"powerMode\": \"low\"\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSystemConfig(t)
+			filePath := path.Join(t.TempDir(), "fanmi", "config.json")
+			data := []byte("{}")
+			if tt.original != "" {
+				data = []byte(tt.original)
+				os.MkdirAll(path.Dir(filePath), 0755)
+				os.WriteFile(filePath, data, 0600)
+			}
+
+			config, _ := Parse(data)
+			config.filePath = filePath
+			config.CheckIntervalMs = 1000
+			config.PowerMode = "low"
+			config.prepareCurves()
+
+			err := config.Save()
+			if err != nil {
+				t.Fatalf("Save() error = %s", err.Error())
+			}
+			data, err = os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Save() wrote\n%s\nwant\n%s", data, tt.want)
+			}
+			if tt.original != "" {
+				stat, _ := os.Stat(filePath)
+				if stat.Mode().Perm() != 0600 {
+					t.Errorf("Save() changed file mode to %s", stat.Mode().Perm())
+				}
+			}
+		})
+	}
+}
+
+func TestConfiguration_Save_overrides(t *testing.T) {
+	useSystemConfig(t)
+	filePath := path.Join(t.TempDir(), "config.json")
+	original := "{\n    \"checkIntervalMs\": 2000\n}\n"
+	os.WriteFile(filePath, []byte(original), 0644)
+
+	config, err := ParseLayers([]Layer{
+		{Origin: filePath, Data: []byte(original)},
+		{Origin: OriginCLI, Data: []byte(`{"checkIntervalMs": 500, "curve": "custom", "curves": {"custom": [{"temp": 40, "rpm": 0}, {"temp": 80, "rpm": 2000}]}}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.filePath = filePath
+	config.PowerMode = "low"
+	config.prepareCurves()
+
+	err = config.Save()
+	if err != nil {
+		t.Fatalf("Save() error = %s", err.Error())
+	}
+	data, _ := os.ReadFile(filePath)
+	want := "{\n    \"checkIntervalMs\": 2000,\n    \"powerMode\": \"low\"\n}\n"
+	if string(data) != want {
+		t.Errorf("Save() wrote\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestConfiguration_Save_invalid(t *testing.T) {
+	useSystemConfig(t)
+	filePath := path.Join(t.TempDir(), "config.json")
+	original := "{\n    \"checkIntervalMs\": 2000\n}\n"
+	os.WriteFile(filePath, []byte(original), 0644)
+
+	config, _ := Parse([]byte(original))
+	config.filePath = filePath
+	config.CheckIntervalMs = 1
+	config.prepareCurves()
+
+	err := config.Save()
+	if err == nil || !strings.Contains(err.Error(), "checkIntervalMs: 1 < 100") {
+		t.Errorf("Save() error = %v, want checkIntervalMs rejected", err)
+	}
+	data, _ := os.ReadFile(filePath)
+	if string(data) != original {
+		t.Errorf("Save() changed the file to\n%s", data)
+	}
+}
+
+func TestConfiguration_Save_migrated(t *testing.T) {
+	tests := []struct {
+		name       string
+		original   string
+		wantBackup bool
+	}{
+		{"Single curve", `{"curve": [{"Temp": 40, "Speed": 0.2}, {"Temp": 80, "Speed": 1}]}`, true},
+		{"Old version with current layout", `{"version": 1, "checkIntervalMs": 2000}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSystemConfig(t)
+			filePath := path.Join(t.TempDir(), "config.json")
+			os.WriteFile(filePath, []byte(tt.original), 0644)
+
+			config, err := ParseLayers([]Layer{{Origin: filePath, Data: []byte(tt.original)}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			config.filePath = filePath
+			config.prepareCurves()
+
+			err = config.Save()
+			if err != nil {
+				t.Fatalf("Save() error = %s", err.Error())
+			}
+			data, err := os.ReadFile(filePath + ".bak")
+			if tt.wantBackup && string(data) != tt.original {
+				t.Errorf("Save() backup = %q, want %q", data, tt.original)
+			}
+			if !tt.wantBackup && err == nil {
+				t.Errorf("Save() wrote a backup without migration")
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package configuration
+
+import (
+	"reflect"
+	"strings"
+)
+
+// schemaAnnotations are added to the generated schema of the value at the JSON path
+var schemaAnnotations = map[string]map[string]any{
+	"version":                       {"description": "Schema version of the configuration", "minimum": 1, "maximum": CurrentVersion},
+	"checkIntervalMs":               {"description": "How often to measure (and update) fan speed in milliseconds", "minimum": MinCheckIntervalMs},
+	"minChange":                     {"description": "Minimal temperature change in °C before a different speed is set", "minimum": 0},
+	"powerMode":                     {"description": "Power mode of the graphics card, cards that do not support the level report a write error", "enum": PowerLevels},
+	"powerProfileMode":              {"description": "Entry of pp_power_profile_mode by name (e.g. \"COMPUTE\"), empty to keep the mode of the driver", "pattern": "^[A-Z0-9_]*$"},
+	"maxStepUp":                     {"description": "Maximal upwards change of the fan speed in % per check", "exclusiveMinimum": 0, "maximum": 100},
+	"maxStepDown":                   {"description": "Maximal downwards change of the fan speed in % per check", "exclusiveMinimum": 0, "maximum": 100},
+	"failsafeTemp":                  {"description": "Temperature in °C at which the fan is set to full speed, 0 to disable", "minimum": 0},
+	"autoSave":                      {"description": "Save settings changed in the GUI immediately"},
+	"minimizeToTray":                {"description": "Hide the main window in the system tray when it is closed"},
+	"curves":                        {"description": "Fan curves by name"},
+	"curve":                         {"description": "Name of the curve active at start-up"},
+	"devices":                       {"description": "Settings of single devices by name (e.g. \"card1\")"},
+	"devices.*.curve":               {"description": "Name of the curve used instead of \"curve\""},
+	"devices.*.powerProfileMode":    {"description": "Entry of pp_power_profile_mode used instead of \"powerProfileMode\"", "pattern": "^[A-Z0-9_]*$"},
+	"devices.*.powerCap":            {"description": "Power limit in W used instead of \"powerCap\"", "minimum": 0},
+	"powerCap":                      {"description": "Power limit of all devices in W, 0 keeps the limit of the driver", "minimum": 0},
+	"profiles":                      {"description": "Combinations of curve and power limit by name (e.g. \"quiet\")"},
+	"profiles.*.curve":              {"description": "Name of the curve, \"curve\" if empty"},
+	"profiles.*.powerCap":           {"description": "Power limit in W, 0 keeps the limit of the driver", "minimum": 0},
+	"profile":                       {"description": "Name of the profile applied to all devices at start-up"},
+	"metrics":                       {"description": "Prometheus exporter"},
+	"api":                           {"description": "HTTP API and web dashboard"},
+	"mqtt":                          {"description": "MQTT broker"},
+	"log":                           {"description": "Log output"},
+	"log.level":                     {"enum": LogLevels},
+	"log.sink":                      {"enum": LogSinks},
+	"telemetry":                     {"description": "Telemetry file with one record per check cycle and device"},
+	"telemetry.format":              {"enum": TelemetryFormats},
+	"notifications":                 {"description": "Desktop notifications on thermal and fan events"},
+	"notifications.cooldown":        {"description": "Seconds before a rule notifies about the same device again", "minimum": 0},
+	"notifications.rateLimit":       {"description": "Maximal number of notifications per minute, 0 for no limit", "minimum": 0},
+	"notifications.rules[].event":   {"enum": NotificationEvents},
+	"notifications.rules[].temp":    {"description": "Warning temperature in °C for event \"temperature\""},
+	"notifications.rules[].device":  {"description": "Name of the device, all devices if empty"},
+	"notifications.rules[].urgency": {"enum": NotificationUrgencies},
+	"console":                       {"description": "Console UI"},
+	"console.keys":                  {"description": "Action of each key, e.g. \"space\": \"toggle\", an empty action removes a default binding"},
+	"console.keys.*":                {"enum": append([]string{""}, ConsoleActions...)},
+	"console.pauseMinutes":          {"description": "Minutes the action \"pause\" hands the fan to the driver", "exclusiveMinimum": 0},
+	"console.manualMinutes":         {"description": "Minutes after which speeds set with \"speed-up\" and \"speed-down\" return to the curve, 0 to keep them", "minimum": 0},
+	"console.speedStep":             {"description": "Change of the actions \"speed-up\" and \"speed-down\" in %", "exclusiveMinimum": 0, "maximum": 100},
+	"console.powerCapStep":          {"description": "Change of the actions \"power-cap-up\" and \"power-cap-down\" in W", "exclusiveMinimum": 0},
+}
+
+// Schema returns a JSON Schema of the configuration file for editor autocompletion
+func Schema() map[string]any {
+	schema := schemaOf(reflect.TypeOf(Configuration{}), "")
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "fanmi configuration"
+	schema["properties"].(map[string]any)["$schema"] = map[string]any{"type": "string"}
+	return schema
+}
+
+func schemaOf(t reflect.Type, path string) map[string]any {
+	var schema map[string]any
+
+	switch {
+	case t == reflect.TypeOf(Entry{}):
+		schema = entrySchema()
+	case t.Kind() == reflect.Struct:
+		properties := make(map[string]any)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = schemaOf(field.Type, strings.TrimPrefix(path+"."+name, "."))
+		}
+		schema = map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	case t.Kind() == reflect.Map:
+		schema = map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), path+".*")}
+	case t.Kind() == reflect.Slice:
+		schema = map[string]any{"type": "array", "items": schemaOf(t.Elem(), path+"[]")}
+	case t.Kind() == reflect.Bool:
+		schema = map[string]any{"type": "boolean"}
+	case t.Kind() == reflect.String:
+		schema = map[string]any{"type": "string"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		schema = map[string]any{"type": "integer"}
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		schema = map[string]any{"type": "integer", "minimum": 0}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		schema = map[string]any{"type": "number"}
+	default:
+		schema = map[string]any{}
+	}
+
+	for key, value := range schemaAnnotations[path] {
+		schema[key] = value
+	}
+	return schema
+}
+
+// entrySchema describes a curve entry, which accepts its keys in both spellings and one of several speed units
+func entrySchema() map[string]any {
+	temp := map[string]any{"type": "number", "minimum": 0, "description": "Temperature in °C"}
+	speed := map[string]any{
+		"description": "Fan speed as fraction (0.45) or percent (\"45%\")",
+		"oneOf": []any{
+			map[string]any{"type": "number", "minimum": 0, "maximum": 1},
+			map[string]any{"type": "string", "pattern": `^\s*[0-9.]+\s*%?\s*$`},
+		},
+	}
+	pwm := map[string]any{"type": "number", "minimum": 0, "maximum": 255, "description": "Fan speed as pwm1 value"}
+	rpm := map[string]any{"type": "integer", "minimum": 0, "description": "Target revolutions per minute"}
+
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"Temp": temp, "temp": temp,
+			"Speed": speed, "speed": speed,
+			"PWM": pwm, "pwm": pwm,
+			"RPM": rpm, "rpm": rpm,
+		},
+		"additionalProperties": false,
+	}
+}
@@ -0,0 +1,21 @@
+package configuration
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestSchema_upToDate(t *testing.T) {
+	want, err := json.MarshalIndent(Schema(), "", "\t")
+	if err != nil {
+		t.Fatalf("Schema() error = %s", err.Error())
+	}
+	got, err := os.ReadFile("../../doc/fanmi_config.schema.json")
+	if err != nil {
+		t.Fatalf("error reading schema: %s", err.Error())
+	}
+	if string(got) != string(want)+"\n" {
+		t.Errorf("doc/fanmi_config.schema.json is outdated, update it with \"fanmi config schema\"")
+	}
+}
@@ -0,0 +1,329 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirion/fanmi/app/keys"
+)
+
+// Minimal check interval, smaller values would keep the CPU busy
+const MinCheckIntervalMs = 100
+
+// ValidationError describes a problem with the value at the JSON path
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors contains all problems found in a configuration
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks the configuration before the curves are prepared and returns all problems at once
+func (c *Configuration) Validate() error {
+	errs := ValidationErrors{}
+	errs = append(errs, c.unknownKeys...)
+
+	if c.Version < 1 {
+		errs.add("version", "%d < 1", c.Version)
+	} else if c.Version > CurrentVersion {
+		errs.add("version", "%d is newer than version %d supported by this fanmi", c.Version, CurrentVersion)
+	}
+	if c.CheckIntervalMs < MinCheckIntervalMs {
+		errs.add("checkIntervalMs", "%d < %d", c.CheckIntervalMs, MinCheckIntervalMs)
+	}
+	if c.MinChange < 0 {
+		errs.add("minChange", "%s < 0.0", number(c.MinChange))
+	}
+	if c.MaxStepUp <= 0 || c.MaxStepUp > 100 {
+		errs.add("maxStepUp", "%s is not in range (0, 100]", number(c.MaxStepUp))
+	}
+	if c.MaxStepDown <= 0 || c.MaxStepDown > 100 {
+		errs.add("maxStepDown", "%s is not in range (0, 100]", number(c.MaxStepDown))
+	}
+	if c.FailsafeTemp < 0 {
+		errs.add("failsafeTemp", "%s < 0.0", number(c.FailsafeTemp))
+	}
+	// Only the documented levels are known here, whether a card accepts the level shows when it is written
+	if c.PowerMode != "" && !slices.Contains(PowerLevels, c.PowerMode) {
+		errs.add("powerMode", "'%s' is not one of %s", c.PowerMode, strings.Join(PowerLevels, ", "))
+	}
+
+	names := make([]string, 0, len(c.Curves))
+	for name := range c.Curves {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		errs.add("curves", "no fan curves defined")
+	} else if c.CurrentCurve == "" && len(names) > 1 {
+		errs.add("curve", "no curve selected, but %d curves defined", len(names))
+	} else if _, ok := c.Curves[c.CurrentCurve]; c.CurrentCurve != "" && !ok {
+		errs.add("curve", "'%s' not found in curves", c.CurrentCurve)
+	}
+	for _, name := range names {
+		errs = append(errs, ValidateCurve(name, c.Curves[name])...)
+	}
+	devices := make([]string, 0, len(c.Devices))
+	for device := range c.Devices {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+	for _, device := range devices {
+		curve := c.Devices[device].Curve
+		if _, ok := c.Curves[curve]; curve != "" && !ok {
+			errs.add("devices."+device+".curve", "'%s' not found in curves", curve)
+		}
+		errs.powerProfileMode("devices."+device+".powerProfileMode", c.Devices[device].PowerProfileMode)
+		if c.Devices[device].PowerCap < 0 {
+			errs.add("devices."+device+".powerCap", "%s < 0.0", number(c.Devices[device].PowerCap))
+		}
+	}
+	errs.powerProfileMode("powerProfileMode", c.PowerProfileMode)
+	if c.PowerCap < 0 {
+		errs.add("powerCap", "%s < 0.0", number(c.PowerCap))
+	}
+	profiles := make([]string, 0, len(c.Profiles))
+	for profile := range c.Profiles {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+	for _, name := range profiles {
+		profile := c.Profiles[name]
+		if _, ok := c.Curves[profile.Curve]; profile.Curve != "" && !ok {
+			errs.add("profiles."+name+".curve", "'%s' not found in curves", profile.Curve)
+		}
+		if profile.PowerCap < 0 {
+			errs.add("profiles."+name+".powerCap", "%s < 0.0", number(profile.PowerCap))
+		}
+	}
+	if _, ok := c.Profiles[c.Profile]; c.Profile != "" && !ok {
+		errs.add("profile", "'%s' not found in profiles", c.Profile)
+	}
+
+	if c.Log.Level != "" && !slices.Contains(LogLevels, strings.ToLower(c.Log.Level)) {
+		errs.add("log.level", "'%s' is not one of %s", c.Log.Level, strings.Join(LogLevels, ", "))
+	}
+	if c.Log.Sink != "" && !slices.Contains(LogSinks, c.Log.Sink) {
+		errs.add("log.sink", "'%s' is not one of %s", c.Log.Sink, strings.Join(LogSinks, ", "))
+	} else if c.Log.Sink == "file" && c.Log.File == "" {
+		errs.add("log.file", "no file given for sink 'file'")
+	}
+	if c.Telemetry.Format != "" && !slices.Contains(TelemetryFormats, c.Telemetry.Format) {
+		errs.add("telemetry.format", "'%s' is not one of %s", c.Telemetry.Format, strings.Join(TelemetryFormats, ", "))
+	}
+	if c.Telemetry.MaxSize < 0 {
+		errs.add("telemetry.maxSize", "%d < 0", c.Telemetry.MaxSize)
+	}
+	if c.Notifications.Cooldown < 0 {
+		errs.add("notifications.cooldown", "%d < 0", c.Notifications.Cooldown)
+	}
+	if c.Notifications.RateLimit < 0 {
+		errs.add("notifications.rateLimit", "%d < 0", c.Notifications.RateLimit)
+	}
+	keyNames := make([]string, 0, len(c.Console.Keys))
+	for key := range c.Console.Keys {
+		keyNames = append(keyNames, key)
+	}
+	sort.Strings(keyNames)
+	for _, key := range keyNames {
+		action := c.Console.Keys[key]
+		if !keys.Valid(key) {
+			errs.add("console.keys."+key, "unknown key, use a character or one of %s", strings.Join(keys.Named, ", "))
+		} else if action != "" && !slices.Contains(ConsoleActions, action) {
+			errs.add("console.keys."+key, "'%s' is not one of %s", action, strings.Join(ConsoleActions, ", "))
+		}
+	}
+	if c.Console.PauseMinutes <= 0 {
+		errs.add("console.pauseMinutes", "%d <= 0", c.Console.PauseMinutes)
+	}
+	if c.Console.ManualMinutes < 0 {
+		errs.add("console.manualMinutes", "%d < 0", c.Console.ManualMinutes)
+	}
+	if c.Console.SpeedStep <= 0 || c.Console.SpeedStep > 100 {
+		errs.add("console.speedStep", "%s is not in range (0, 100]", number(c.Console.SpeedStep))
+	}
+	if c.Console.PowerCapStep <= 0 {
+		errs.add("console.powerCapStep", "%s <= 0.0", number(c.Console.PowerCapStep))
+	}
+	for i, rule := range c.Notifications.Rules {
+		rulePath := fmt.Sprintf("notifications.rules[%d]", i)
+		if !slices.Contains(NotificationEvents, rule.Event) {
+			errs.add(rulePath+".event", "'%s' is not one of %s", rule.Event, strings.Join(NotificationEvents, ", "))
+		} else if rule.Event == "temperature" && rule.Temp <= 0 {
+			errs.add(rulePath+".temp", "%s <= 0.0", number(rule.Temp))
+		}
+		if rule.Urgency != "" && !slices.Contains(NotificationUrgencies, rule.Urgency) {
+			errs.add(rulePath+".urgency", "'%s' is not one of %s", rule.Urgency, strings.Join(NotificationUrgencies, ", "))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ValidateCurve checks the entries of a curve in their original (unsorted) order
+func ValidateCurve(name string, curve Values) ValidationErrors {
+	errs := ValidationErrors{}
+	path := "curves." + name
+
+	if name == "" {
+		errs.add("curves", "curve name must not be empty")
+	}
+	if len(curve) == 0 {
+		errs.add(path, "curve has no entries")
+	}
+
+	temps := make(map[float32]int, len(curve))
+	rpm := curve.IsRPM()
+	for i, entry := range curve {
+		entryPath := fmt.Sprintf("%s[%d]", path, i)
+		if entry.InRPM != rpm {
+			errs.add(entryPath, "mixes rpm and speed, all entries of a curve must use the same unit")
+		}
+		if entry.Temp < 0 {
+			errs.add(entryPath+".Temp", "%s < 0.0", number(entry.Temp))
+		}
+		if first, ok := temps[entry.Temp]; ok {
+			errs.add(entryPath+".Temp", "%s is already used by %s[%d]", number(entry.Temp), path, first)
+		} else {
+			temps[entry.Temp] = i
+		}
+		if entry.RPM < 0 {
+			errs.add(entryPath+".RPM", "%d < 0", entry.RPM)
+		} else if entry.Speed < 0 {
+			errs.add(entryPath+".Speed", "%s < 0.0", number(entry.Speed))
+		} else if entry.Speed > 1 {
+			errs.add(entryPath+".Speed", "%s > 1.0", number(entry.Speed))
+		}
+	}
+	return errs
+}
+
+// powerProfileMode checks the name of a pp_power_profile_mode entry, the entries themselves differ between cards
+func (e *ValidationErrors) powerProfileMode(path, mode string) {
+	if mode == "CUSTOM" {
+		e.add(path, "'CUSTOM' needs heuristics, which fanmi does not write")
+	} else if mode != "" && !powerProfileModePattern.MatchString(mode) {
+		e.add(path, "'%s' is not a mode name like COMPUTE", mode)
+	}
+}
+
+func (e *ValidationErrors) add(path, format string, args ...any) {
+	*e = append(*e, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// number formats a value with at least one decimal
+func number(value float32) string {
+	str := strconv.FormatFloat(float64(value), 'f', -1, 32)
+	if !strings.Contains(str, ".") {
+		str += ".0"
+	}
+	return str
+}
+
+// findUnknownKeys returns an error for every key in the JSON data that is not part of the configuration
+func findUnknownKeys(data []byte) ValidationErrors {
+	var value any
+	if json.Unmarshal(data, &value) != nil {
+		return nil
+	}
+	if object, ok := value.(map[string]any); ok {
+		// Editors read the JSON Schema from this key
+		delete(object, "$schema")
+	}
+	return unknownKeys(value, reflect.TypeOf((*Configuration)(nil)).Elem(), "")
+}
+
+func unknownKeys(value any, t reflect.Type, path string) ValidationErrors {
+	errs := ValidationErrors{}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return errs
+		}
+		fields := jsonFields(t)
+		if t == reflect.TypeOf(Entry{}) {
+			// Entries have their own JSON keys
+			fields = jsonFields(reflect.TypeOf(entryJSON{}))
+		}
+		for _, key := range sortedKeys(object) {
+			keyPath := strings.TrimPrefix(path+"."+key, ".")
+			// encoding/json matches keys case-insensitively
+			fieldType, ok := fields[strings.ToLower(key)]
+			if !ok {
+				errs.add(keyPath, "unknown key")
+				continue
+			}
+			errs = append(errs, unknownKeys(object[key], fieldType, keyPath)...)
+		}
+
+	case reflect.Map:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return errs
+		}
+		for _, key := range sortedKeys(object) {
+			errs = append(errs, unknownKeys(object[key], t.Elem(), strings.TrimPrefix(path+"."+key, "."))...)
+		}
+
+	case reflect.Slice:
+		array, ok := value.([]any)
+		if !ok {
+			return errs
+		}
+		for i, element := range array {
+			errs = append(errs, unknownKeys(element, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+
+	return errs
+}
+
+// jsonFields returns the types of all fields that can be set via JSON by their lower case name
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = field.Type
+	}
+	return fields
+}
+
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,118 @@
+package configuration
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirion/fanmi/app/keys"
+)
+
+func TestConfiguration_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "Defaults",
+			data: `{}`,
+			want: nil,
+		},
+		{
+			name: "Valid curve",
+			data: `{"curve": "quiet", "curves": {"quiet": [{"Temp": 80, "Speed": 1}, {"Temp": 50, "Speed": 0}]}}`,
+			want: nil,
+		},
+		{
+			name: "Speed out of range",
+			data: `{"curves": {"loud": [{"Temp": 40, "Speed": 0}, {"Temp": 60, "Speed": 0.5}, {"Temp": 80, "Speed": 1.5}]}}`,
+			want: []string{"curves.loud[2].Speed: 1.5 > 1.0", "curve: no curve selected, but 2 curves defined"},
+		},
+		{
+			name: "Negative and duplicate temperatures",
+			data: `{"curve": "default", "curves": {"default": [{"Temp": -5, "Speed": 0}, {"Temp": 60, "Speed": 0.5}, {"Temp": 60, "Speed": 1}]}}`,
+			want: []string{"curves.default[0].Temp: -5.0 < 0.0", "curves.default[2].Temp: 60.0 is already used by curves.default[1]"},
+		},
+		{
+			name: "Unknown keys",
+			data: `{"checkInterval": 1000, "log": {"lvl": "debug"}, "curves": {"default": [{"Temp": 40, "Speed": 0, "fan": 1000}]}}`,
+			want: []string{"checkInterval: unknown key", "log.lvl: unknown key", "curves.default[0].fan: unknown key"},
+		},
+		{
+			name: "Check interval too small",
+			data: `{"checkIntervalMs": 0}`,
+			want: []string{"checkIntervalMs: 0 < 100"},
+		},
+		{
+			name: "Unknown power mode",
+			data: `{"powerMode": "turbo"}`,
+			want: []string{"powerMode: 'turbo' is not one of auto, low, high"},
+		},
+		{
+			name: "Notification rules",
+			data: `{"notifications": {"cooldown": -1, "rules": [{"event": "temperature"}, {"event": "fire"}, {"event": "stall", "urgency": "urgent"}]}}`,
+			want: []string{
+				"notifications.cooldown: -1 < 0",
+				"notifications.rules[0].temp: 0.0 <= 0.0",
+				"notifications.rules[1].event: 'fire' is not one of temperature, stall, failsafe, writeError",
+				"notifications.rules[2].urgency: 'urgent' is not one of low, normal, critical",
+			},
+		},
+		{
+			name: "Console keys",
+			data: `{"console": {"keys": {"x": "explode", "hyper-x": "quit", "q": ""}, "speedStep": 0}}`,
+			want: []string{
+				"console.keys.hyper-x: unknown key, use a character or one of " + strings.Join(keys.Named, ", "),
+				"console.keys.x: 'explode' is not one of " + strings.Join(ConsoleActions, ", "),
+				"console.speedStep: 0.0 is not in range (0, 100]",
+			},
+		},
+		{
+			name: "Power caps and profiles",
+			data: `{"powerCap": -10, "devices": {"card1": {"powerCap": -1}}, "profiles": {"quiet": {"curve": "silent", "powerCap": 150}}, "profile": "loud"}`,
+			want: []string{
+				"devices.card1.powerCap: -1.0 < 0.0",
+				"powerCap: -10.0 < 0.0",
+				"profiles.quiet.curve: 'silent' not found in curves",
+				"profile: 'loud' not found in profiles",
+			},
+		},
+		{
+			name: "Power profile modes",
+			data: `{"powerProfileMode": "compute", "devices": {"card0": {"powerProfileMode": "CUSTOM"}, "card1": {"powerProfileMode": "3D_FULL_SCREEN"}}}`,
+			want: []string{
+				"devices.card0.powerProfileMode: 'CUSTOM' needs heuristics, which fanmi does not write",
+				"powerProfileMode: 'compute' is not a mode name like COMPUTE",
+			},
+		},
+		{
+			name: "Unknown curve selected",
+			data: `{"curve": "silent"}`,
+			want: []string{"curve: 'silent' not found in curves"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Parse() error = %s", err.Error())
+			}
+
+			err = config.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %s, want none", err.Error())
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() returned no error, want %v", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %s, want %s", err.Error(), want)
+				}
+			}
+		})
+	}
+}
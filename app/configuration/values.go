@@ -1,12 +1,92 @@
 package configuration
 
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirion/fanmi/app/units"
+)
+
+// Entry of a fan curve, the keys are case-insensitive ("Temp" or "temp") in all configuration formats.
+// The fan speed can be given as "speed" (fraction 0-1 or percent as string like "45%"), as "pwm" (0-255) or as
+// "rpm".
 type Entry struct {
-	Temp  float32
+	Temp float32
+	// Fan speed (0-1), used if InRPM is not set
 	Speed float32
+	// Target fan speed in revolutions per minute
+	RPM int
+	// InRPM marks entries given in revolutions per minute, also for a target of 0 rpm
+	InRPM bool
+}
+
+// entryJSON contains all keys an entry can be given with
+type entryJSON struct {
+	Temp  float32
+	Speed json.RawMessage
+	PWM   *float32
+	RPM   *int
+}
+
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	entry := entryJSON{}
+	err := json.Unmarshal(data, &entry)
+	if err != nil {
+		return err
+	}
+
+	*e = Entry{Temp: entry.Temp}
+	speeds := 0
+	if entry.Speed != nil {
+		speeds++
+		e.Speed, err = units.ParseSpeed(entry.Speed)
+		if err != nil {
+			return err
+		}
+	}
+	if entry.PWM != nil {
+		speeds++
+		e.Speed = units.FromPWM(*entry.PWM)
+	}
+	if entry.RPM != nil {
+		speeds++
+		e.RPM = *entry.RPM
+		e.InRPM = true
+	}
+	if speeds > 1 {
+		return fmt.Errorf("curve entry for %s° has more than one of speed, pwm and rpm", number(e.Temp))
+	}
+	return nil
+}
+
+func (e Entry) MarshalJSON() ([]byte, error) {
+	if e.InRPM {
+		return json.Marshal(struct {
+			Temp float32
+			RPM  int
+		}{e.Temp, e.RPM})
+	}
+	return json.Marshal(struct {
+		Temp  float32
+		Speed float32
+	}{e.Temp, e.Speed})
+}
+
+// Value returns the target of the entry, either RPM or Speed
+func (e Entry) Value() float32 {
+	if e.InRPM {
+		return float32(e.RPM)
+	}
+	return e.Speed
 }
 
 type Values []Entry
 
+// IsRPM checks whether the curve targets revolutions per minute instead of speeds
+func (v Values) IsRPM() bool {
+	return len(v) > 0 && v[0].InRPM
+}
+
 func (v Values) Len() int {
 	return len(v)
 }
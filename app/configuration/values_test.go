@@ -0,0 +1,70 @@
+package configuration
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEntry_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    Entry
+		wantErr bool
+	}{
+		{`{"Temp": 50, "Speed": 0.45}`, Entry{Temp: 50, Speed: 0.45}, false},
+		{`{"temp": 50, "speed": "45%"}`, Entry{Temp: 50, Speed: 0.45}, false},
+		{`{"temp": 50, "pwm": 51}`, Entry{Temp: 50, Speed: 0.2}, false},
+		{`{"temp": 50, "rpm": 1800}`, Entry{Temp: 50, RPM: 1800, InRPM: true}, false},
+		{`{"temp": 40, "rpm": 0}`, Entry{Temp: 40, InRPM: true}, false},
+		{`{"temp": 50, "speed": 0.5, "rpm": 1800}`, Entry{}, true},
+		{`{"temp": 50, "speed": "fast"}`, Entry{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			got := Entry{}
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("UnmarshalJSON() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCurve_units(t *testing.T) {
+	curve := Values{}
+	json.Unmarshal([]byte(`[{"temp": 40, "rpm": 800}, {"temp": 60, "speed": "50%"}, {"temp": 80, "pwm": 300}]`), &curve)
+
+	errs := ValidateCurve("mixed", curve)
+	want := []string{
+		"curves.mixed[1]: mixes rpm and speed, all entries of a curve must use the same unit",
+		"curves.mixed[2]: mixes rpm and speed, all entries of a curve must use the same unit",
+		"curves.mixed[2].Speed: 1.1764706 > 1.0",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("ValidateCurve() = %s, want %d errors", errs.Error(), len(want))
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf("ValidateCurve()[%d] = %s, want %s", i, err.Error(), want[i])
+		}
+	}
+}
+
+func TestValidateCurve_zeroRPM(t *testing.T) {
+	curve := Values{}
+	json.Unmarshal([]byte(`[{"temp": 40, "rpm": 0}, {"temp": 60, "rpm": 1200}, {"temp": 85, "rpm": 2500}]`), &curve)
+
+	if errs := ValidateCurve("rpm", curve); len(errs) > 0 {
+		t.Fatalf("ValidateCurve() = %s, want no errors", errs.Error())
+	}
+	if !curve.IsRPM() || curve[0].Value() != 0 {
+		t.Errorf("IsRPM() = %t, Value() = %v, want an rpm curve starting at 0", curve.IsRPM(), curve[0].Value())
+	}
+	data, _ := json.Marshal(curve[0])
+	if string(data) != `{"Temp":40,"RPM":0}` {
+		t.Errorf("MarshalJSON() = %s, want the unit kept for 0 rpm", data)
+	}
+}
@@ -0,0 +1,94 @@
+package debug
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// journaldHandler writes one line per record, prefixed with the syslog priority (e.g. "<6>") as understood
+// by systemd-journald for the output of services (see sd-daemon(3))
+type journaldHandler struct {
+	mutex *sync.Mutex
+	out   io.Writer
+	level slog.Leveler
+	attrs string
+	group string
+}
+
+func (h *journaldHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *journaldHandler) Handle(_ context.Context, r slog.Record) error {
+	line := &strings.Builder{}
+	fmt.Fprintf(line, "<%d>%s%s", priority(r.Level), r.Message, h.attrs)
+	r.Attrs(func(attr slog.Attr) bool {
+		writeAttr(line, h.group, attr)
+		return true
+	})
+	line.WriteString("\n")
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, err := io.WriteString(h.out, line.String())
+	return err
+}
+
+func (h *journaldHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	line := &strings.Builder{}
+	line.WriteString(h.attrs)
+	for _, attr := range attrs {
+		writeAttr(line, h.group, attr)
+	}
+
+	handler := *h
+	handler.attrs = line.String()
+	return &handler
+}
+
+func (h *journaldHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	handler := *h
+	handler.group = h.group + name + "."
+	return &handler
+}
+
+func writeAttr(line *strings.Builder, group string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, groupAttr := range attr.Value.Group() {
+			writeAttr(line, group+attr.Key+".", groupAttr)
+		}
+		return
+	}
+
+	value := attr.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	fmt.Fprintf(line, " %s%s=%s", group, attr.Key, value)
+}
+
+// priority returns the syslog priority of the level
+func priority(l slog.Level) int {
+	switch {
+	case l >= slog.LevelError:
+		return 3
+	case l >= slog.LevelWarn:
+		return 4
+	case l >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
@@ -0,0 +1,49 @@
+package debug
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_journaldHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(logger *slog.Logger)
+		want string
+	}{
+		{
+			name: "Debug filtered",
+			log:  func(logger *slog.Logger) { logger.Debug("hidden") },
+			want: "",
+		},
+		{
+			name: "Info",
+			log:  func(logger *slog.Logger) { logger.Info("sysfs write", "device", "card0", "new", "128") },
+			want: "<6>sysfs write device=card0 new=128\n",
+		},
+		{
+			name: "Quoted values",
+			log:  func(logger *slog.Logger) { logger.Error("failed", "error", "permission denied", "old", "") },
+			want: "<3>failed error=\"permission denied\" old=\"\"\n",
+		},
+		{
+			name: "Attributes and groups",
+			log: func(logger *slog.Logger) {
+				logger.With("device", "card1").WithGroup("fan").Warn("stalled", "rpm", 0)
+			},
+			want: "<4>stalled device=card1 fan.rpm=0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &strings.Builder{}
+			logger := slog.New(&journaldHandler{mutex: &sync.Mutex{}, out: out, level: slog.LevelInfo})
+			tt.log(logger)
+			if out.String() != tt.want {
+				t.Errorf("journaldHandler output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
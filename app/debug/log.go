@@ -1,24 +1,141 @@
 package debug
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
 )
 
 var DebugOutput bool = false
 
+// Reason why a value was written to a sysfs file
+type Reason string
+
+const (
+	ReasonCurve    Reason = "curve"
+	ReasonFailsafe Reason = "failsafe"
+	ReasonUser     Reason = "user"
+	ReasonShutdown Reason = "shutdown"
+)
+
+const (
+	SinkStderr   = "stderr"
+	SinkFile     = "file"
+	SinkJournald = "journald"
+)
+
+var (
+	level    = &slog.LevelVar{}
+	terminal = &switchWriter{w: os.Stderr}
+	logFile  *os.File
+
+	// Logger is used for all log output of fanmi
+	Logger = slog.New(slog.NewTextHandler(terminal, &slog.HandlerOptions{Level: level}))
+	// AuditLogger records sysfs writes to the sink of Logger regardless of the configured level
+	AuditLogger = slog.New(slog.NewTextHandler(terminal, nil))
+)
+
+func init() {
+	level.Set(slog.LevelWarn)
+}
+
+// Setup configures level and sink of the Logger. Level is always "debug" if DebugOutput is set.
+func Setup(levelName, sink, filePath string) error {
+	if DebugOutput {
+		levelName = "debug"
+	}
+	if levelName != "" {
+		var l slog.Level
+		err := l.UnmarshalText([]byte(levelName))
+		if err != nil {
+			return fmt.Errorf("invalid log level '%s'", levelName)
+		}
+		level.Set(l)
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	switch sink {
+	case "", SinkStderr:
+		Logger = slog.New(slog.NewTextHandler(terminal, options))
+		AuditLogger = slog.New(slog.NewTextHandler(terminal, nil))
+
+	case SinkJournald:
+		mutex := &sync.Mutex{}
+		Logger = slog.New(&journaldHandler{mutex: mutex, out: terminal, level: level})
+		AuditLogger = slog.New(&journaldHandler{mutex: mutex, out: terminal, level: slog.LevelInfo})
+
+	case SinkFile:
+		if filePath == "" {
+			return fmt.Errorf("no log file given")
+		}
+		file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("error opening log file %s: %s", filePath, err.Error())
+		}
+		if logFile != nil {
+			logFile.Close()
+		}
+		logFile = file
+		Logger = slog.New(slog.NewJSONHandler(file, options))
+		AuditLogger = slog.New(slog.NewJSONHandler(file, nil))
+
+	default:
+		return fmt.Errorf("unknown log sink '%s'", sink)
+	}
+
+	return nil
+}
+
+// SetOutput replaces the writer of the stderr and journald sinks. UIs drawing on the terminal use this to
+// print log records without breaking their output.
+func SetOutput(w io.Writer) {
+	terminal.set(w)
+}
+
 func Log(format string, args ...any) {
-	if !DebugOutput {
+	if !Logger.Enabled(context.Background(), slog.LevelDebug) {
 		return
 	}
-	fmt.Printf(format, args...)
+	Logger.Debug(strings.TrimSpace(fmt.Sprintf(format, args...)))
 }
 
 func LogJSON(prefix string, object any, suffix string) {
-	if !DebugOutput {
+	if !Logger.Enabled(context.Background(), slog.LevelDebug) {
 		return
 	}
 
 	json, _ := json.MarshalIndent(object, "", "    ")
-	fmt.Printf("%s%s%s", prefix, json, suffix)
+	Logger.Debug(strings.TrimSpace(fmt.Sprintf("%s%s%s", prefix, json, suffix)))
+}
+
+// Audit records a write to a sysfs file, independent of the log level
+func Audit(device, filePath, oldValue, newValue string, reason Reason, err error) {
+	if err != nil {
+		AuditLogger.Error("sysfs write failed", "device", device, "file", filePath, "old", oldValue, "new", newValue, "reason", reason, "error", err.Error())
+		return
+	}
+	AuditLogger.Info("sysfs write", "device", device, "file", filePath, "old", oldValue, "new", newValue, "reason", reason)
+}
+
+// switchWriter is a writer whose target can be replaced
+type switchWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.w.Write(p)
+}
+
+func (s *switchWriter) set(w io.Writer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.w = w
 }
@@ -0,0 +1,35 @@
+package debug
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	out := &strings.Builder{}
+	SetOutput(out)
+	defer SetOutput(os.Stderr)
+
+	err := Setup("error", SinkStderr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Setup("warn", SinkStderr, "")
+
+	Logger.Info("hidden")
+	Audit("card0", "pwm1", "100", "128", ReasonCurve, nil)
+	Audit("card0", "pwm1", "128", "255", ReasonFailsafe, errors.New("permission denied"))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Audit() wrote %q, want 2 records on level error", out.String())
+	}
+	if !strings.Contains(lines[0], "level=INFO msg=\"sysfs write\" device=card0 file=pwm1 old=100 new=128 reason=curve") {
+		t.Errorf("Audit() wrote %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "level=ERROR msg=\"sysfs write failed\"") {
+		t.Errorf("Audit() wrote %q", lines[1])
+	}
+}
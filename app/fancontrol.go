@@ -5,13 +5,18 @@ import (
 	"math"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/sirion/fanmi/app/configuration"
 	"github.com/sirion/fanmi/app/debug"
+	"github.com/sirion/fanmi/app/powerprofile"
+	"github.com/sirion/fanmi/app/status"
 	"github.com/sirion/fanmi/app/ui"
+	"github.com/sirion/fanmi/app/units"
 )
 
 const (
@@ -19,133 +24,325 @@ const (
 	FANMODE_AUTO   = "2"
 )
 
+// Share of the RPM difference that is corrected per cycle when regulating the fan speed for rpm curves
+const closedLoopGain = 0.5
+
 type FanControl struct {
 	done          chan bool
 	ui            ui.UI
 	config        *configuration.Configuration
+	listeners     []status.Listener
+	name          string
 	deviceDirPath string
 	hwmonDirPath  string
 	powerModePath string
 	pwmPath       string
 	fanModePath   string
+	fanInputPath  string
+	fanTargetPath string
+	maxRPM        int
 	tempInputPath string
+	tempChannels  []tempChannel
 	byTempData    byTempData
+	powerCap      powerCap
+	powerProfile  powerProfile
+	status        status.Status
+}
+
+// powerCap is the power limit of the device, all values in µW
+type powerCap struct {
+	// Empty if the driver has no power1_cap
+	path      string
+	inputPath string
+	min       int64
+	max       int64
+	// Limit at start-up, restored when the configuration keeps the limit of the driver again and on exit
+	original int64
+	// Whether fanmi changed the limit
+	changed bool
+	// Limit that could not be written, it is not tried again
+	failed int64
+}
+
+// powerProfile is the entry of pp_power_profile_mode of the device
+type powerProfile struct {
+	// Empty if the driver has no pp_power_profile_mode
+	path string
+	// Mode at start-up, restored when the configuration keeps the mode of the driver again and on exit
+	original string
+	// Whether fanmi changed the mode
+	changed bool
+	// Mode that could not be selected, it is not tried again
+	failed string
 }
 
 type byTempData struct {
 	currentFactor float32
 }
 
-func NewFanControl(ui ui.UI, deviceDirPath, hwmonDirPath string, config *configuration.Configuration) *FanControl {
+type tempChannel struct {
+	label     string
+	inputPath string
+}
+
+func NewFanControl(ui ui.UI, deviceDirPath, hwmonDirPath string, config *configuration.Configuration, listeners ...status.Listener) *FanControl {
+	name := path.Base(path.Dir(deviceDirPath))
+	// Not all drivers let the firmware regulate the fan to a target RPM
+	fanTargetPath := path.Join(hwmonDirPath, "fan1_target")
+	if !fileExists(fanTargetPath) {
+		fanTargetPath = ""
+	}
 	return &FanControl{
 		done:          make(chan bool),
 		ui:            ui,
 		deviceDirPath: deviceDirPath,
 		hwmonDirPath:  hwmonDirPath,
 		config:        config,
+		listeners:     listeners,
+		name:          name,
 		powerModePath: path.Join(deviceDirPath, "power_dpm_force_performance_level"),
 		pwmPath:       path.Join(hwmonDirPath, "pwm1"),
 		fanModePath:   path.Join(hwmonDirPath, "pwm1_enable"),
+		fanInputPath:  path.Join(hwmonDirPath, "fan1_input"),
+		fanTargetPath: fanTargetPath,
+		maxRPM:        readRPM(path.Join(hwmonDirPath, "fan1_max")),
 		tempInputPath: path.Join(hwmonDirPath, "temp1_input"),
+		tempChannels:  findTempChannels(hwmonDirPath),
 		byTempData: byTempData{
 			currentFactor: -1,
 		},
+		powerCap:     findPowerCap(hwmonDirPath),
+		powerProfile: findPowerProfile(deviceDirPath),
+		status: status.Status{
+			Device: name,
+			RPM:    -1,
+			Power:  -1,
+		},
+	}
+}
+
+// findPowerCap reads the power limit and its range from the hwmon directory
+func findPowerCap(hwmonDirPath string) powerCap {
+	p := powerCap{
+		path:      path.Join(hwmonDirPath, "power1_cap"),
+		inputPath: path.Join(hwmonDirPath, "power1_average"),
+	}
+	if !fileExists(p.inputPath) {
+		// Newer cards only report the current power draw
+		p.inputPath = path.Join(hwmonDirPath, "power1_input")
+	}
+
+	var err error
+	p.original, err = readInt(p.path)
+	if err != nil {
+		p.path = ""
+		return p
 	}
+	p.min, _ = readInt(path.Join(hwmonDirPath, "power1_cap_min"))
+	p.max, _ = readInt(path.Join(hwmonDirPath, "power1_cap_max"))
+	return p
+}
+
+// findPowerProfile reads the active entry of pp_power_profile_mode from the device directory
+func findPowerProfile(deviceDirPath string) powerProfile {
+	p := powerProfile{path: path.Join(deviceDirPath, "pp_power_profile_mode")}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		p.path = ""
+		return p
+	}
+	_, p.original = powerprofile.Parse(string(data))
+	return p
 }
 
 func (f *FanControl) Run() chan bool {
 	go (func() {
 		powerModeAvailable := true
+		powerMode := ""
 		var lastTemp float32 = -500
 		var lastSpeed float32 = -500
 		lastCurve := f.config.Curve
 
 		for f.config.Running {
+			// Settings that can be changed by reloading the configuration
+			f.config.RLock()
+			interval := time.Duration(f.config.CheckIntervalMs) * time.Millisecond
+			minChange := f.config.MinChange
+			failsafeTemp := f.config.FailsafeTemp
+			f.config.RUnlock()
+			curveName, curve := f.config.DeviceCurve(f.name)
+			// Without fan1_target the speed of rpm curves is regulated every cycle
+			closedLoop := curve.IsRPM() && f.fanTargetPath == ""
+
 			// Power Mode
 			if powerModeAvailable {
 				var err error
 
-				if f.config.PowerModeChanged && f.config.PowerMode != "" {
-					err = writePowerMode(f.powerModePath, f.config.PowerMode)
-					f.config.PowerModeChanged = false
+				if mode, pending := f.config.PendingPowerMode(f.name); pending {
+					err = f.writeSysfs(f.powerModePath, mode, debug.ReasonUser)
 					if err != nil {
-						f.ui.Message(err.Error())
+						// The kernel does not list the levels a card accepts
+						f.status.WriteErrors++
+						f.ui.Message(fmt.Sprintf("Power mode %s was rejected by %s: %s\n", mode, f.name, err.Error()))
 					}
 				}
-				f.config.PowerMode, err = readPowerMode(f.powerModePath)
+				powerMode, err = readPowerMode(f.powerModePath)
 				if err != nil {
-					if powerModeAvailable {
-						fmt.Fprintf(os.Stderr, "Cannot read power mode: %s", err.Error())
-					}
+					debug.Logger.Warn("cannot read power mode", "device", f.name, "error", err.Error())
 					powerModeAvailable = false
-					f.config.PowerMode = ""
+					powerMode = ""
 				}
 			}
-			f.ui.PowerMode(f.config.PowerMode)
+			f.ui.PowerMode(f.name, powerMode)
+			f.updatePowerCap()
+			f.updatePowerProfile()
 
 			temp := readTemp(f.ui, f.tempInputPath)
-			f.ui.Temperature(temp)
+			f.ui.Temperature(f.name, temp)
 			speed := readSpeed(f.ui, f.pwmPath)
 			if math.Abs(float64(lastSpeed-speed)) > 0.1 {
 				// If there is more than 10% difference, it is either initial or something is wrong
 				lastSpeed = speed
 			}
 
-			if !f.config.Active {
-				f.ui.Speed(speed)
+			f.status.Time = time.Now()
+			f.status.Temperature = temp
+			f.status.Temperatures = f.readTempChannels(temp)
+			f.status.TargetSpeed = speed
+			f.status.Speed = speed
+			f.status.RPM = readRPM(f.fanInputPath)
+			f.status.PowerMode = powerMode
+			f.status.Curve = curveName
+
+			if !f.config.DeviceActive(f.name) {
+				f.ui.Speed(f.name, speed)
 
 				if lastTemp != -500 {
 					lastTemp = -500
-					writeFile(f.ui, f.fanModePath, FANMODE_AUTO)
+					f.writeFanMode(FANMODE_AUTO, debug.ReasonUser)
 				}
-				time.Sleep(time.Duration(f.config.CheckIntervalMs) * time.Millisecond)
+				f.setState(status.StatePaused)
+				f.publish()
+				time.Sleep(interval)
+				continue
+			}
+
+			if failsafeTemp > 0 && temp >= failsafeTemp {
+				// Overheating: Ignore curve and step limits until the temperature is back to normal
+				f.failsafe(temp, failsafeTemp)
+				lastSpeed = 1
+				lastTemp = temp
+				f.publish()
+				time.Sleep(interval)
 				continue
 			}
 
+			if fixedSpeed, ok := f.config.DeviceSpeed(f.name); ok {
+				// The user fixed the speed, the curve is used again afterwards
+				if f.manual(fixedSpeed) {
+					lastSpeed = fixedSpeed
+				}
+				lastTemp = -500
+				f.publish()
+				time.Sleep(interval)
+				continue
+			}
+			if f.status.State == status.StateManual {
+				f.ui.Message(fmt.Sprintf("Speed of %s is controlled by curve %s again\n", f.name, curveName))
+			}
+
 			deltaTemp := float32(lastTemp - temp)
 
-			if /* f.config.Mode == configuration.ModeCurve && */ &f.config.Curve != &lastCurve {
-				deltaTemp = f.config.MinChange + 1
+			if /* f.config.Mode == configuration.ModeCurve && */ &f.config.Curve != &lastCurve || f.status.State != status.StateActive {
+				deltaTemp = minChange + 1
 			}
-			if math.Abs(float64(deltaTemp)) > float64(f.config.MinChange) {
-				f.byCurve(temp, &lastSpeed, f.ui, f.pwmPath, f.fanModePath, f.config)
-				lastTemp = temp
+			if math.Abs(float64(deltaTemp)) > float64(minChange) || closedLoop {
+				err := f.byCurve(temp, &lastSpeed)
+				if err != nil {
+					// Hand the fan back to the driver and try again in the next cycle
+					f.status.WriteErrors++
+					f.ui.Message(err.Error() + "\n")
+					f.setState(status.StateFailsafe)
+					f.writeFanMode(FANMODE_AUTO, debug.ReasonFailsafe)
+					lastTemp = -500
+				} else {
+					f.setState(status.StateActive)
+					lastTemp = temp
+				}
 			}
-			time.Sleep(time.Duration(f.config.CheckIntervalMs) * time.Millisecond)
+			f.publish()
+			time.Sleep(interval)
+		}
+
+		if f.powerProfile.changed {
+			f.ui.Message(fmt.Sprintf("Resetting power profile mode of %s to %s\n", f.name, f.powerProfile.original))
+			f.selectPowerProfile(f.powerProfile.original, debug.ReasonShutdown)
+		}
+		if f.powerCap.changed {
+			// Restored before signalling the end, fanmi may exit right afterwards
+			f.ui.Message(fmt.Sprintf("Resetting power limit of %s to %.0f W\n", f.name, units.Watts(f.powerCap.original)))
+			f.writePowerCap(f.powerCap.original, debug.ReasonShutdown)
 		}
 
 		f.done <- true
 
 		f.ui.Message("Resetting FanMode to Auto\n")
-		writeFile(f.ui, f.fanModePath, FANMODE_AUTO)
+		f.writeFanMode(FANMODE_AUTO, debug.ReasonShutdown)
 	})()
 
 	return f.done
 }
 
-func (f *FanControl) byCurve(temp float32, lastSpeed *float32, ui ui.UI, pwmPath, fanModePath string, config *configuration.Configuration) {
-	writeFile(ui, fanModePath, FANMODE_MANUAL)
+func (f *FanControl) byCurve(temp float32, lastSpeed *float32) error {
+	err := f.setFanMode(FANMODE_MANUAL, debug.ReasonCurve)
+	if err != nil {
+		return err
+	}
 
-	min := config.Curve[0]
-	max := config.Curve[len(config.Curve)-1]
+	_, curve := f.config.DeviceCurve(f.name)
+	f.config.RLock()
+	maxUp := units.FromPercent(f.config.MaxStepUp)
+	maxDown := units.FromPercent(f.config.MaxStepDown)
+	f.config.RUnlock()
 
-	var factor float32
+	min := curve[0]
+	max := curve[len(curve)-1]
+
+	// Speed or RPM, depending on the curve
+	var target float32
 	if temp < min.Temp {
-		factor = min.Speed
+		target = min.Value()
 	} else if temp >= max.Temp {
-		factor = max.Speed
+		target = max.Value()
 	} else {
 		// between min and max
-		for i, en := range config.Curve {
+		for i, en := range curve {
 			if temp < en.Temp {
-				factor = calculateStep(temp, config.Curve[i-1], en)
+				target = calculateStep(temp, curve[i-1], en)
 				break
 			}
 		}
 	}
 
-	maxUp := config.MaxStepUp / 100
-	maxDown := config.MaxStepDown / 100
+	factor := target
+	f.status.TargetSpeed = target
+	f.status.TargetRPM = 0
+	if curve.IsRPM() {
+		if f.maxRPM <= 0 {
+			return fmt.Errorf("cannot use rpm curve on %s: maximum fan speed (fan1_max) is unknown", f.name)
+		}
+		f.status.TargetRPM = int(target)
+		f.status.TargetSpeed = units.Clamp(units.FromRPM(int(target), f.maxRPM))
+		factor = f.status.TargetSpeed
+
+		if f.fanTargetPath == "" {
+			if f.status.RPM < 0 {
+				return fmt.Errorf("cannot use rpm curve on %s: fan speed (fan1_input) cannot be read", f.name)
+			}
+			// Closed loop: Correct the current speed by the difference to the target RPM
+			factor = *lastSpeed + closedLoopGain*units.FromRPM(int(target)-f.status.RPM, f.maxRPM)
+		}
+	}
 
 	delta := factor - *lastSpeed
 	if delta > 0 && delta > maxUp {
@@ -153,9 +350,276 @@ func (f *FanControl) byCurve(temp float32, lastSpeed *float32, ui ui.UI, pwmPath
 	} else if delta < 0 && delta < 0-maxDown {
 		factor = *lastSpeed - maxDown
 	}
+	factor = units.Clamp(factor)
 
-	setSpeed(ui, pwmPath, factor)
+	if curve.IsRPM() && f.fanTargetPath != "" {
+		err = f.setTargetRPM(factor, debug.ReasonCurve)
+	} else {
+		err = f.setSpeed(factor, debug.ReasonCurve)
+	}
+	if err != nil {
+		return err
+	}
 	*lastSpeed = factor
+	return nil
+}
+
+// failsafe sets the fan to full speed, bypassing curve and step limits
+func (f *FanControl) failsafe(temp, failsafeTemp float32) {
+	if f.status.State != status.StateFailsafe {
+		f.ui.Message(fmt.Sprintf("Temperature %2.0f° reached failsafe temperature %2.0f° on %s\n", temp, failsafeTemp, f.name))
+	}
+	f.setState(status.StateFailsafe)
+	f.status.TargetSpeed = 1
+
+	err := f.setFanMode(FANMODE_MANUAL, debug.ReasonFailsafe)
+	if err == nil {
+		err = f.setSpeed(1, debug.ReasonFailsafe)
+	}
+	if err != nil {
+		f.status.WriteErrors++
+		f.ui.Message(err.Error() + "\n")
+		// If the speed cannot be set, the driver's automatic mode is the safest option
+		f.writeFanMode(FANMODE_AUTO, debug.ReasonFailsafe)
+	}
+}
+
+// manual sets the speed fixed by the user and returns whether it was set
+func (f *FanControl) manual(speed float32) bool {
+	f.status.TargetSpeed = speed
+	if f.status.State == status.StateManual && units.PWM(f.status.Speed) == units.PWM(speed) {
+		// Already set, the speed read from pwm1 is rounded
+		return true
+	}
+
+	err := f.setFanMode(FANMODE_MANUAL, debug.ReasonUser)
+	if err == nil {
+		err = f.setSpeed(speed, debug.ReasonUser)
+	}
+	if err != nil {
+		f.status.WriteErrors++
+		f.ui.Message(err.Error() + "\n")
+		f.setState(status.StateFailsafe)
+		f.writeFanMode(FANMODE_AUTO, debug.ReasonFailsafe)
+		return false
+	}
+	f.setState(status.StateManual)
+	return true
+}
+
+// updatePowerCap sets the configured power limit, clamped to the range of the driver, and reads the power draw
+func (f *FanControl) updatePowerCap() {
+	f.status.Power = -1
+	if microWatts, err := readInt(f.powerCap.inputPath); err == nil {
+		f.status.Power = units.Watts(microWatts)
+	}
+	if f.powerCap.path == "" {
+		return
+	}
+
+	current, err := readInt(f.powerCap.path)
+	if err != nil {
+		debug.Logger.Debug("cannot read power limit", "device", f.name, "error", err.Error())
+		return
+	}
+	f.status.PowerCap = units.Watts(current)
+	f.status.PowerCapMin = units.Watts(f.powerCap.min)
+	f.status.PowerCapMax = units.Watts(f.powerCap.max)
+
+	want := f.powerCap.original
+	if watts := f.config.DevicePowerCap(f.name); watts > 0 {
+		want = f.powerCap.clamp(units.MicroWatts(watts))
+	} else if !f.powerCap.changed {
+		// The limit of the driver is kept, even if it was changed by someone else
+		return
+	}
+	if want == current || want == f.powerCap.failed {
+		return
+	}
+
+	if f.writePowerCap(want, debug.ReasonUser) {
+		f.powerCap.changed = want != f.powerCap.original
+		f.status.PowerCap = units.Watts(want)
+	}
+}
+
+// writePowerCap writes the power limit in µW and returns whether it was set
+func (f *FanControl) writePowerCap(microWatts int64, reason debug.Reason) bool {
+	err := f.writeSysfs(f.powerCap.path, strconv.FormatInt(microWatts, 10)+"\n", reason)
+	if err != nil {
+		f.status.WriteErrors++
+		f.ui.Message(err.Error() + "\n")
+		f.powerCap.failed = microWatts
+		return false
+	}
+	f.powerCap.failed = 0
+	return true
+}
+
+// updatePowerProfile selects the configured entry of pp_power_profile_mode and reads the entries of the card
+func (f *FanControl) updatePowerProfile() {
+	if f.powerProfile.path == "" {
+		return
+	}
+	data, err := os.ReadFile(f.powerProfile.path)
+	if err != nil {
+		debug.Logger.Debug("cannot read power profile mode", "device", f.name, "error", err.Error())
+		return
+	}
+	modes, active := powerprofile.Parse(string(data))
+	f.status.PowerProfileModes = powerprofile.Names(modes)
+	f.status.PowerProfileMode = active
+
+	want := f.config.DevicePowerProfileMode(f.name)
+	if want == "" {
+		if !f.powerProfile.changed {
+			// The mode of the driver is kept, even if it was changed by someone else
+			return
+		}
+		want = f.powerProfile.original
+	}
+	if want == active || want == f.powerProfile.failed {
+		return
+	}
+
+	if f.selectPowerProfile(want, debug.ReasonUser) {
+		f.powerProfile.changed = f.powerProfile.original != "" && want != f.powerProfile.original
+		f.status.PowerProfileMode = want
+	}
+}
+
+// selectPowerProfile writes the index of the entry of pp_power_profile_mode and returns whether it was selected
+func (f *FanControl) selectPowerProfile(name string, reason debug.Reason) bool {
+	data, _ := os.ReadFile(f.powerProfile.path)
+	modes, _ := powerprofile.Parse(string(data))
+	mode, ok := powerprofile.Find(modes, name)
+	if !ok {
+		f.ui.Message(fmt.Sprintf("Power profile mode %s is not available on %s, use one of %s\n", name, f.name, strings.Join(powerprofile.Names(modes), ", ")))
+		f.powerProfile.failed = name
+		return false
+	}
+
+	err := f.writeSysfs(f.powerProfile.path, strconv.Itoa(mode.Index)+"\n", reason)
+	if err != nil {
+		f.status.WriteErrors++
+		f.ui.Message(fmt.Sprintf("%s (some cards only accept it in power mode \"manual\")\n", err.Error()))
+		f.powerProfile.failed = name
+		return false
+	}
+	f.powerProfile.failed = ""
+	return true
+}
+
+// clamp limits a power limit to the range of the driver, the range is ignored if the driver does not report it
+func (p powerCap) clamp(microWatts int64) int64 {
+	if p.min > 0 && microWatts < p.min {
+		return p.min
+	}
+	if p.max > 0 && microWatts > p.max {
+		return p.max
+	}
+	return microWatts
+}
+
+func (f *FanControl) setState(state status.State) {
+	if state == status.StateFailsafe && f.status.State != status.StateFailsafe {
+		f.status.FailsafeEvents++
+	}
+	f.status.State = state
+}
+
+func (f *FanControl) setSpeed(factor float32, reason debug.Reason) error {
+	factor = units.Clamp(factor)
+	value := strconv.Itoa(units.PWM(factor)) + "\n"
+
+	err := f.writeSysfs(f.pwmPath, value, reason)
+	if err != nil {
+		return err
+	}
+	f.status.Speed = factor
+	f.ui.Speed(f.name, factor)
+	return nil
+}
+
+// setTargetRPM lets the driver regulate the fan to the speed (via fan1_target)
+func (f *FanControl) setTargetRPM(factor float32, reason debug.Reason) error {
+	factor = units.Clamp(factor)
+	value := strconv.Itoa(units.RPM(factor, f.maxRPM)) + "\n"
+
+	err := f.writeSysfs(f.fanTargetPath, value, reason)
+	if err != nil {
+		return err
+	}
+	f.status.Speed = factor
+	f.ui.Speed(f.name, factor)
+	return nil
+}
+
+// setFanMode writes pwm1_enable only if it is not in the mode already, so the audit log records changes of the
+// mode instead of a write every check
+func (f *FanControl) setFanMode(mode string, reason debug.Reason) error {
+	current, err := os.ReadFile(f.fanModePath)
+	if err == nil && strings.TrimSpace(string(current)) == mode {
+		return nil
+	}
+	return f.writeSysfs(f.fanModePath, mode, reason)
+}
+
+func (f *FanControl) writeFanMode(mode string, reason debug.Reason) {
+	err := f.setFanMode(mode, reason)
+	if err != nil {
+		f.status.WriteErrors++
+		f.ui.Message(err.Error() + "\n")
+	}
+}
+
+// writeSysfs writes a value to a file of the device and records it in the audit log
+func (f *FanControl) writeSysfs(filePath, value string, reason debug.Reason) error {
+	oldValue, _ := os.ReadFile(filePath)
+	err := writeFile(filePath, value)
+	debug.Audit(f.name, filePath, strings.TrimSpace(string(oldValue)), strings.TrimSpace(value), reason, err)
+	return err
+}
+
+func (f *FanControl) publish() {
+	for _, listener := range f.listeners {
+		listener.Update(f.status)
+	}
+}
+
+// readTempChannels reads all temperature channels, the value of temp1 has already been read
+func (f *FanControl) readTempChannels(temp1 float32) []status.Temperature {
+	temps := make([]status.Temperature, 0, len(f.tempChannels))
+	for _, channel := range f.tempChannels {
+		if channel.inputPath == f.tempInputPath {
+			temps = append(temps, status.Temperature{Channel: channel.label, Value: temp1})
+			continue
+		}
+		value, err := readInt(channel.inputPath)
+		if err != nil {
+			debug.Logger.Debug("cannot read temperature channel", "device", f.name, "file", channel.inputPath, "error", err.Error())
+			continue
+		}
+		temps = append(temps, status.Temperature{Channel: channel.label, Value: float32(value) / 1000})
+	}
+	return temps
+}
+
+// findTempChannels returns all temp?_input files of the hwmon directory with their labels
+func findTempChannels(hwmonDirPath string) []tempChannel {
+	inputPaths, _ := filepath.Glob(path.Join(hwmonDirPath, "temp*_input"))
+	sort.Strings(inputPaths)
+
+	channels := make([]tempChannel, 0, len(inputPaths))
+	for _, inputPath := range inputPaths {
+		label := strings.TrimSuffix(path.Base(inputPath), "_input")
+		data, err := os.ReadFile(strings.TrimSuffix(inputPath, "_input") + "_label")
+		if err == nil && strings.TrimSpace(string(data)) != "" {
+			label = strings.TrimSpace(string(data))
+		}
+		channels = append(channels, tempChannel{label: label, inputPath: inputPath})
+	}
+	return channels
 }
 
 func fileExists(filePath string) bool {
@@ -171,109 +635,80 @@ func fileExists(filePath string) bool {
 	return true
 }
 
-func readTemp(ui ui.UI, filePath string) float32 {
+func readInt(filePath string) (int64, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		ui.Fatal(configuration.ExitCodeReadTemperature, fmt.Sprintf("Error reading temperature from %s: %s\n", filePath, err.Error()))
+		return 0, err
 	}
 
-	str := strings.TrimSpace(string(data))
-	temp, err := strconv.ParseInt(str, 10, 64)
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func readTemp(ui ui.UI, filePath string) float32 {
+	temp, err := readInt(filePath)
 	if err != nil {
 		ui.Fatal(configuration.ExitCodeReadTemperature, fmt.Sprintf("Error reading temperature from %s: %s\n", filePath, err.Error()))
 	}
 
 	fTemp := float32(temp) / 1000
-	debug.Log("Read temperature %f to %s\n", fTemp, filePath)
+	debug.Logger.Debug("read temperature", "file", filePath, "value", fTemp)
 	return fTemp
 }
 
 func readSpeed(ui ui.UI, filePath string) float32 {
-	data, err := os.ReadFile(filePath)
+	speed, err := readInt(filePath)
 	if err != nil {
 		ui.Fatal(configuration.ExitCodeReadSpeed, fmt.Sprintf("Error reading temperature from %s: %s\n", filePath, err.Error()))
 	}
 
-	str := strings.TrimSpace(string(data))
-	temp, err := strconv.ParseInt(str, 10, 64)
-	if err != nil {
-		ui.Fatal(configuration.ExitCodeReadSpeed, fmt.Sprintf("Error reading temperature from %s: %s\n", filePath, err.Error()))
-	}
-
-	fSpeed := float32(temp) / 255
-	debug.Log("Read fan speed %f from %s\n", fSpeed, filePath)
+	fSpeed := units.FromPWM(float32(speed))
+	debug.Logger.Debug("read fan speed", "file", filePath, "value", fSpeed)
 
 	return fSpeed
 }
 
-func writeFile(ui ui.UI, filePath string, value string) {
-	file, err := os.OpenFile(filePath, os.O_WRONLY, os.ModePerm)
+// readRPM returns the current fan speed in revolutions per minute or -1 if it cannot be read
+func readRPM(filePath string) int {
+	rpm, err := readInt(filePath)
 	if err != nil {
-		ui.Fatal(configuration.ExitCodeWriteFile, fmt.Sprintf("Error writing to %s: %s\n", filePath, err.Error()))
+		return -1
 	}
 
-	_, err = file.Write([]byte(value))
-	if err != nil {
-		ui.Fatal(configuration.ExitCodeWriteFile, fmt.Sprintf("Error writing to %s: %s\n", filePath, err.Error()))
-	}
+	debug.Logger.Debug("read fan rpm", "file", filePath, "value", rpm)
+	return int(rpm)
 }
 
-func setSpeed(ui ui.UI, filePath string, factor float32) {
-	if factor > 1 {
-		factor = 1
-	} else if factor < 0 {
-		factor = 0
-	}
-	value := strconv.FormatInt(int64(factor*255), 10) + "\n"
-	debug.Log("Writing %s to %s\n", strings.TrimSpace(value), filePath)
-
+func writeFile(filePath string, value string) error {
 	file, err := os.OpenFile(filePath, os.O_WRONLY, os.ModePerm)
 	if err != nil {
-		ui.Fatal(configuration.ExitCodeSpeedWrite, fmt.Sprintf("Error writing to %s: %s\n", filePath, err.Error()))
+		return fmt.Errorf("error writing to %s: %s", filePath, err.Error())
 	}
+	defer file.Close()
 
 	_, err = file.Write([]byte(value))
 	if err != nil {
-		ui.Fatal(configuration.ExitCodeSpeedWrite, fmt.Sprintf("Error writing to %s: %s\n", filePath, err.Error()))
+		return fmt.Errorf("error writing to %s: %s", filePath, err.Error())
 	}
-
-	debug.Log("Wrote fan speed %f to %s\n", factor, filePath)
-
-	ui.Speed(factor)
+	return nil
 }
 
 func calculateStep(temp float32, lowEntry, highEntry configuration.Entry) float32 {
 	// Interpolate between steps
 	smallerTemp := lowEntry.Temp
-	smallerSpeed := lowEntry.Speed
+	smallerSpeed := lowEntry.Value()
 
 	relTemp := (temp - smallerTemp) / (highEntry.Temp - smallerTemp)
-	relSpeed := relTemp*(highEntry.Speed-smallerSpeed) + smallerSpeed
+	relSpeed := relTemp*(highEntry.Value()-smallerSpeed) + smallerSpeed
 
 	return relSpeed
 }
 
-func writePowerMode(powerModePath, mode string) error {
-	file, err := os.OpenFile(powerModePath, os.O_WRONLY, os.ModePerm)
-	if err != nil {
-		return fmt.Errorf("error writing to %s: %s", powerModePath, err.Error())
-	}
-
-	_, err = file.Write([]byte(mode))
-	if err != nil {
-		return fmt.Errorf("error writing to %s: %s", powerModePath, err.Error())
-	}
-
-	debug.Log("Written %s to %s\n", mode, powerModePath)
-	return nil
-}
-
 func readPowerMode(powerModePath string) (string, error) {
 	data, err := os.ReadFile(powerModePath)
 	if err != nil {
 		return "", fmt.Errorf("error reading temperature from %s: %s", powerModePath, err.Error())
 	}
 
-	debug.Log("Read power mode: %s", data)
+	debug.Logger.Debug("read power mode", "file", powerModePath, "value", strings.TrimSpace(string(data)))
 	return strings.TrimSpace(string(data)), nil
 }
@@ -0,0 +1,140 @@
+// Package history keeps the recent status of all devices in memory for charts in the user interfaces
+package history
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirion/fanmi/app/status"
+)
+
+// Longest time range the user interfaces show
+const MaxAge = time.Hour
+
+// Event kinds
+const (
+	EventPowerMode = "powerMode"
+	EventCurve     = "curve"
+)
+
+// Sample is the status of a device at one point in time
+type Sample struct {
+	Time time.Time
+	// All temperature channels of the device in °C
+	Temperatures []status.Temperature
+	// Speed that was written (0-1)
+	Speed float32
+	// Fan speed in revolutions per minute, -1 if not available
+	RPM int
+}
+
+// Event marks a change of power mode or curve
+type Event struct {
+	Time   time.Time
+	Device string
+	// EventPowerMode or EventCurve
+	Kind  string
+	Value string
+}
+
+// Buffer keeps the samples and events of all devices for a limited time, it can be used as status.Listener
+type Buffer struct {
+	maxAge time.Duration
+
+	mutex   sync.RWMutex
+	samples map[string][]Sample
+	events  []Event
+	last    map[string]status.Status
+}
+
+// NewBuffer creates a buffer that keeps samples and events for maxAge
+func NewBuffer(maxAge time.Duration) *Buffer {
+	return &Buffer{
+		maxAge:  maxAge,
+		samples: make(map[string][]Sample),
+		events:  make([]Event, 0),
+		last:    make(map[string]status.Status),
+	}
+}
+
+// Update adds the status of a device, changes of power mode and curve are recorded as events
+func (b *Buffer) Update(s status.Status) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.samples[s.Device] = append(b.samples[s.Device], Sample{
+		Time:         s.Time,
+		Temperatures: slices.Clone(s.Temperatures),
+		Speed:        s.Speed,
+		RPM:          s.RPM,
+	})
+
+	if last, ok := b.last[s.Device]; ok {
+		if last.PowerMode != s.PowerMode {
+			b.events = append(b.events, Event{Time: s.Time, Device: s.Device, Kind: EventPowerMode, Value: s.PowerMode})
+		}
+		if last.Curve != s.Curve {
+			b.events = append(b.events, Event{Time: s.Time, Device: s.Device, Kind: EventCurve, Value: s.Curve})
+		}
+	}
+	b.last[s.Device] = s
+
+	b.prune(s.Time.Add(-b.maxAge))
+}
+
+// prune removes samples and events before the time
+func (b *Buffer) prune(before time.Time) {
+	for device, samples := range b.samples {
+		i := sort.Search(len(samples), func(i int) bool {
+			return !samples[i].Time.Before(before)
+		})
+		if i > 0 {
+			b.samples[device] = slices.Clone(samples[i:])
+		}
+	}
+
+	i := sort.Search(len(b.events), func(i int) bool {
+		return !b.events[i].Time.Before(before)
+	})
+	if i > 0 {
+		b.events = slices.Clone(b.events[i:])
+	}
+}
+
+// Devices returns the names of all devices with samples, sorted
+func (b *Buffer) Devices() []string {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	devices := make([]string, 0, len(b.samples))
+	for device := range b.samples {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+// Samples returns the samples of a device since the time, oldest first
+func (b *Buffer) Samples(device string, since time.Time) []Sample {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	samples := b.samples[device]
+	i := sort.Search(len(samples), func(i int) bool {
+		return !samples[i].Time.Before(since)
+	})
+	return slices.Clone(samples[i:])
+}
+
+// Events returns the power mode and curve changes of all devices since the time, oldest first
+func (b *Buffer) Events(since time.Time) []Event {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	i := sort.Search(len(b.events), func(i int) bool {
+		return !b.events[i].Time.Before(since)
+	})
+	return slices.Clone(b.events[i:])
+}
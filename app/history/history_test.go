@@ -0,0 +1,101 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirion/fanmi/app/status"
+)
+
+func TestBuffer(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	tests := []struct {
+		name        string
+		updates     []status.Status
+		since       time.Time
+		wantDevices []string
+		wantSamples map[string]int
+		wantEvents  []Event
+	}{
+		{
+			name: "Samples per device",
+			updates: []status.Status{
+				{Device: "card0", Time: at(0), Speed: 0.2, PowerMode: "auto", Curve: "default"},
+				{Device: "card1", Time: at(0), Speed: 0.3, PowerMode: "auto", Curve: "default"},
+				{Device: "card0", Time: at(1), Speed: 0.4, PowerMode: "auto", Curve: "default"},
+			},
+			since:       at(0),
+			wantDevices: []string{"card0", "card1"},
+			wantSamples: map[string]int{"card0": 2, "card1": 1},
+			wantEvents:  []Event{},
+		},
+		{
+			name: "Power mode and curve changes",
+			updates: []status.Status{
+				{Device: "card0", Time: at(0), PowerMode: "auto", Curve: "default"},
+				{Device: "card0", Time: at(1), PowerMode: "low", Curve: "default"},
+				{Device: "card0", Time: at(2), PowerMode: "low", Curve: "quiet"},
+			},
+			since:       at(0),
+			wantDevices: []string{"card0"},
+			wantSamples: map[string]int{"card0": 3},
+			wantEvents: []Event{
+				{Time: at(1), Device: "card0", Kind: EventPowerMode, Value: "low"},
+				{Time: at(2), Device: "card0", Kind: EventCurve, Value: "quiet"},
+			},
+		},
+		{
+			name: "Old samples and events are removed",
+			updates: []status.Status{
+				{Device: "card0", Time: at(0), Curve: "default"},
+				{Device: "card0", Time: at(5), Curve: "quiet"},
+				{Device: "card0", Time: at(61), Curve: "quiet"},
+				{Device: "card0", Time: at(62), Curve: "loud"},
+			},
+			since:       at(0),
+			wantDevices: []string{"card0"},
+			wantSamples: map[string]int{"card0": 3},
+			wantEvents: []Event{
+				{Time: at(5), Device: "card0", Kind: EventCurve, Value: "quiet"},
+				{Time: at(62), Device: "card0", Kind: EventCurve, Value: "loud"},
+			},
+		},
+		{
+			name: "Since",
+			updates: []status.Status{
+				{Device: "card0", Time: at(0), Curve: "default"},
+				{Device: "card0", Time: at(5), Curve: "quiet"},
+				{Device: "card0", Time: at(10), Curve: "quiet"},
+			},
+			since:       at(6),
+			wantDevices: []string{"card0"},
+			wantSamples: map[string]int{"card0": 1},
+			wantEvents:  []Event{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := NewBuffer(time.Hour)
+			for _, update := range tt.updates {
+				buffer.Update(update)
+			}
+
+			if got := buffer.Devices(); !reflect.DeepEqual(got, tt.wantDevices) {
+				t.Errorf("Devices() = %v, want %v", got, tt.wantDevices)
+			}
+			for device, want := range tt.wantSamples {
+				if got := buffer.Samples(device, tt.since); len(got) != want {
+					t.Errorf("Samples(%s) = %d samples, want %d", device, len(got), want)
+				}
+			}
+			if got := buffer.Events(tt.since); !reflect.DeepEqual(got, tt.wantEvents) {
+				t.Errorf("Events() = %v, want %v", got, tt.wantEvents)
+			}
+		})
+	}
+}
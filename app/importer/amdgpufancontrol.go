@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/units"
+)
+
+// importAmdgpuFancontrol reads /etc/amdgpu-fancontrol.cfg, temperatures and hysteresis are given in millidegrees,
+// speeds as pwm1 values
+func importAmdgpuFancontrol(data []byte) (*Result, error) {
+	variables, err := parseShellVariables(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	var temps, pwms []string
+	for _, v := range variables {
+		switch v.name {
+		case "TEMPS":
+			temps = v.values
+		case "PWMS":
+			pwms = v.values
+		case "HYSTERESIS":
+			hysteresis, err := parseNumber(v)
+			if err != nil {
+				return nil, err
+			}
+			result.MinChange = millidegrees(hysteresis)
+		case "SLEEP_INTERVAL":
+			interval, err := parseNumber(v)
+			if err != nil {
+				return nil, err
+			}
+			result.CheckIntervalMs = uint32(interval * 1000)
+		case "DEBUG":
+			result.untranslated("%s: use -v or log.level \"debug\" instead", v.name)
+		case "FILE_PWM", "FILE_FANMODE", "FILE_TEMP":
+			result.untranslated("%s: fanmi finds the sysfs files of the graphics card itself", v.name)
+		default:
+			result.untranslated("%s: unknown setting", v.name)
+		}
+	}
+
+	if len(temps) != len(pwms) {
+		return nil, fmt.Errorf("TEMPS has %d entries, but PWMS has %d", len(temps), len(pwms))
+	}
+	if len(temps) == 0 {
+		return result, nil
+	}
+
+	curve := make(configuration.Values, len(temps))
+	for i := range temps {
+		temp, err := strconv.ParseFloat(temps[i], 32)
+		if err != nil {
+			return nil, fmt.Errorf("TEMPS[%d]: '%s' is not a number", i, temps[i])
+		}
+		pwm, err := strconv.ParseFloat(pwms[i], 32)
+		if err != nil {
+			return nil, fmt.Errorf("PWMS[%d]: '%s' is not a number", i, pwms[i])
+		}
+		curve[i] = configuration.Entry{Temp: millidegrees(float32(temp)), Speed: units.FromPWM(float32(pwm))}
+	}
+	result.Curves = map[string]configuration.Values{FormatAmdgpuFancontrol: curve}
+	return result, nil
+}
+
+// millidegrees converts to °C
+func millidegrees(value float32) float32 {
+	return value / 1000
+}
+
+func parseNumber(v variable) (float32, error) {
+	if len(v.values) != 1 {
+		return 0, fmt.Errorf("line %d: %s must be a single number", v.line, v.name)
+	}
+	number, err := strconv.ParseFloat(v.values[0], 32)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: %s: '%s' is not a number", v.line, v.name, v.values[0])
+	}
+	return float32(number), nil
+}
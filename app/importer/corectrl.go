@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/units"
+)
+
+// importCoreCtrl reads a CoreCtrl profile. Exported profiles (.ccpro) are zip archives containing the profile XML,
+// the fan curve points are given in °C and percent.
+func importCoreCtrl(data []byte) (*Result, error) {
+	if isZip(data) {
+		var err error
+		data, err = coreCtrlProfile(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{Curves: make(map[string]configuration.Values)}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	gpu := -1
+	var curve configuration.Values
+	// Names of the enclosing elements
+	parents := make([]string, 0)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error parsing CoreCtrl profile: %s", err.Error())
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			parent := ""
+			if len(parents) > 0 {
+				parent = parents[len(parents)-1]
+			}
+			parents = append(parents, element.Name.Local)
+
+			attributes := make(map[string]string)
+			for _, a := range element.Attr {
+				attributes[a.Name.Local] = a.Value
+			}
+
+			switch element.Name.Local {
+			case "GPU":
+				gpu++
+			case "AMD_FAN_MODE":
+				if attributes["mode"] != "AMD_FAN_CURVE" {
+					result.untranslated("GPU %d: fan mode %s is active, the curve is imported anyway", gpu, attributes["mode"])
+				}
+			case "AMD_FAN_CURVE":
+				curve = make(configuration.Values, 0)
+				if attributes["fanStop"] == "true" {
+					result.untranslated("GPU %d: fanStop (start at %s%%) is not supported, the curve is used as is", gpu, attributes["fanStartValue"])
+				}
+			case "POINT":
+				if curve == nil {
+					continue
+				}
+				temp, errTemp := strconv.ParseFloat(attributes["temp"], 32)
+				percent, errPercent := strconv.ParseFloat(attributes["pwm"], 32)
+				if errTemp != nil || errPercent != nil {
+					return nil, fmt.Errorf("GPU %d: invalid curve point temp=\"%s\" pwm=\"%s\"", gpu, attributes["temp"], attributes["pwm"])
+				}
+				curve = append(curve, configuration.Entry{Temp: float32(temp), Speed: units.FromPercent(float32(percent))})
+			default:
+				if parent == "GPU" && attributes["active"] == "true" {
+					result.untranslated("GPU %d: %s is not supported by fanmi", gpu, element.Name.Local)
+				}
+			}
+
+		case xml.EndElement:
+			parents = parents[:len(parents)-1]
+			if element.Name.Local == "AMD_FAN_CURVE" && len(curve) > 0 {
+				result.Curves[coreCtrlCurveName(gpu)] = curve
+				curve = nil
+			}
+		}
+	}
+	return result, nil
+}
+
+// coreCtrlProfile returns the profile XML of a .ccpro archive
+func coreCtrlProfile(data []byte) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(content), []byte("<")) {
+			return content, nil
+		}
+	}
+	return nil, fmt.Errorf("no profile found in CoreCtrl archive")
+}
+
+func coreCtrlCurveName(gpu int) string {
+	if gpu <= 0 {
+		return FormatCoreCtrl
+	}
+	return fmt.Sprintf("%s-gpu%d", FormatCoreCtrl, gpu)
+}
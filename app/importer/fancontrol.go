@@ -0,0 +1,123 @@
+package importer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/units"
+)
+
+// importFancontrol reads /etc/fancontrol of fancontrol(8). Every setting but INTERVAL holds space separated
+// pwm=value pairs, one for each controlled fan.
+func importFancontrol(data []byte) (*Result, error) {
+	variables, err := parseShellVariables(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	// Values by setting and pwm output
+	settings := make(map[string]map[string]float32)
+	for _, v := range variables {
+		switch v.name {
+		case "INTERVAL":
+			interval, err := parseNumber(v)
+			if err != nil {
+				return nil, err
+			}
+			result.CheckIntervalMs = uint32(interval * 1000)
+		case "MINTEMP", "MAXTEMP", "MINSTART", "MINSTOP", "MINPWM", "MAXPWM":
+			settings[v.name] = make(map[string]float32)
+			for _, pair := range v.values {
+				pwm, value, ok := strings.Cut(pair, "=")
+				number, err := strconv.ParseFloat(value, 32)
+				if !ok || err != nil {
+					return nil, fmt.Errorf("line %d: %s: expected pwm=number, got '%s'", v.line, v.name, pair)
+				}
+				settings[v.name][pwm] = float32(number)
+			}
+		case "FCTEMPS":
+			for _, pair := range v.values {
+				pwm, sensor, _ := strings.Cut(pair, "=")
+				result.untranslated("%s: %s follows %s, fanmi uses the temperature of the graphics card", v.name, pwm, sensor)
+			}
+		case "AVERAGE":
+			result.untranslated("%s: fanmi does not average temperatures, use minChange to dampen changes", v.name)
+		case "DEVPATH", "DEVNAME", "FCFANS":
+			// Identify the devices, fanmi finds the graphics card itself
+		default:
+			result.untranslated("%s: unknown setting", v.name)
+		}
+	}
+
+	pwms := make([]string, 0, len(settings["MINTEMP"]))
+	for pwm := range settings["MINTEMP"] {
+		pwms = append(pwms, pwm)
+	}
+	sort.Strings(pwms)
+
+	result.Curves = make(map[string]configuration.Values)
+	for _, pwm := range pwms {
+		curve, err := fancontrolCurve(pwm, settings)
+		if err != nil {
+			return nil, err
+		}
+		name := FormatFancontrol
+		if len(pwms) > 1 {
+			name = strings.ReplaceAll(pwm, "/", "-")
+		}
+		result.Curves[name] = curve
+
+		if start, ok := settings["MINSTART"][pwm]; ok {
+			result.untranslated("MINSTART: %s starts at %s, fanmi does not boost stopped fans", pwm, strconv.FormatFloat(float64(start), 'f', -1, 32))
+		}
+	}
+	if len(pwms) > 1 {
+		result.untranslated("fanmi controls the fan of one graphics card, only the selected curve is used")
+	}
+	return result, nil
+}
+
+// fancontrolCurve creates the curve of one pwm output. fancontrol sets MINPWM up to MINTEMP, MAXPWM from MAXTEMP
+// on and rises linearly from MINSTOP in between, the jump to MINSTOP is spread over the first degree.
+func fancontrolCurve(pwm string, settings map[string]map[string]float32) (configuration.Values, error) {
+	value := func(name string, fallback float32) (float32, error) {
+		v, ok := settings[name][pwm]
+		if !ok && fallback < 0 {
+			return 0, fmt.Errorf("%s is missing for %s", name, pwm)
+		} else if !ok {
+			return fallback, nil
+		}
+		return v, nil
+	}
+
+	minTemp, err := value("MINTEMP", -1)
+	if err != nil {
+		return nil, err
+	}
+	maxTemp, err := value("MAXTEMP", -1)
+	if err != nil {
+		return nil, err
+	}
+	minStop, err := value("MINSTOP", -1)
+	if err != nil {
+		return nil, err
+	}
+	minPWM, _ := value("MINPWM", 0)
+	maxPWM, _ := value("MAXPWM", units.MaxPWM)
+
+	if maxTemp <= minTemp {
+		return nil, fmt.Errorf("MAXTEMP of %s must be above MINTEMP", pwm)
+	}
+
+	curve := configuration.Values{{Temp: minTemp, Speed: units.FromPWM(minPWM)}}
+	if minStop != minPWM && maxTemp-minTemp > 1 {
+		step := (maxPWM - minStop) / (maxTemp - minTemp)
+		curve = append(curve, configuration.Entry{Temp: minTemp + 1, Speed: units.FromPWM(minStop + step)})
+	}
+	curve = append(curve, configuration.Entry{Temp: maxTemp, Speed: units.FromPWM(maxPWM)})
+	return curve, nil
+}
@@ -0,0 +1,136 @@
+// Package importer translates the configuration of other Linux fan-control tools into a fanmi configuration
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sirion/fanmi/app/configuration"
+)
+
+// Supported source formats
+const (
+	// amdgpu-fancontrol (bash configuration with TEMPS and PWMS arrays)
+	FormatAmdgpuFancontrol = "amdgpu-fancontrol"
+	// CoreCtrl profile (.ccpro archive or the profile XML inside)
+	FormatCoreCtrl = "corectrl"
+	// fancontrol(8) from lm-sensors (/etc/fancontrol)
+	FormatFancontrol = "fancontrol"
+)
+
+var Formats = []string{FormatAmdgpuFancontrol, FormatCoreCtrl, FormatFancontrol}
+
+// Result of an import
+type Result struct {
+	Curves map[string]configuration.Values
+	// Curve active at start-up
+	Curve string
+	// 0 if not set by the source
+	CheckIntervalMs uint32
+	// 0 if not set by the source
+	MinChange float32
+	// Settings that have no equivalent in fanmi
+	Untranslated []string
+}
+
+// Import reads the configuration of another tool. If format is empty, it is detected from file name and content.
+func Import(fileName string, data []byte, format string) (*Result, error) {
+	if format == "" {
+		format = Detect(fileName, data)
+	}
+
+	var result *Result
+	var err error
+	switch format {
+	case FormatAmdgpuFancontrol:
+		result, err = importAmdgpuFancontrol(data)
+	case FormatCoreCtrl:
+		result, err = importCoreCtrl(data)
+	case FormatFancontrol:
+		result, err = importFancontrol(data)
+	case "":
+		return nil, fmt.Errorf("unknown format, use one of %s", strings.Join(Formats, ", "))
+	default:
+		return nil, fmt.Errorf("unknown format '%s', use one of %s", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Curves) == 0 {
+		return nil, fmt.Errorf("no fan curve found in %s configuration", format)
+	}
+
+	for _, curve := range result.Curves {
+		sort.Sort(curve)
+	}
+	// The default curve of fanmi is still defined, so the imported one has to be selected
+	if result.Curve == "" {
+		names := make([]string, 0, len(result.Curves))
+		for name := range result.Curves {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		result.Curve = names[0]
+	}
+	return result, nil
+}
+
+// Detect guesses the format of a configuration file, it returns an empty string if the format is unknown
+func Detect(fileName string, data []byte) string {
+	if strings.EqualFold(path.Ext(fileName), ".ccpro") || isZip(data) {
+		return FormatCoreCtrl
+	}
+
+	text := string(data)
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(text), "<"):
+		return FormatCoreCtrl
+	case strings.Contains(text, "TEMPS=") && strings.Contains(text, "PWMS="):
+		return FormatAmdgpuFancontrol
+	case strings.Contains(text, "FCTEMPS=") || strings.Contains(text, "MINTEMP="):
+		return FormatFancontrol
+	}
+	return ""
+}
+
+// JSON returns the configuration with the imported values only, everything else keeps the fanmi defaults
+func (r *Result) JSON() ([]byte, error) {
+	config := make(map[string]any)
+	if r.CheckIntervalMs != 0 {
+		config["checkIntervalMs"] = r.CheckIntervalMs
+	}
+	if r.MinChange != 0 {
+		config["minChange"] = r.MinChange
+	}
+	config["curve"] = r.Curve
+	config["curves"] = r.Curves
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure fanmi accepts the result
+	parsed, err := configuration.Parse(data)
+	if err == nil {
+		err = parsed.Validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("imported configuration is invalid: %s", err.Error())
+	}
+	return data, nil
+}
+
+func (r *Result) untranslated(format string, args ...any) {
+	r.Untranslated = append(r.Untranslated, fmt.Sprintf(format, args...))
+}
+
+func isZip(data []byte) bool {
+	_, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	return err == nil
+}
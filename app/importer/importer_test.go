@@ -0,0 +1,164 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/units"
+)
+
+const amdgpuFancontrolConfig = `# temperatures in degrees C * 1000
+TEMPS=( 65000 80000 90000 )
+# pwm values (0-255)
+PWMS=(      0   153   255 )
+HYSTERESIS=6000   # in mK
+SLEEP_INTERVAL=1  # in s
+DEBUG=true
+`
+
+const fancontrolConfig = `# Configuration file generated by pwmconfig
+INTERVAL=2
+DEVPATH=hwmon1=devices/pci0000:00/0000:00:03.1/0000:09:00.0
+DEVNAME=hwmon1=amdgpu
+FCTEMPS=hwmon1/pwm1=hwmon1/temp1_input
+FCFANS=hwmon1/pwm1=hwmon1/fan1_input
+MINTEMP=hwmon1/pwm1=40
+MAXTEMP=hwmon1/pwm1=80
+MINSTART=hwmon1/pwm1=150
+MINSTOP=hwmon1/pwm1=95
+`
+
+const coreCtrlConfig = `<?xml version="1.0"?>
+<PROFILE active="true" name="_global_" exe="_global_">
+ <GPU index="0" deviceid="731f" active="true">
+  <AMD_FAN_MODE mode="AMD_FAN_CURVE" active="true">
+   <AMD_FAN_AUTO active="false"/>
+   <AMD_FAN_FIXED active="false" value="64" fanStop="false" fanStartValue="54"/>
+   <AMD_FAN_CURVE active="true" fanStop="true" fanStartValue="54">
+    <CURVE>
+     <POINT temp="35" pwm="20"/>
+     <POINT temp="70" pwm="50"/>
+     <POINT temp="90" pwm="100"/>
+    </CURVE>
+   </AMD_FAN_CURVE>
+  </AMD_FAN_MODE>
+  <AMD_PM_PERFMODE mode="AMD_PM_AUTO" active="true">
+   <AMD_PM_AUTO active="true"/>
+  </AMD_PM_PERFMODE>
+ </GPU>
+</PROFILE>
+`
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name             string
+		fileName         string
+		data             []byte
+		format           string
+		want             map[string]configuration.Values
+		wantInterval     uint32
+		wantMinChange    float32
+		wantUntranslated []string
+		wantErr          string
+	}{
+		{
+			name:     "amdgpu-fancontrol",
+			fileName: "/etc/amdgpu-fancontrol.cfg",
+			data:     []byte(amdgpuFancontrolConfig),
+			want: map[string]configuration.Values{"amdgpu-fancontrol": {
+				{Temp: 65, Speed: 0}, {Temp: 80, Speed: units.FromPWM(153)}, {Temp: 90, Speed: 1},
+			}},
+			wantInterval:     1000,
+			wantMinChange:    6,
+			wantUntranslated: []string{"DEBUG: use -v"},
+		},
+		{
+			name:     "fancontrol",
+			fileName: "/etc/fancontrol",
+			data:     []byte(fancontrolConfig),
+			want: map[string]configuration.Values{"fancontrol": {
+				{Temp: 40, Speed: 0}, {Temp: 41, Speed: units.FromPWM(99)}, {Temp: 80, Speed: 1},
+			}},
+			wantInterval:     2000,
+			wantUntranslated: []string{"FCTEMPS: hwmon1/pwm1 follows hwmon1/temp1_input", "MINSTART: hwmon1/pwm1 starts at 150"},
+		},
+		{
+			name:     "CoreCtrl profile",
+			fileName: "profile",
+			data:     []byte(coreCtrlConfig),
+			want: map[string]configuration.Values{"corectrl": {
+				{Temp: 35, Speed: 0.2}, {Temp: 70, Speed: 0.5}, {Temp: 90, Speed: 1},
+			}},
+			wantUntranslated: []string{"GPU 0: fanStop (start at 54%)", "GPU 0: AMD_PM_PERFMODE is not supported"},
+		},
+		{
+			name:     "CoreCtrl archive",
+			fileName: "gaming.ccpro",
+			data:     zipped(t, coreCtrlConfig),
+			want: map[string]configuration.Values{"corectrl": {
+				{Temp: 35, Speed: 0.2}, {Temp: 70, Speed: 0.5}, {Temp: 90, Speed: 1},
+			}},
+		},
+		{
+			name:    "Mismatching arrays",
+			data:    []byte("TEMPS=( 65000 80000 )\nPWMS=( 0 )\n"),
+			wantErr: "TEMPS has 2 entries, but PWMS has 1",
+		},
+		{
+			name:    "Unknown format",
+			data:    []byte("{}"),
+			wantErr: "unknown format, use one of amdgpu-fancontrol, corectrl, fancontrol",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Import(tt.fileName, tt.data, tt.format)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Import() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Import() error = %s", err.Error())
+			}
+
+			if !reflect.DeepEqual(got.Curves, tt.want) {
+				t.Errorf("Import() curves = %v, want %v", got.Curves, tt.want)
+			}
+			if got.CheckIntervalMs != tt.wantInterval {
+				t.Errorf("Import() checkIntervalMs = %d, want %d", got.CheckIntervalMs, tt.wantInterval)
+			}
+			if got.MinChange != tt.wantMinChange {
+				t.Errorf("Import() minChange = %v, want %v", got.MinChange, tt.wantMinChange)
+			}
+			untranslated := strings.Join(got.Untranslated, "\n")
+			for _, want := range tt.wantUntranslated {
+				if !strings.Contains(untranslated, want) {
+					t.Errorf("Import() untranslated = %s, want %s", untranslated, want)
+				}
+			}
+
+			_, err = got.JSON()
+			if err != nil {
+				t.Errorf("JSON() error = %s", err.Error())
+			}
+		})
+	}
+}
+
+func zipped(t *testing.T, content string) []byte {
+	buffer := &bytes.Buffer{}
+	archive := zip.NewWriter(buffer)
+	file, err := archive.Create("profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte(content))
+	archive.Close()
+	return buffer.Bytes()
+}
@@ -0,0 +1,66 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// variable of a shell-style configuration file
+type variable struct {
+	name string
+	// Whitespace separated words, or the elements of an array
+	values []string
+	line   int
+}
+
+// parseShellVariables reads NAME=value and NAME=( a b c ) assignments in the order they appear, comments and
+// empty lines are skipped
+func parseShellVariables(data []byte) ([]variable, error) {
+	variables := make([]variable, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := stripComment(scanner.Text())
+		line = strings.TrimPrefix(line, "export ")
+		if line == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected NAME=value", lineNumber)
+		}
+		v := variable{name: strings.TrimSpace(name), line: lineNumber}
+
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, "(") {
+			// Arrays may span multiple lines
+			start := lineNumber
+			for !strings.Contains(value, ")") {
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("line %d: array %s is not closed", start, v.name)
+				}
+				lineNumber++
+				value += " " + stripComment(scanner.Text())
+			}
+			value = strings.TrimSuffix(strings.TrimPrefix(value, "("), ")")
+		}
+
+		for _, word := range strings.Fields(value) {
+			v.values = append(v.values, strings.Trim(word, `"'`))
+		}
+		variables = append(variables, v)
+	}
+	return variables, scanner.Err()
+}
+
+func stripComment(line string) string {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,180 @@
+// Package keys translates the input of a terminal in raw mode into key names like "a", "space", "up" or "ctrl-c"
+package keys
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Names of the keys that are not a single printable character
+var Named = []string{
+	"space", "tab", "shift-tab", "enter", "esc", "backspace",
+	"up", "down", "left", "right", "home", "end", "pgup", "pgdown", "insert", "delete",
+	"f1", "f2", "f3", "f4", "f5", "f6", "f7", "f8", "f9", "f10", "f11", "f12",
+}
+
+// Modifiers of named keys and letters, e.g. "ctrl-up" or "alt-x"
+var Modifiers = []string{"shift-", "alt-", "ctrl-", "ctrl-shift-"}
+
+// Named keys that are sent with modifiers
+var modifiable = []string{
+	"up", "down", "left", "right", "home", "end", "pgup", "pgdown", "insert", "delete",
+	"f1", "f2", "f3", "f4", "f5", "f6", "f7", "f8", "f9", "f10", "f11", "f12",
+}
+
+// Final characters of CSI (ESC [) and SS3 (ESC O) sequences without parameter
+var finals = map[byte]string{
+	'A': "up", 'B': "down", 'C': "right", 'D': "left", 'H': "home", 'F': "end", 'Z': "shift-tab",
+	'P': "f1", 'Q': "f2", 'R': "f3", 'S': "f4",
+}
+
+// Numbers of "ESC [ n ~" sequences
+var tildes = map[string]string{
+	"1": "home", "2": "insert", "3": "delete", "4": "end", "5": "pgup", "6": "pgdown", "7": "home", "8": "end",
+	"11": "f1", "12": "f2", "13": "f3", "14": "f4", "15": "f5", "17": "f6", "18": "f7", "19": "f8",
+	"20": "f9", "21": "f10", "23": "f11", "24": "f12",
+}
+
+// Modifier parameters of CSI sequences, e.g. "ESC [ 1 ; 5 A" for ctrl-up
+var modifierParams = map[string]string{"2": "shift-", "3": "alt-", "5": "ctrl-", "6": "ctrl-shift-"}
+
+// Symbols used to show keys to the user
+var symbols = map[string]string{"up": "↑", "down": "↓", "left": "←", "right": "→"}
+
+// Split returns the keys in the input. An incomplete escape sequence at the end is returned as rest, it is
+// completed by the next read.
+func Split(input []byte) (names []string, rest []byte) {
+	names = []string{}
+	for len(input) > 0 {
+		name, size := next(input)
+		if size == 0 {
+			return names, input
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+		input = input[size:]
+	}
+	return names, nil
+}
+
+// next returns the first key of the input and its length in bytes, 0 if the input is incomplete. Unknown
+// sequences are skipped with an empty name.
+func next(input []byte) (string, int) {
+	b := input[0]
+	switch {
+	case b == 0x1b:
+		return escape(input)
+	case b == ' ':
+		return "space", 1
+	case b == '\t':
+		return "tab", 1
+	case b == '\r' || b == '\n':
+		return "enter", 1
+	case b == 0x7f || b == 0x08:
+		return "backspace", 1
+	case b >= 0x01 && b <= 0x1a:
+		return "ctrl-" + string(rune('a'+b-1)), 1
+	case b < 0x20:
+		return "", 1
+	}
+
+	if !utf8.FullRune(input) {
+		return "", 0
+	}
+	r, size := utf8.DecodeRune(input)
+	if r == utf8.RuneError {
+		return "", size
+	}
+	return string(r), size
+}
+
+// escape reads a sequence starting with ESC
+func escape(input []byte) (string, int) {
+	if len(input) == 1 {
+		// Terminals send sequences at once, so a single ESC is the key itself
+		return "esc", 1
+	}
+
+	switch input[1] {
+	case '[':
+		// CSI: parameters and a final character between '@' and '~'
+		for i := 2; i < len(input); i++ {
+			if input[i] >= '@' && input[i] <= '~' {
+				return csi(string(input[2:i]), input[i]), i + 1
+			}
+		}
+		return "", 0
+	case 'O':
+		if len(input) < 3 {
+			return "", 0
+		}
+		return finals[input[2]], 3
+	case 0x1b:
+		return "esc", 1
+	}
+
+	name, size := next(input[1:])
+	if size == 0 {
+		return "", 0
+	}
+	if name == "" || strings.Contains(name, "-") {
+		return "", size + 1
+	}
+	return "alt-" + name, size + 1
+}
+
+func csi(params string, final byte) string {
+	number, modifier, _ := strings.Cut(params, ";")
+	name := ""
+	if final == '~' {
+		name = tildes[number]
+	} else if number == "" || number == "1" {
+		name = finals[final]
+	}
+	if name == "" {
+		return ""
+	}
+	if modifier != "" {
+		prefix, ok := modifierParams[modifier]
+		if !ok {
+			return ""
+		}
+		name = prefix + name
+	}
+	return name
+}
+
+// Valid checks whether the key name can be produced by Split
+func Valid(name string) bool {
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		return r > ' ' && r != 0x7f
+	}
+	if slices.Contains(Named, name) {
+		return true
+	}
+	for _, modifier := range Modifiers {
+		key, ok := strings.CutPrefix(name, modifier)
+		if !ok {
+			continue
+		}
+		if slices.Contains(modifiable, key) {
+			return true
+		}
+		if len(key) == 1 && key[0] >= 'a' && key[0] <= 'z' {
+			// The terminal sends ctrl-h, ctrl-i, ctrl-j and ctrl-m as backspace, tab and enter
+			return modifier == "alt-" || (modifier == "ctrl-" && !strings.Contains("hijm", key))
+		}
+	}
+	return false
+}
+
+// Symbol returns a short form of the key name to show to the user
+func Symbol(name string) string {
+	if symbol, ok := symbols[name]; ok {
+		return symbol
+	}
+	return name
+}
@@ -0,0 +1,61 @@
+package keys
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantNames []string
+		wantRest  string
+	}{
+		{"Characters", "aq?+", []string{"a", "q", "?", "+"}, ""},
+		{"Named keys", " \t\r\x7f\x1b", []string{"space", "tab", "enter", "backspace", "esc"}, ""},
+		{"Control keys", "\x03\x01", []string{"ctrl-c", "ctrl-a"}, ""},
+		{"Arrow keys", "\x1b[A\x1b[B\x1bOC\x1b[D", []string{"up", "down", "right", "left"}, ""},
+		{"Tilde sequences", "\x1b[5~\x1b[3~\x1b[15~", []string{"pgup", "delete", "f5"}, ""},
+		{"Modifiers", "\x1b[1;5A\x1b[3;2~\x1bx\x1b[Z", []string{"ctrl-up", "shift-delete", "alt-x", "shift-tab"}, ""},
+		{"UTF-8", "ä€", []string{"ä", "€"}, ""},
+		{"Incomplete sequence", "a\x1b[1;", []string{"a"}, "\x1b[1;"},
+		{"Incomplete rune", "a\xc3", []string{"a"}, "\xc3"},
+		{"Unknown sequences are skipped", "\x1b[99~b\x1b[1;9A", []string{"b"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, rest := Split([]byte(tt.input))
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("Split() names = %q, want %q", names, tt.wantNames)
+			}
+			if string(rest) != tt.wantRest {
+				t.Errorf("Split() rest = %q, want %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := map[string]bool{
+		"a":             true,
+		"?":             true,
+		"space":         true,
+		"up":            true,
+		"ctrl-c":        true,
+		"ctrl-up":       true,
+		"ctrl-shift-up": true,
+		"alt-x":         true,
+		"":              false,
+		" ":             false,
+		"ctrl-m":        false,
+		"shift-space":   false,
+		"hyper-a":       false,
+		"UP":            false,
+	}
+	for name, want := range tests {
+		if got := Valid(name); got != want {
+			t.Errorf("Valid(%q) = %t, want %t", name, got, want)
+		}
+	}
+}
@@ -9,16 +9,33 @@ import (
 	"path"
 	"syscall"
 
+	"github.com/sirion/fanmi/app/api"
 	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/debug"
+	"github.com/sirion/fanmi/app/history"
+	"github.com/sirion/fanmi/app/metrics"
+	"github.com/sirion/fanmi/app/mqtt"
+	"github.com/sirion/fanmi/app/notify"
+	"github.com/sirion/fanmi/app/status"
+	"github.com/sirion/fanmi/app/telemetry"
 	"github.com/sirion/fanmi/app/ui"
 )
 
 func main() {
+	// Task: Run sub-command
+	if len(os.Args) > 1 && commands[os.Args[1]] != nil {
+		os.Exit(commands[os.Args[1]](os.Args[2:]))
+	}
+
 	// Task: Read Configuration
 	config := configuration.ReadConfig()
 
 	ui := ui.CreateUI(config.UI)
 
+	// Task: Keep recent status for charts
+	recent := history.NewBuffer(history.MaxAge)
+	ui.History(recent)
+
 	uiClosed := ui.Init(config)
 	go (func() {
 		<-uiClosed
@@ -46,16 +63,101 @@ func main() {
 	}
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 	signal.Notify(c, syscall.SIGQUIT, syscall.SIGILL, syscall.SIGTRAP, syscall.SIGABRT, syscall.SIGSTKFLT, syscall.SIGSYS)
 
 	go (func() {
 		<-c
 		config.Running = false
-		config.Active = false
+		config.SetActive(false)
 		ui.Message("Signal caught. Exiting.\n")
 	})()
 
+	// Task: Reload configuration on SIGHUP or when the file changes
+	// Successful reloads are logged by Reload, rejected ones are logged here as well, as not every UI shows messages
+	reloaded := func(err error) {
+		if err != nil {
+			debug.Logger.Warn("invalid configuration, keeping the current one", "error", err.Error())
+			ui.Message(fmt.Sprintf("Invalid configuration, keeping the current one:\n%s\n", err.Error()))
+		} else {
+			ui.Message("Configuration reloaded\n")
+		}
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go (func() {
+		for range hup {
+			reloaded(config.Reload())
+		}
+	})()
+	stopWatching, err := config.Watch(reloaded)
+	if err != nil {
+		ui.Message(fmt.Sprintf("Configuration file is not watched: %s\n", err.Error()))
+	} else {
+		defer stopWatching()
+	}
+
+	listeners := []status.Listener{recent}
+	if listener, ok := ui.(status.Listener); ok {
+		// The console UI shows all values of the status
+		listeners = append(listeners, listener)
+	}
+	if config.Metrics.Listen != "" {
+		// Task: Serve Prometheus metrics
+		exporter := metrics.NewExporter()
+		listeners = append(listeners, exporter)
+		go (func() {
+			err := exporter.ListenAndServe(config.Metrics.Listen)
+			ui.Message(fmt.Sprintf("Metrics exporter stopped: %s\n", err.Error()))
+		})()
+	}
+
+	if config.API.Listen != "" {
+		// Task: Serve HTTP API and dashboard
+		server, err := api.NewServer(config)
+		if err != nil {
+			ui.Message(fmt.Sprintf("Cannot start API: %s\n", err.Error()))
+		} else {
+			listeners = append(listeners, server)
+			go (func() {
+				err := server.ListenAndServe(config.API.Listen)
+				ui.Message(fmt.Sprintf("API stopped: %s\n", err.Error()))
+			})()
+		}
+	}
+
+	if config.MQTT.Broker != "" {
+		// Task: Connect to MQTT broker
+		client := mqtt.NewClient(config, ui.Message)
+		client.Connect()
+		defer client.Disconnect()
+		listeners = append(listeners, client)
+	}
+
+	if config.Telemetry.File != "" {
+		// Task: Write telemetry file
+		writer, err := telemetry.NewWriter(config.Telemetry.File, config.Telemetry.Format, int64(config.Telemetry.MaxSize)*1024*1024, config.Telemetry.MaxFiles, func(err error) {
+			ui.Message(err.Error() + "\n")
+		})
+		if err != nil {
+			ui.Message(fmt.Sprintf("Cannot write telemetry: %s\n", err.Error()))
+		} else {
+			defer writer.Close()
+			listeners = append(listeners, writer)
+		}
+	}
+
+	if config.Notifications.Enabled {
+		// Task: Send desktop notifications
+		sink, err := notify.NewSink()
+		if err != nil {
+			ui.Message(fmt.Sprintf("Notifications are not shown: %s\n", err.Error()))
+		}
+		notifier := notify.NewNotifier(config, sink)
+		defer notifier.Close()
+		listeners = append(listeners, notifier)
+	}
+
 	workers := make([]chan bool, 0)
 	for _, matchPath := range pwmMatches {
 		// Task: Start Monitor Routine per card
@@ -69,7 +171,11 @@ func main() {
 			continue
 		}
 
-		worker := NewFanControl(ui, deviceDirPath, hwmonDirPath, config)
+		worker := NewFanControl(ui, deviceDirPath, hwmonDirPath, config, listeners...)
+		if config.ManualSpeed >= 0 {
+			// Task: Fix speed given on the command line
+			config.SetDeviceSpeed(worker.name, config.ManualSpeed, config.ManualDuration)
+		}
 		workers = append(workers, worker.Run())
 	}
 
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/ui"
 )
 
 func Test_calculateStep(t *testing.T) {
@@ -82,6 +87,19 @@ func Test_calculateStep(t *testing.T) {
 			},
 			want: 0.15,
 		},
+		{
+			name: "RPM",
+			args: args{
+				temp: 55,
+				lowEntry: configuration.Entry{
+					Temp: 50, RPM: 1000, InRPM: true,
+				},
+				highEntry: configuration.Entry{
+					Temp: 60, RPM: 2000, InRPM: true,
+				},
+			},
+			want: 1500,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -91,3 +109,153 @@ func Test_calculateStep(t *testing.T) {
 		})
 	}
 }
+
+func TestFanControl_byCurve_rpm(t *testing.T) {
+	tests := []struct {
+		name      string
+		fanTarget bool
+		lastSpeed float32
+		rpm       string
+		wantFile  string
+		want      string
+	}{
+		// 60° => 1500 RPM of 3000 => 50%, limited by max step up
+		{"Target", true, 0.45, "1200", "fan1_target", "1500"},
+		{"Target limited", true, 0.3, "1200", "fan1_target", "1200"},
+		// 300 RPM missing => 5% more (with gain 0.5)
+		{"Closed loop", false, 0.45, "1200", "pwm1", "127"},
+		{"Closed loop down", false, 0.6, "1800", "pwm1", "140"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hwmonDirPath := t.TempDir()
+			files := map[string]string{"pwm1": "0", "pwm1_enable": "2", "fan1_input": tt.rpm, "fan1_max": "3000"}
+			if tt.fanTarget {
+				files["fan1_target"] = "0"
+			}
+			for name, value := range files {
+				os.WriteFile(path.Join(hwmonDirPath, name), []byte(value+"\n"), 0644)
+			}
+
+			config := &configuration.Configuration{
+				MaxStepUp:   10,
+				MaxStepDown: 10,
+				Curve:       configuration.Values{{Temp: 40, RPM: 500, InRPM: true}, {Temp: 80, RPM: 2500, InRPM: true}},
+			}
+			f := NewFanControl(&ui.NoUI{}, t.TempDir(), hwmonDirPath, config)
+			f.status.RPM = readRPM(f.fanInputPath)
+
+			lastSpeed := tt.lastSpeed
+			err := f.byCurve(60, &lastSpeed)
+			if err != nil {
+				t.Fatalf("byCurve() error = %s", err.Error())
+			}
+			data, _ := os.ReadFile(path.Join(hwmonDirPath, tt.wantFile))
+			if strings.TrimSpace(string(data)) != tt.want {
+				t.Errorf("byCurve() wrote %s = %s, want %s", tt.wantFile, strings.TrimSpace(string(data)), tt.want)
+			}
+			if f.status.TargetRPM != 1500 || f.status.TargetSpeed != 0.5 {
+				t.Errorf("byCurve() target = %d RPM (%f), want 1500 RPM (0.5)", f.status.TargetRPM, f.status.TargetSpeed)
+			}
+		})
+	}
+}
+
+func TestFanControl_updatePowerCap(t *testing.T) {
+	tests := []struct {
+		name        string
+		powerCap    float32
+		changed     bool
+		current     string
+		want        string
+		wantChanged bool
+	}{
+		{"Keep driver limit", 0, false, "180000000", "180000000", false},
+		{"Set limit", 150, false, "200000000", "150000000", true},
+		{"Clamp to minimum", 50, false, "200000000", "100000000", true},
+		{"Clamp to maximum", 300, false, "200000000", "250000000", true},
+		{"Restore original limit", 0, true, "150000000", "200000000", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hwmonDirPath := t.TempDir()
+			files := map[string]string{
+				"power1_cap": "200000000", "power1_cap_min": "100000000", "power1_cap_max": "250000000",
+				"power1_average": "123500000",
+			}
+			for name, value := range files {
+				os.WriteFile(path.Join(hwmonDirPath, name), []byte(value+"\n"), 0644)
+			}
+
+			config := &configuration.Configuration{PowerCap: tt.powerCap}
+			f := NewFanControl(&ui.NoUI{}, t.TempDir(), hwmonDirPath, config)
+			f.powerCap.changed = tt.changed
+			os.WriteFile(path.Join(hwmonDirPath, "power1_cap"), []byte(tt.current+"\n"), 0644)
+
+			f.updatePowerCap()
+			data, _ := os.ReadFile(path.Join(hwmonDirPath, "power1_cap"))
+			if strings.TrimSpace(string(data)) != tt.want {
+				t.Errorf("updatePowerCap() wrote power1_cap = %s, want %s", strings.TrimSpace(string(data)), tt.want)
+			}
+			if f.powerCap.changed != tt.wantChanged {
+				t.Errorf("updatePowerCap() changed = %t, want %t", f.powerCap.changed, tt.wantChanged)
+			}
+			if f.status.Power != 123.5 || f.status.PowerCapMin != 100 || f.status.PowerCapMax != 250 {
+				t.Errorf("updatePowerCap() status = %v W (%v-%v W), want 123.5 W (100-250 W)", f.status.Power, f.status.PowerCapMin, f.status.PowerCapMax)
+			}
+		})
+	}
+}
+
+func TestFanControl_updatePowerProfile(t *testing.T) {
+	table := `NUM        MODE_NAME BUSY_SET_POINT FPS USE_RLC_BUSY MIN_ACTIVE_LEVEL
+  0 BOOTUP_DEFAULT :             70      60          0              0
+  1 3D_FULL_SCREEN*:             70      60          1              3
+  2   POWER_SAVING :             90      60          0              0
+  5        COMPUTE :             30      60          0              6
+  6         CUSTOM :              0       0          0              0
+`
+	tests := []struct {
+		name        string
+		mode        string
+		changed     bool
+		want        string
+		wantChanged bool
+	}{
+		{"Keep driver mode", "", false, table, false},
+		{"Already selected", "3D_FULL_SCREEN", false, table, false},
+		{"Select mode", "COMPUTE", false, "5", true},
+		{"Not offered by the card", "VR", false, table, false},
+		{"Restore original mode", "", true, "1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deviceDirPath := t.TempDir()
+			filePath := path.Join(deviceDirPath, "pp_power_profile_mode")
+			os.WriteFile(filePath, []byte(table), 0644)
+
+			config := &configuration.Configuration{PowerProfileMode: tt.mode}
+			f := NewFanControl(&ui.NoUI{}, deviceDirPath, t.TempDir(), config)
+			f.powerProfile.changed = tt.changed
+			if tt.changed {
+				// Someone selected another mode in the meantime
+				os.WriteFile(filePath, []byte(strings.Replace(strings.Replace(table, "3D_FULL_SCREEN*", "3D_FULL_SCREEN ", 1), "POWER_SAVING ", "POWER_SAVING*", 1)), 0644)
+			}
+
+			f.updatePowerProfile()
+			// Written values replace the beginning of the file, sysfs files are replaced as a whole
+			data, _ := os.ReadFile(filePath)
+			got, _, _ := strings.Cut(string(data), "\n")
+			want, _, _ := strings.Cut(tt.want, "\n")
+			if got != want {
+				t.Errorf("updatePowerProfile() first line = %s, want %s", got, want)
+			}
+			if f.powerProfile.changed != tt.wantChanged {
+				t.Errorf("updatePowerProfile() changed = %t, want %t", f.powerProfile.changed, tt.wantChanged)
+			}
+			if !reflect.DeepEqual(f.status.PowerProfileModes, []string{"BOOTUP_DEFAULT", "3D_FULL_SCREEN", "POWER_SAVING", "COMPUTE"}) {
+				t.Errorf("updatePowerProfile() modes = %v", f.status.PowerProfileModes)
+			}
+		})
+	}
+}
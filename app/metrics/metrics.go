@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirion/fanmi/app/status"
+	"github.com/sirion/fanmi/app/units"
+)
+
+// Exporter keeps the latest status of every device and serves it in the Prometheus text format
+type Exporter struct {
+	mutex   sync.Mutex
+	devices map[string]status.Status
+}
+
+func NewExporter() *Exporter {
+	return &Exporter{
+		devices: make(map[string]status.Status),
+	}
+}
+
+// Update implements status.Listener
+func (e *Exporter) Update(s status.Status) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.devices[s.Device] = s
+}
+
+// ListenAndServe serves the metrics on /metrics at the given address, it only returns on error
+func (e *Exporter) ListenAndServe(address string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	return http.ListenAndServe(address, mux)
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.Write(w)
+}
+
+// Write writes all metrics in the Prometheus text format
+func (e *Exporter) Write(w io.Writer) {
+	e.mutex.Lock()
+	devices := make([]status.Status, 0, len(e.devices))
+	for _, s := range e.devices {
+		devices = append(devices, s)
+	}
+	e.mutex.Unlock()
+
+	sort.Slice(devices, func(a, b int) bool {
+		return devices[a].Device < devices[b].Device
+	})
+
+	header(w, "fanmi_temperature_celsius", "gauge", "Temperature of the sensor channel in degrees Celsius.")
+	for _, s := range devices {
+		for _, temp := range s.Temperatures {
+			sample(w, "fanmi_temperature_celsius", float64(temp.Value), "device", s.Device, "channel", temp.Channel)
+		}
+	}
+
+	header(w, "fanmi_fan_pwm_percent", "gauge", "Fan speed written to pwm1 in percent.")
+	for _, s := range devices {
+		sample(w, "fanmi_fan_pwm_percent", float64(units.Percent(s.Speed)), "device", s.Device)
+	}
+
+	header(w, "fanmi_fan_rpm", "gauge", "Fan speed in revolutions per minute.")
+	for _, s := range devices {
+		if s.RPM >= 0 {
+			sample(w, "fanmi_fan_rpm", float64(s.RPM), "device", s.Device)
+		}
+	}
+
+	header(w, "fanmi_power_watts", "gauge", "Power draw of the device in watts.")
+	for _, s := range devices {
+		if s.Power >= 0 {
+			sample(w, "fanmi_power_watts", float64(s.Power), "device", s.Device)
+		}
+	}
+
+	header(w, "fanmi_power_cap_watts", "gauge", "Power limit of the device in watts.")
+	for _, s := range devices {
+		if s.PowerCap > 0 {
+			sample(w, "fanmi_power_cap_watts", float64(s.PowerCap), "device", s.Device)
+		}
+	}
+
+	header(w, "fanmi_power_mode_info", "gauge", "Current power mode of the device.")
+	for _, s := range devices {
+		if s.PowerMode != "" {
+			sample(w, "fanmi_power_mode_info", 1, "device", s.Device, "mode", s.PowerMode)
+		}
+	}
+
+	header(w, "fanmi_power_profile_mode_info", "gauge", "Current entry of pp_power_profile_mode of the device.")
+	for _, s := range devices {
+		if s.PowerProfileMode != "" {
+			sample(w, "fanmi_power_profile_mode_info", 1, "device", s.Device, "mode", s.PowerProfileMode)
+		}
+	}
+
+	header(w, "fanmi_curve_info", "gauge", "Fan curve currently used for the device.")
+	for _, s := range devices {
+		sample(w, "fanmi_curve_info", 1, "device", s.Device, "curve", s.Curve)
+	}
+
+	header(w, "fanmi_controller_state", "gauge", "State of the fan controller, 1 for the current state.")
+	for _, s := range devices {
+		for _, state := range status.States {
+			value := 0.0
+			if s.State == state {
+				value = 1
+			}
+			sample(w, "fanmi_controller_state", value, "device", s.Device, "state", string(state))
+		}
+	}
+
+	header(w, "fanmi_sysfs_write_errors_total", "counter", "Number of failed writes to sysfs files.")
+	for _, s := range devices {
+		sample(w, "fanmi_sysfs_write_errors_total", float64(s.WriteErrors), "device", s.Device)
+	}
+
+	header(w, "fanmi_failsafe_events_total", "counter", "Number of times the controller entered failsafe state.")
+	for _, s := range devices {
+		sample(w, "fanmi_failsafe_events_total", float64(s.FailsafeEvents), "device", s.Device)
+	}
+}
+
+func header(w io.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+// sample writes one line, labels are given as name/value pairs
+func sample(w io.Writer, name string, value float64, labels ...string) {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1])))
+	}
+	fmt.Fprintf(w, "%s{%s} %g\n", name, strings.Join(pairs, ","), value)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
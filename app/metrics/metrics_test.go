@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirion/fanmi/app/status"
+)
+
+func TestExporter_Write(t *testing.T) {
+	exporter := NewExporter()
+	exporter.Update(status.Status{
+		Device: "card1",
+		Temperatures: []status.Temperature{
+			{Channel: "edge", Value: 45},
+			{Channel: "junction", Value: 52.5},
+		},
+		Speed:            0.25,
+		RPM:              -1,
+		Power:            -1,
+		PowerMode:        "auto",
+		PowerProfileMode: "COMPUTE",
+		Curve:            "quiet",
+		State:            status.StateFailsafe,
+		WriteErrors:      2,
+		FailsafeEvents:   1,
+	})
+	exporter.Update(status.Status{
+		Device:   "card0",
+		Speed:    0.5,
+		RPM:      1200,
+		Power:    181.5,
+		PowerCap: 200,
+		Curve:    `"default"`,
+		State:    status.StateActive,
+	})
+
+	builder := &strings.Builder{}
+	exporter.Write(builder)
+	output := builder.String()
+
+	tests := []string{
+		`fanmi_temperature_celsius{device="card1",channel="edge"} 45`,
+		`fanmi_temperature_celsius{device="card1",channel="junction"} 52.5`,
+		`fanmi_fan_pwm_percent{device="card0"} 50`,
+		`fanmi_fan_pwm_percent{device="card1"} 25`,
+		`fanmi_fan_rpm{device="card0"} 1200`,
+		`fanmi_power_watts{device="card0"} 181.5`,
+		`fanmi_power_cap_watts{device="card0"} 200`,
+		`fanmi_power_mode_info{device="card1",mode="auto"} 1`,
+		`fanmi_power_profile_mode_info{device="card1",mode="COMPUTE"} 1`,
+		`fanmi_curve_info{device="card0",curve="\"default\""} 1`,
+		`fanmi_controller_state{device="card0",state="active"} 1`,
+		`fanmi_controller_state{device="card1",state="active"} 0`,
+		`fanmi_controller_state{device="card1",state="failsafe"} 1`,
+		`fanmi_sysfs_write_errors_total{device="card1"} 2`,
+		`fanmi_failsafe_events_total{device="card1"} 1`,
+		"# TYPE fanmi_failsafe_events_total counter",
+	}
+	for _, want := range tests {
+		if !strings.Contains(output, want+"\n") {
+			t.Errorf("Write() output does not contain %q:\n%s", want, output)
+		}
+	}
+
+	if strings.Contains(output, `fanmi_fan_rpm{device="card1"}`) {
+		t.Errorf("Write() contains rpm of a device without fan1_input:\n%s", output)
+	}
+	if strings.Contains(output, `fanmi_power_watts{device="card1"}`) || strings.Contains(output, `fanmi_power_cap_watts{device="card1"}`) {
+		t.Errorf("Write() contains power of a device without power1_* files:\n%s", output)
+	}
+	if strings.Index(output, `fanmi_fan_pwm_percent{device="card0"}`) > strings.Index(output, `fanmi_fan_pwm_percent{device="card1"}`) {
+		t.Errorf("Write() devices are not sorted:\n%s", output)
+	}
+}
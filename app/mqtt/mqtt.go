@@ -0,0 +1,266 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/debug"
+	"github.com/sirion/fanmi/app/status"
+	"github.com/sirion/fanmi/app/units"
+)
+
+const (
+	CommandCurve     = "curve"
+	CommandPowerMode = "powermode"
+	CommandActive    = "active"
+)
+
+// Client publishes the status of every device to a MQTT broker and changes the configuration on commands
+type Client struct {
+	config   *configuration.Configuration
+	settings configuration.MQTTConfiguration
+	client   paho.Client
+	messages func(string)
+
+	mutex      sync.Mutex
+	discovered map[string]bool
+}
+
+// StatePayload is published (retained) to the state topic of each device
+type StatePayload struct {
+	Temperature  float32            `json:"temperature"`
+	Temperatures map[string]float32 `json:"temperatures"`
+	TargetSpeed  float32            `json:"targetSpeed"`
+	Speed        float32            `json:"speed"`
+	RPM          int                `json:"rpm"`
+	Curve        string             `json:"curve"`
+	PowerMode    string             `json:"powerMode"`
+	State        status.State       `json:"state"`
+	Active       bool               `json:"active"`
+}
+
+// NewClient creates the client, messages is used to report connection problems
+func NewClient(config *configuration.Configuration, messages func(string)) *Client {
+	c := &Client{
+		config:     config,
+		settings:   config.MQTT,
+		messages:   messages,
+		discovered: make(map[string]bool),
+	}
+
+	options := paho.NewClientOptions()
+	options.AddBroker(c.settings.Broker)
+	options.SetClientID(c.settings.ClientID)
+	options.SetUsername(c.settings.Username)
+	options.SetPassword(c.settings.Password)
+	options.SetAutoReconnect(true)
+	options.SetConnectRetry(true)
+	options.SetConnectRetryInterval(10 * time.Second)
+	options.SetOnConnectHandler(c.onConnect)
+	options.SetConnectionLostHandler(func(client paho.Client, err error) {
+		c.messages(fmt.Sprintf("MQTT connection lost: %s\n", err.Error()))
+	})
+	c.client = paho.NewClient(options)
+
+	return c
+}
+
+// Connect connects to the broker in the background, reconnecting when the connection is lost
+func (c *Client) Connect() {
+	c.client.Connect()
+}
+
+func (c *Client) Disconnect() {
+	c.client.Disconnect(250)
+}
+
+func (c *Client) onConnect(client paho.Client) {
+	debug.Log("Connected to MQTT broker %s\n", c.settings.Broker)
+
+	// The broker might have lost retained messages
+	c.mutex.Lock()
+	c.discovered = make(map[string]bool)
+	c.mutex.Unlock()
+
+	topic := strings.TrimSuffix(c.settings.CommandTopic, "/") + "/+"
+	client.Subscribe(topic, 1, c.onCommand)
+}
+
+func (c *Client) onCommand(client paho.Client, message paho.Message) {
+	command := message.Topic()[strings.LastIndex(message.Topic(), "/")+1:]
+	payload := strings.TrimSpace(string(message.Payload()))
+	debug.Log("MQTT command %s: %s\n", command, payload)
+
+	err := c.Command(command, payload)
+	if err != nil {
+		c.messages(fmt.Sprintf("MQTT command %s failed: %s\n", message.Topic(), err.Error()))
+	}
+}
+
+// Command executes a command received on the command topic
+func (c *Client) Command(command, payload string) error {
+	switch command {
+	case CommandCurve:
+		c.config.RLock()
+		_, ok := c.config.Curves[payload]
+		c.config.RUnlock()
+		if !ok {
+			return fmt.Errorf("curve '%s' not found", payload)
+		}
+		c.config.SetCurve(payload)
+
+	case CommandPowerMode:
+		if !slices.Contains(configuration.PowerModes, payload) {
+			return fmt.Errorf("unknown power mode '%s'", payload)
+		}
+		c.config.SetPowerMode(payload)
+
+	case CommandActive:
+		active, err := parseBool(payload)
+		if err != nil {
+			return err
+		}
+		c.config.SetActive(active)
+
+	default:
+		return fmt.Errorf("unknown command '%s'", command)
+	}
+	return nil
+}
+
+// Update implements status.Listener
+func (c *Client) Update(s status.Status) {
+	if !c.client.IsConnectionOpen() {
+		return
+	}
+
+	c.mutex.Lock()
+	discovered := c.discovered[s.Device]
+	c.discovered[s.Device] = true
+	c.mutex.Unlock()
+
+	if !discovered && c.settings.Discovery {
+		c.publishDiscovery(s)
+	}
+
+	c.config.RLock()
+	active := c.config.Active
+	c.config.RUnlock()
+
+	payload := StatePayload{
+		Temperature:  s.Temperature,
+		Temperatures: make(map[string]float32, len(s.Temperatures)),
+		TargetSpeed:  units.Percent(s.TargetSpeed),
+		Speed:        units.Percent(s.Speed),
+		RPM:          s.RPM,
+		Curve:        s.Curve,
+		PowerMode:    s.PowerMode,
+		State:        s.State,
+		Active:       active,
+	}
+	for _, temp := range s.Temperatures {
+		payload.Temperatures[temp.Channel] = temp.Value
+	}
+
+	c.publish(c.StateTopic(s.Device), payload)
+}
+
+// StateTopic returns the state topic of the given device
+func (c *Client) StateTopic(device string) string {
+	return strings.ReplaceAll(c.settings.StateTopic, "{device}", device)
+}
+
+func (c *Client) publish(topic string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		c.messages(fmt.Sprintf("Error creating MQTT message: %s\n", err.Error()))
+		return
+	}
+	c.client.Publish(topic, 0, true, data)
+}
+
+// publishDiscovery announces the entities of a device to Home Assistant
+func (c *Client) publishDiscovery(s status.Status) {
+	device := map[string]any{
+		"identifiers":  []string{"fanmi_" + s.Device},
+		"name":         "FanMi " + s.Device,
+		"manufacturer": "AMD",
+		"model":        "amdgpu",
+	}
+	stateTopic := c.StateTopic(s.Device)
+	commandTopic := strings.TrimSuffix(c.settings.CommandTopic, "/")
+
+	entity := func(component, object, name string, config map[string]any) {
+		config["name"] = name
+		config["unique_id"] = "fanmi_" + s.Device + "_" + object
+		config["device"] = device
+		config["state_topic"] = stateTopic
+		c.publish(fmt.Sprintf("%s/%s/fanmi_%s/%s/config", c.settings.DiscoveryPrefix, component, s.Device, object), config)
+	}
+
+	for _, temp := range s.Temperatures {
+		entity("sensor", "temperature_"+temp.Channel, "Temperature "+temp.Channel, map[string]any{
+			"device_class":        "temperature",
+			"unit_of_measurement": "°C",
+			"value_template":      fmt.Sprintf("{{ value_json.temperatures[%q] }}", temp.Channel),
+		})
+	}
+	entity("sensor", "speed", "Fan speed", map[string]any{
+		"unit_of_measurement": "%",
+		"value_template":      "{{ value_json.speed | round(1) }}",
+	})
+	if s.RPM >= 0 {
+		entity("sensor", "rpm", "Fan RPM", map[string]any{
+			"unit_of_measurement": "RPM",
+			"value_template":      "{{ value_json.rpm }}",
+		})
+	}
+	entity("sensor", "state", "Controller state", map[string]any{
+		"value_template": "{{ value_json.state }}",
+	})
+
+	c.config.RLock()
+	curves := slices.Clone(c.config.CurveNames)
+	c.config.RUnlock()
+	entity("select", "curve", "Fan curve", map[string]any{
+		"command_topic":  commandTopic + "/" + CommandCurve,
+		"options":        curves,
+		"value_template": "{{ value_json.curve }}",
+	})
+	if s.PowerMode != "" {
+		entity("select", "power_mode", "Power mode", map[string]any{
+			"command_topic":  commandTopic + "/" + CommandPowerMode,
+			"options":        configuration.PowerModes,
+			"value_template": "{{ value_json.powerMode }}",
+		})
+	}
+	entity("switch", "active", "Fan control", map[string]any{
+		"command_topic":  commandTopic + "/" + CommandActive,
+		"payload_on":     "true",
+		"payload_off":    "false",
+		"state_on":       "true",
+		"state_off":      "false",
+		"value_template": "{{ value_json.active | lower }}",
+	})
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	active, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value '%s' for active", value)
+	}
+	return active, nil
+}
@@ -0,0 +1,147 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/status"
+)
+
+// startBroker starts an in-process broker on a free local port
+func startBroker(t *testing.T) (*mochi.Server, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	broker := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	broker.AddHook(new(auth.AllowHook), nil)
+	err = broker.AddListener(listeners.NewTCP(listeners.Config{ID: "test", Address: address}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go broker.Serve()
+	t.Cleanup(func() { broker.Close() })
+
+	return broker, "tcp://" + address
+}
+
+func waitFor(t *testing.T, description string, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", description)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClient(t *testing.T) {
+	broker, url := startBroker(t)
+
+	config := &configuration.Configuration{
+		Active:       true,
+		CurrentCurve: "default",
+		CurveNames:   []string{"default", "quiet"},
+		Curves: map[string]configuration.Values{
+			"default": {{Temp: 40, Speed: 0}, {Temp: 90, Speed: 1}},
+			"quiet":   {{Temp: 60, Speed: 0}, {Temp: 90, Speed: 0.8}},
+		},
+		MQTT: configuration.MQTTConfiguration{
+			Broker:          url,
+			ClientID:        "fanmi-test",
+			StateTopic:      "fanmi/{device}/state",
+			CommandTopic:    "fanmi/set",
+			Discovery:       true,
+			DiscoveryPrefix: "homeassistant",
+		},
+	}
+
+	mutex := sync.Mutex{}
+	received := make(map[string][]byte)
+	broker.Subscribe("#", 1, func(cl *mochi.Client, sub packets.Subscription, pk packets.Packet) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		received[pk.TopicName] = pk.Payload
+	})
+	message := func(topic string) []byte {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return received[topic]
+	}
+
+	messages := make(chan string, 10)
+	client := NewClient(config, func(message string) { messages <- message })
+	client.Connect()
+	defer client.Disconnect()
+	waitFor(t, "connection", client.client.IsConnectionOpen)
+
+	client.Update(status.Status{
+		Device:       "card0",
+		Temperature:  50,
+		Temperatures: []status.Temperature{{Channel: "edge", Value: 50}},
+		Speed:        0.25,
+		RPM:          900,
+		Curve:        "default",
+		PowerMode:    "auto",
+		State:        status.StateActive,
+	})
+
+	waitFor(t, "state message", func() bool { return message("fanmi/card0/state") != nil })
+	state := StatePayload{}
+	err := json.Unmarshal(message("fanmi/card0/state"), &state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Speed != 25 || state.RPM != 900 || state.Temperatures["edge"] != 50 || !state.Active {
+		t.Errorf("unexpected state %+v", state)
+	}
+
+	for _, topic := range []string{
+		"homeassistant/sensor/fanmi_card0/temperature_edge/config",
+		"homeassistant/sensor/fanmi_card0/rpm/config",
+		"homeassistant/select/fanmi_card0/curve/config",
+		"homeassistant/select/fanmi_card0/power_mode/config",
+		"homeassistant/switch/fanmi_card0/active/config",
+	} {
+		waitFor(t, topic, func() bool { return message(topic) != nil })
+	}
+	if !strings.Contains(string(message("homeassistant/select/fanmi_card0/curve/config")), `"command_topic":"fanmi/set/curve"`) {
+		t.Errorf("unexpected discovery message %s", message("homeassistant/select/fanmi_card0/curve/config"))
+	}
+
+	broker.Publish("fanmi/set/curve", []byte("quiet"), false, 1)
+	broker.Publish("fanmi/set/powermode", []byte("low"), false, 1)
+	broker.Publish("fanmi/set/active", []byte("OFF"), false, 1)
+	waitFor(t, "commands", func() bool {
+		config.RLock()
+		defer config.RUnlock()
+		return config.CurrentCurve == "quiet" && config.PowerMode == "low" && !config.Active
+	})
+
+	broker.Publish("fanmi/set/curve", []byte("loud"), false, 1)
+	select {
+	case msg := <-messages:
+		if !strings.Contains(msg, "curve 'loud' not found") {
+			t.Errorf("unexpected message %s", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("no error message for unknown curve")
+	}
+}
@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	notificationsService = "org.freedesktop.Notifications"
+	notificationsPath    = "/org/freedesktop/Notifications"
+	// A hanging notification service must not hold up the fan control
+	notifyTimeout = 2 * time.Second
+)
+
+// Values of the "urgency" hint
+var urgencies = map[string]byte{"low": 0, "normal": 1, "critical": 2}
+
+// DBusSink sends notifications to the notification service (org.freedesktop.Notifications) on the session bus
+type DBusSink struct {
+	conn *dbus.Conn
+}
+
+// NewSink connects to the session bus. If there is none, Discard is returned with the error.
+func NewSink() (Sink, error) {
+	address := os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+	if address == "" && os.Getenv("XDG_RUNTIME_DIR") != "" {
+		// Do not let the dbus library launch a new bus, it would not have a notification service
+		socket := path.Join(os.Getenv("XDG_RUNTIME_DIR"), "bus")
+		if _, err := os.Stat(socket); err == nil {
+			address = "unix:path=" + socket
+		}
+	}
+	if address == "" {
+		return Discard, fmt.Errorf("no session bus found, DBUS_SESSION_BUS_ADDRESS is not set")
+	}
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		return Discard, fmt.Errorf("cannot connect to the session bus: %s", err.Error())
+	}
+	return &DBusSink{conn: conn}, nil
+}
+
+func (d *DBusSink) Notify(notification Notification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	hints := map[string]dbus.Variant{"urgency": dbus.MakeVariant(urgencies[notification.Urgency])}
+	call := d.conn.Object(notificationsService, notificationsPath).CallWithContext(ctx, notificationsService+".Notify", 0,
+		"fanmi", uint32(0), "", notification.Summary, notification.Body, []string{}, hints, int32(-1))
+	return call.Err
+}
@@ -0,0 +1,240 @@
+package notify
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/debug"
+	"github.com/sirion/fanmi/app/status"
+	"github.com/sirion/fanmi/app/units"
+)
+
+const (
+	// A fan is stalled if it does not turn at this speed (0-1)...
+	stallSpeed = 0.2
+	// ...for this number of check cycles in a row
+	stallCycles = 3
+	// Temperature rules fire again after the temperature dropped this much (°C) below the warning temperature
+	hysteresis = 3
+	// Window of the rate limit
+	rateWindow = time.Minute
+	// Notifications waiting for a slow notification service, further ones are dropped
+	queueSize = 16
+)
+
+// Notification is shown on the desktop
+type Notification struct {
+	Summary string
+	Body    string
+	// "low", "normal" or "critical"
+	Urgency string
+}
+
+// Sink shows notifications
+type Sink interface {
+	Notify(notification Notification) error
+}
+
+// Discard is used if there is no notification service
+var Discard Sink = discard{}
+
+type discard struct{}
+
+func (discard) Notify(Notification) error {
+	return nil
+}
+
+// Notifier checks the notification rules on every status update and sends a notification when an event occurs.
+// Notifications are sent by their own goroutine, so a slow notification service does not delay the fan control.
+type Notifier struct {
+	config *configuration.Configuration
+	sink   Sink
+	now    func() time.Time
+
+	mutex   sync.Mutex
+	devices map[string]*deviceState
+	// Times of the notifications within the rate window
+	sent []time.Time
+
+	queue  chan Notification
+	closed bool
+	// Closed when the sender has sent all queued notifications
+	done chan bool
+}
+
+// deviceState remembers the events of one device between status updates
+type deviceState struct {
+	// Whether the condition of the rule (by index) was met in the last update
+	conditions map[int]bool
+	// Time of the last notification of the rule (by index)
+	lastSent    map[int]time.Time
+	stalled     int
+	writeErrors uint64
+}
+
+// NewNotifier creates a notifier using the rules of the configuration, they can be changed by reloading it
+func NewNotifier(config *configuration.Configuration, sink Sink) *Notifier {
+	n := &Notifier{
+		config:  config,
+		sink:    sink,
+		now:     time.Now,
+		devices: make(map[string]*deviceState),
+		queue:   make(chan Notification, queueSize),
+		done:    make(chan bool),
+	}
+	go n.send()
+	return n
+}
+
+// Close stops the sender after the queued notifications were sent
+func (n *Notifier) Close() {
+	n.mutex.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mutex.Unlock()
+	<-n.done
+}
+
+// send shows the queued notifications until the notifier is closed
+func (n *Notifier) send() {
+	for notification := range n.queue {
+		err := n.sink.Notify(notification)
+		if err != nil {
+			debug.Logger.Warn("cannot send notification", "summary", notification.Summary, "error", err.Error())
+		}
+	}
+	close(n.done)
+}
+
+// Update checks the rules for the status of a device
+func (n *Notifier) Update(s status.Status) {
+	n.config.RLock()
+	settings := n.config.Notifications
+	settings.Rules = append([]configuration.NotificationRule{}, settings.Rules...)
+	n.config.RUnlock()
+	if !settings.Enabled {
+		// Disabled by reloading the configuration
+		return
+	}
+
+	notifications := n.check(s, settings)
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for _, notification := range notifications {
+		if n.closed {
+			return
+		}
+		select {
+		case n.queue <- notification:
+		default:
+			debug.Logger.Warn("notification dropped, the notification service is too slow", "device", s.Device, "summary", notification.Summary)
+		}
+	}
+}
+
+// check returns the notifications for the rules whose events occurred
+func (n *Notifier) check(s status.Status, settings configuration.NotificationsConfiguration) []Notification {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	now := n.now()
+	device, ok := n.devices[s.Device]
+	if !ok {
+		device = &deviceState{
+			conditions:  make(map[int]bool),
+			lastSent:    make(map[int]time.Time),
+			writeErrors: s.WriteErrors,
+		}
+		n.devices[s.Device] = device
+	}
+
+	if s.RPM == 0 && s.Speed >= stallSpeed {
+		device.stalled++
+	} else {
+		device.stalled = 0
+	}
+	newWriteErrors := s.WriteErrors > device.writeErrors
+	device.writeErrors = s.WriteErrors
+
+	notifications := []Notification{}
+	for i, rule := range settings.Rules {
+		if rule.Device != "" && rule.Device != s.Device {
+			continue
+		}
+
+		previous := device.conditions[i]
+		condition := false
+		switch rule.Event {
+		case "temperature":
+			// Stay hot until the temperature is clearly below the warning temperature
+			condition = s.Temperature >= rule.Temp || (previous && s.Temperature > rule.Temp-hysteresis)
+		case "stall":
+			condition = device.stalled >= stallCycles
+		case "failsafe":
+			condition = s.State == status.StateFailsafe
+		case "writeError":
+			// Every failed write is a new event
+			condition = newWriteErrors
+			previous = false
+		}
+		device.conditions[i] = condition
+		if !condition || previous {
+			continue
+		}
+
+		if last, ok := device.lastSent[i]; ok && now.Sub(last) < time.Duration(settings.Cooldown)*time.Second {
+			debug.Logger.Debug("notification in cooldown", "device", s.Device, "event", rule.Event)
+			continue
+		}
+		if !n.allowed(now, settings.RateLimit) {
+			// Try again with the next update
+			debug.Logger.Debug("notification rate limited", "device", s.Device, "event", rule.Event)
+			device.conditions[i] = false
+			continue
+		}
+
+		device.lastSent[i] = now
+		n.sent = append(n.sent, now)
+		notifications = append(notifications, message(rule, s))
+	}
+	return notifications
+}
+
+// allowed checks whether the rate limit allows another notification, the mutex must be held
+func (n *Notifier) allowed(now time.Time, rateLimit int) bool {
+	i := 0
+	for i < len(n.sent) && now.Sub(n.sent[i]) >= rateWindow {
+		i++
+	}
+	n.sent = n.sent[i:]
+	return rateLimit == 0 || len(n.sent) < rateLimit
+}
+
+// message describes the event of the rule
+func message(rule configuration.NotificationRule, s status.Status) Notification {
+	notification := Notification{Urgency: rule.Urgency}
+	if notification.Urgency == "" {
+		notification.Urgency = "normal"
+	}
+
+	switch rule.Event {
+	case "temperature":
+		notification.Summary = fmt.Sprintf("%s is hot", s.Device)
+		notification.Body = fmt.Sprintf("Temperature %.0f °C reached the warning temperature of %.0f °C, the fan runs at %.0f %%", s.Temperature, rule.Temp, units.Percent(s.Speed))
+	case "stall":
+		notification.Summary = fmt.Sprintf("Fan of %s stalled", s.Device)
+		notification.Body = fmt.Sprintf("The fan does not turn at %.0f %% speed, the temperature is %.0f °C", units.Percent(s.Speed), s.Temperature)
+	case "failsafe":
+		notification.Summary = fmt.Sprintf("%s is in failsafe mode", s.Device)
+		notification.Body = fmt.Sprintf("The temperature is %.0f °C, the fan runs at full speed or is controlled by the driver", s.Temperature)
+	case "writeError":
+		notification.Summary = fmt.Sprintf("Cannot control the fan of %s", s.Device)
+		notification.Body = fmt.Sprintf("%d writes to sysfs failed since start-up, see the log for details", s.WriteErrors)
+	}
+	return notification
+}
@@ -0,0 +1,200 @@
+package notify
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/status"
+)
+
+// fakeSink records the notifications instead of showing them
+type fakeSink struct {
+	notifications []Notification
+	err           error
+}
+
+func (f *fakeSink) Notify(notification Notification) error {
+	f.notifications = append(f.notifications, notification)
+	return f.err
+}
+
+// step is one status update after the time advanced
+type step struct {
+	after  time.Duration
+	status status.Status
+}
+
+func hot(device string, temp float32) status.Status {
+	return status.Status{Device: device, Temperature: temp, Speed: 0.5, RPM: 1500, State: status.StateActive}
+}
+
+func TestNotifier_Update(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings configuration.NotificationsConfiguration
+		steps    []step
+		want     []string
+	}{
+		{
+			name:     "Warning temperature crossed once",
+			settings: configuration.NotificationsConfiguration{Rules: []configuration.NotificationRule{{Event: "temperature", Temp: 85}}},
+			steps:    []step{{0, hot("card0", 80)}, {time.Second, hot("card0", 86)}, {time.Second, hot("card0", 90)}},
+			want:     []string{"card0 is hot"},
+		},
+		{
+			name:     "Hysteresis",
+			settings: configuration.NotificationsConfiguration{Rules: []configuration.NotificationRule{{Event: "temperature", Temp: 85}}},
+			steps:    []step{{0, hot("card0", 86)}, {time.Second, hot("card0", 84)}, {time.Second, hot("card0", 86)}, {time.Second, hot("card0", 80)}, {time.Second, hot("card0", 86)}},
+			want:     []string{"card0 is hot", "card0 is hot"},
+		},
+		{
+			name: "Cooldown",
+			settings: configuration.NotificationsConfiguration{Cooldown: 60, Rules: []configuration.NotificationRule{
+				{Event: "temperature", Temp: 85},
+			}},
+			steps: []step{
+				{0, hot("card0", 86)}, {time.Second, hot("card0", 70)}, {time.Second, hot("card0", 86)},
+				{time.Minute, hot("card0", 70)}, {time.Second, hot("card0", 86)},
+			},
+			want: []string{"card0 is hot", "card0 is hot"},
+		},
+		{
+			name: "Cooldown per device",
+			settings: configuration.NotificationsConfiguration{Cooldown: 60, Rules: []configuration.NotificationRule{
+				{Event: "temperature", Temp: 85},
+			}},
+			steps: []step{{0, hot("card0", 86)}, {time.Second, hot("card1", 86)}},
+			want:  []string{"card0 is hot", "card1 is hot"},
+		},
+		{
+			name: "Rate limit",
+			settings: configuration.NotificationsConfiguration{RateLimit: 2, Rules: []configuration.NotificationRule{
+				{Event: "temperature", Temp: 85},
+			}},
+			steps: []step{
+				{0, hot("card0", 86)}, {time.Second, hot("card1", 86)}, {time.Second, hot("card2", 86)},
+				// Sent when the rate window allows it again
+				{time.Minute, hot("card2", 87)},
+			},
+			want: []string{"card0 is hot", "card1 is hot", "card2 is hot"},
+		},
+		{
+			name:     "Rule for one device",
+			settings: configuration.NotificationsConfiguration{Rules: []configuration.NotificationRule{{Event: "temperature", Temp: 85, Device: "card1"}}},
+			steps:    []step{{0, hot("card0", 90)}, {time.Second, hot("card1", 90)}},
+			want:     []string{"card1 is hot"},
+		},
+		{
+			name:     "Stalled fan",
+			settings: configuration.NotificationsConfiguration{Rules: []configuration.NotificationRule{{Event: "stall"}}},
+			steps: []step{
+				{0, status.Status{Device: "card0", Speed: 0.5, RPM: 0}},
+				{time.Second, status.Status{Device: "card0", Speed: 0.5, RPM: 0}},
+				// Fans stop at low speeds
+				{time.Second, status.Status{Device: "card0", Speed: 0.1, RPM: 0}},
+				{time.Second, status.Status{Device: "card0", Speed: 0.5, RPM: 0}},
+				{time.Second, status.Status{Device: "card0", Speed: 0.5, RPM: 0}},
+				{time.Second, status.Status{Device: "card0", Speed: 0.5, RPM: 0}},
+				{time.Second, status.Status{Device: "card0", Speed: 0.5, RPM: 0}},
+			},
+			want: []string{"Fan of card0 stalled"},
+		},
+		{
+			name:     "Failsafe",
+			settings: configuration.NotificationsConfiguration{Rules: []configuration.NotificationRule{{Event: "failsafe"}}},
+			steps: []step{
+				{0, status.Status{Device: "card0", State: status.StateActive}},
+				{time.Second, status.Status{Device: "card0", State: status.StateFailsafe}},
+				{time.Second, status.Status{Device: "card0", State: status.StateFailsafe}},
+			},
+			want: []string{"card0 is in failsafe mode"},
+		},
+		{
+			name:     "Write errors",
+			settings: configuration.NotificationsConfiguration{Rules: []configuration.NotificationRule{{Event: "writeError"}}},
+			steps: []step{
+				// Errors before the first update are not reported
+				{0, status.Status{Device: "card0", WriteErrors: 1}},
+				{time.Second, status.Status{Device: "card0", WriteErrors: 2}},
+				{time.Second, status.Status{Device: "card0", WriteErrors: 3}},
+				{time.Second, status.Status{Device: "card0", WriteErrors: 3}},
+			},
+			want: []string{"Cannot control the fan of card0", "Cannot control the fan of card0"},
+		},
+		{
+			name:     "Disabled",
+			settings: configuration.NotificationsConfiguration{Rules: []configuration.NotificationRule{{Event: "failsafe"}}},
+			steps:    []step{{0, status.Status{Device: "card0", State: status.StateFailsafe}}},
+			want:     []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &configuration.Configuration{Notifications: tt.settings}
+			config.Notifications.Enabled = tt.name != "Disabled"
+			sink := &fakeSink{err: fmt.Errorf("errors are only logged")}
+			notifier := NewNotifier(config, sink)
+			now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			notifier.now = func() time.Time { return now }
+
+			for _, step := range tt.steps {
+				now = now.Add(step.after)
+				notifier.Update(step.status)
+			}
+			notifier.Close()
+
+			got := []string{}
+			for _, notification := range sink.notifications {
+				got = append(got, notification.Summary)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Update() notifications = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// blockingSink does not return until it is released
+type blockingSink struct {
+	release chan bool
+}
+
+func (b *blockingSink) Notify(Notification) error {
+	<-b.release
+	return nil
+}
+
+func TestNotifier_Update_slowSink(t *testing.T) {
+	config := &configuration.Configuration{Notifications: configuration.NotificationsConfiguration{
+		Enabled: true,
+		Rules:   []configuration.NotificationRule{{Event: "writeError"}},
+	}}
+	sink := &blockingSink{release: make(chan bool)}
+	notifier := NewNotifier(config, sink)
+
+	start := time.Now()
+	for i := range queueSize * 2 {
+		notifier.Update(status.Status{Device: "card0", WriteErrors: uint64(i)})
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Update() blocked for %s while the sink was busy", elapsed)
+	}
+	close(sink.release)
+	notifier.Close()
+}
+
+func TestMessage(t *testing.T) {
+	rule := configuration.NotificationRule{Event: "temperature", Temp: 85}
+	got := message(rule, status.Status{Device: "card1", Temperature: 86.4, Speed: 0.7})
+	want := Notification{
+		Summary: "card1 is hot",
+		Body:    "Temperature 86 °C reached the warning temperature of 85 °C, the fan runs at 70 %",
+		Urgency: "normal",
+	}
+	if got != want {
+		t.Errorf("message() = %+v, want %+v", got, want)
+	}
+}
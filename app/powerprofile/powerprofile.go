@@ -0,0 +1,100 @@
+// Package powerprofile reads the table of pp_power_profile_mode. Its layout differs between the generations of
+// amdgpu (one mode per line, one line per mode and clock, or all modes in one header line). Most show the index
+// before the name of a mode, the active mode is marked with "*".
+package powerprofile
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Custom is the mode whose heuristics are written together with its index, it cannot be selected by name
+const Custom = "CUSTOM"
+
+// Mode is an entry of pp_power_profile_mode, the index is written to the file to select it
+type Mode struct {
+	Index int
+	Name  string
+}
+
+// Index and name of a mode, e.g. "1 3D_FULL_SCREEN*" or "1 3D_FULL_SCREEN *"
+var modePattern = regexp.MustCompile(`(\d+) +([A-Z0-9_]*[A-Z][A-Z0-9_]*)( ?\*)?`)
+
+// Line with one mode followed by its values (" 1 3D_FULL_SCREEN*: 70 60") or with all modes ("0 BOOTUP_DEFAULT
+// 1 3D_FULL_SCREEN* ..."). Lines of clocks ("0( GFXCLK)") or heuristics ("0 GFXCLK FPS 0") do not match.
+var linePattern = regexp.MustCompile(`^\s*(?:\d+ +[A-Z0-9_]*[A-Z][A-Z0-9_]* ?\*?\s*)+(?::.*)?$`)
+
+// Name of a mode in a header line without indexes
+var namePattern = regexp.MustCompile(`^([A-Z0-9_]*[A-Z][A-Z0-9_]*)(\*?)$`)
+
+// Parse returns the modes in the order of the table and the name of the active mode, an empty string if none is
+// marked
+func Parse(table string) ([]Mode, string) {
+	modes := []Mode{}
+	active := ""
+	seen := map[int]bool{}
+	for _, line := range strings.Split(table, "\n") {
+		if !linePattern.MatchString(line) {
+			continue
+		}
+		line, _, _ = strings.Cut(line, ":")
+		for _, match := range modePattern.FindAllStringSubmatch(line, -1) {
+			index, err := strconv.Atoi(match[1])
+			if err != nil || seen[index] {
+				continue
+			}
+			seen[index] = true
+			modes = append(modes, Mode{Index: index, Name: match[2]})
+			if match[3] != "" {
+				active = match[2]
+			}
+		}
+	}
+	if len(modes) == 0 {
+		return parseHeader(table)
+	}
+	return modes, active
+}
+
+// parseHeader reads tables that list the modes in their first line without indexes, the index is the position
+func parseHeader(table string) ([]Mode, string) {
+	modes := []Mode{}
+	active := ""
+	header, _, _ := strings.Cut(strings.TrimLeft(table, "\n"), "\n")
+	for _, field := range strings.Fields(header) {
+		match := namePattern.FindStringSubmatch(field)
+		if match == nil {
+			if field == "*" && len(modes) > 0 {
+				active = modes[len(modes)-1].Name
+			}
+			continue
+		}
+		modes = append(modes, Mode{Index: len(modes), Name: match[1]})
+		if match[2] != "" {
+			active = match[1]
+		}
+	}
+	return modes, active
+}
+
+// Names returns the names of the modes that can be selected, CUSTOM needs its heuristics and is left out
+func Names(modes []Mode) []string {
+	names := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		if mode.Name != Custom {
+			names = append(names, mode.Name)
+		}
+	}
+	return names
+}
+
+// Find returns the mode with the name
+func Find(modes []Mode, name string) (Mode, bool) {
+	for _, mode := range modes {
+		if mode.Name == name {
+			return mode, true
+		}
+	}
+	return Mode{}, false
+}
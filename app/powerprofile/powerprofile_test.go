@@ -0,0 +1,105 @@
+package powerprofile
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Tables as printed by the different SMU generations
+const (
+	smu7 = `NUM        MODE_NAME     SCLK_UP_HYST   SCLK_DOWN_HYST SCLK_ACTIVE_LEVEL     MCLK_UP_HYST   MCLK_DOWN_HYST MCLK_ACTIVE_LEVEL
+  0   BOOTUP_DEFAULT:        -        -      -        -        -      -
+  1 3D_FULL_SCREEN *:        0      100     30        0      100     10
+  2     POWER_SAVING:       10        0     30        -        -      -
+  3            VIDEO:        -        -      -       10       16     31
+  4               VR:        0       11     50        0      100     10
+  5          COMPUTE:        0        5     30        -        -      -
+  6           CUSTOM:        -        -      -        -        -      -
+`
+	vega = `NUM        MODE_NAME BUSY_SET_POINT FPS USE_RLC_BUSY MIN_ACTIVE_LEVEL
+  0 BOOTUP_DEFAULT :             70      60          0              0
+  1 3D_FULL_SCREEN*:             70      60          1              3
+  2   POWER_SAVING :             90      60          0              0
+  3          VIDEO :             70      60          0              0
+  4             VR :             70      90          0              0
+  5        COMPUTE :             30      60          0              6
+  6         CUSTOM :              0       0          0              0
+`
+	navi = `PROFILE_INDEX(NAME) CLOCK_TYPE(NAME) FPS MinFreqType MinActiveFreqType MinActiveFreq BoosterFreqType BoosterFreq PD_Data_limit_c PD_Data_error_coeff PD_Data_error_rate_coeff
+ 0 BOOTUP_DEFAULT :
+                    0(       GFXCLK)       0       5       1       0       4     800 4587520  -65536       0
+                    1(       SOCCLK)       0       5       1       0       1       0 3276800   -6553   -65536
+                    2(        MEMLK)       0       5       1       0       4     800  327680   -6553   -65536
+ 1 3D_FULL_SCREEN :
+                    0(       GFXCLK)       0       5       1       0       4     650 4587520  -65536       0
+ 2   POWER_SAVING*:
+                    0(       GFXCLK)       0       5       1       0       4     650 4587520  -65536       0
+ 5        COMPUTE :
+                    0(       GFXCLK)       0       5       1       0       4     650 4587520  -65536       0
+`
+	rdna3 = `                              0 BOOTUP_DEFAULT  1 3D_FULL_SCREEN  2 POWER_SAVING    3 VIDEO           4 VR              5 COMPUTE*        6 CUSTOM
+0                        GFXCLK  FPS                0               0               0               0               0               1               0
+1                        SOCCLK  FPS                0               0               0               0               0               0               0
+`
+	rdna3Names = `                              BOOTUP_DEFAULT  3D_FULL_SCREEN* POWER_SAVING    VIDEO           VR              COMPUTE         CUSTOM
+0                        GFXCLK  FPS                0               0               0               0               0               1               0
+`
+	apu = ` 0 BOOTUP_DEFAULT*
+ 1 3D_FULL_SCREEN
+ 2   POWER_SAVING
+ 3          VIDEO
+ 4             VR
+ 5        COMPUTE
+ 6         CUSTOM
+`
+)
+
+func TestParse(t *testing.T) {
+	all := []string{"BOOTUP_DEFAULT", "3D_FULL_SCREEN", "POWER_SAVING", "VIDEO", "VR", "COMPUTE", "CUSTOM"}
+	tests := []struct {
+		name       string
+		table      string
+		wantNames  []string
+		wantActive string
+	}{
+		{"SMU7", smu7, all, "3D_FULL_SCREEN"},
+		{"Vega", vega, all, "3D_FULL_SCREEN"},
+		{"Navi", navi, []string{"BOOTUP_DEFAULT", "3D_FULL_SCREEN", "POWER_SAVING", "COMPUTE"}, "POWER_SAVING"},
+		{"RDNA3", rdna3, all, "COMPUTE"},
+		{"RDNA3 without indexes", rdna3Names, all, "3D_FULL_SCREEN"},
+		{"APU", apu, all, "BOOTUP_DEFAULT"},
+		{"Empty", "", []string{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modes, active := Parse(tt.table)
+			names := make([]string, len(modes))
+			for i, mode := range modes {
+				names[i] = mode.Name
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("Parse() modes = %v, want %v", names, tt.wantNames)
+			}
+			if active != tt.wantActive {
+				t.Errorf("Parse() active = %q, want %q", active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	modes, _ := Parse(navi)
+	mode, ok := Find(modes, "COMPUTE")
+	if !ok || mode.Index != 5 {
+		t.Errorf("Find(COMPUTE) = %v, %t, want index 5", mode, ok)
+	}
+	if _, ok := Find(modes, "VR"); ok {
+		t.Errorf("Find(VR) found a mode the card does not offer")
+	}
+	if names := Names(modes); !reflect.DeepEqual(names, []string{"BOOTUP_DEFAULT", "3D_FULL_SCREEN", "POWER_SAVING", "COMPUTE"}) {
+		t.Errorf("Names() = %v", names)
+	}
+	if names := Names([]Mode{{6, Custom}}); len(names) != 0 {
+		t.Errorf("Names() = %v, want CUSTOM left out", names)
+	}
+}
@@ -0,0 +1,65 @@
+package status
+
+import "time"
+
+// State describes what the controller is currently doing with the fan of a device
+type State string
+
+const (
+	StateActive   State = "active"
+	StatePaused   State = "paused"
+	StateFailsafe State = "failsafe"
+	// The speed is fixed by the user
+	StateManual State = "manual"
+)
+
+// States lists all possible controller states
+var States = []State{StateActive, StatePaused, StateFailsafe, StateManual}
+
+// Temperature is the reading of one temperature channel (temp?_input) of a device
+type Temperature struct {
+	Channel string  `json:"channel"`
+	Value   float32 `json:"value"`
+}
+
+// Status is the data collected by FanControl for one device in one check cycle
+type Status struct {
+	Device string    `json:"device"`
+	Time   time.Time `json:"time"`
+
+	// All temperature channels of the device in °C
+	Temperatures []Temperature `json:"temperatures"`
+	// Temperature used for the fan curve in °C
+	Temperature float32 `json:"temperature"`
+	// Speed calculated from the curve (0-1)
+	TargetSpeed float32 `json:"targetSpeed"`
+	// Target in revolutions per minute for rpm curves, 0 for speed curves
+	TargetRPM int `json:"targetRpm"`
+	// Speed that was actually written after step limiting (0-1)
+	Speed float32 `json:"speed"`
+	// Fan speed in revolutions per minute, -1 if not available
+	RPM int `json:"rpm"`
+
+	// Power draw in W, -1 if not available
+	Power float32 `json:"power"`
+	// Power limit and its range in W, 0 if the driver has no power1_cap
+	PowerCap    float32 `json:"powerCap"`
+	PowerCapMin float32 `json:"powerCapMin"`
+	PowerCapMax float32 `json:"powerCapMax"`
+
+	PowerMode string `json:"powerMode"`
+	// Entry of pp_power_profile_mode and the entries offered by the card, empty if the driver has none
+	PowerProfileMode  string   `json:"powerProfileMode"`
+	PowerProfileModes []string `json:"powerProfileModes"`
+	Curve             string   `json:"curve"`
+	State             State    `json:"state"`
+
+	// Counters since start-up
+	WriteErrors    uint64 `json:"writeErrors"`
+	FailsafeEvents uint64 `json:"failsafeEvents"`
+}
+
+// Listener receives the status of a device after every check cycle
+type Listener interface {
+	Update(status Status)
+}
@@ -0,0 +1,163 @@
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Gaps between two records longer than this (e.g. fanmi was not running) are not counted
+const maxRecordGap = 5 * time.Minute
+
+// Stats summarizes the telemetry records of one device
+type Stats struct {
+	Device       string
+	Records      int
+	From         time.Time
+	To           time.Time
+	Bands        map[int]time.Duration
+	AverageSpeed float32
+	PeakSpeed    float32
+	SpeedChanges int
+
+	speedSum  float64
+	lastSpeed float32
+	last      time.Time
+	lastBand  int
+}
+
+// Band returns the lower temperature of the band containing temp
+func Band(temp float32, bandWidth int) int {
+	return int(math.Floor(float64(temp)/float64(bandWidth))) * bandWidth
+}
+
+// ReadRecords reads all records from a telemetry file in CSV or JSON lines format
+func ReadRecords(r io.Reader) ([]Record, error) {
+	reader := bufio.NewReader(r)
+	first, err := reader.Peek(1)
+	if err == io.EOF {
+		return []Record{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, 0)
+	if bytes.Equal(first, []byte("{")) {
+		decoder := json.NewDecoder(reader)
+		for line := 1; ; line++ {
+			record := Record{}
+			err := decoder.Decode(&record)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("record %d: %s", line, err.Error())
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	for line := 1; ; line++ {
+		fields, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if fields[0] == csvHeader[0] {
+			continue
+		}
+		record, err := parseCSV(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err.Error())
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Summarize calculates the statistics per device, sorted by device name
+func Summarize(records []Record, bandWidth int) []*Stats {
+	devices := make(map[string]*Stats)
+
+	for _, record := range records {
+		stats, ok := devices[record.Device]
+		if !ok {
+			stats = &Stats{
+				Device: record.Device,
+				From:   record.Time,
+				Bands:  make(map[int]time.Duration),
+			}
+			devices[record.Device] = stats
+		} else {
+			gap := record.Time.Sub(stats.last)
+			if gap > 0 && gap <= maxRecordGap {
+				stats.Bands[stats.lastBand] += gap
+			}
+			if record.Speed != stats.lastSpeed {
+				stats.SpeedChanges++
+			}
+		}
+
+		stats.Records++
+		stats.To = record.Time
+		stats.speedSum += float64(record.Speed)
+		if record.Speed > stats.PeakSpeed {
+			stats.PeakSpeed = record.Speed
+		}
+		stats.lastSpeed = record.Speed
+		stats.last = record.Time
+		stats.lastBand = Band(record.Temperature, bandWidth)
+		if _, ok := stats.Bands[stats.lastBand]; !ok {
+			stats.Bands[stats.lastBand] = 0
+		}
+	}
+
+	result := make([]*Stats, 0, len(devices))
+	for _, stats := range devices {
+		stats.AverageSpeed = float32(stats.speedSum / float64(stats.Records))
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Device < result[b].Device
+	})
+	return result
+}
+
+// Print writes the statistics in a human readable form
+func Print(w io.Writer, stats []*Stats, bandWidth int) {
+	for _, s := range stats {
+		fmt.Fprintf(w, "Device %s: %d records from %s to %s\n", s.Device, s.Records, s.From.Format(time.DateTime), s.To.Format(time.DateTime))
+		fmt.Fprintf(w, "  Average speed: %6.2f%%\n", s.AverageSpeed)
+		fmt.Fprintf(w, "  Peak speed:    %6.2f%%\n", s.PeakSpeed)
+		fmt.Fprintf(w, "  Speed changes: %d\n", s.SpeedChanges)
+
+		var total time.Duration
+		bands := make([]int, 0, len(s.Bands))
+		for band, duration := range s.Bands {
+			bands = append(bands, band)
+			total += duration
+		}
+		sort.Ints(bands)
+
+		fmt.Fprintf(w, "  Time per temperature band:\n")
+		for _, band := range bands {
+			share := 0.0
+			if total > 0 {
+				share = float64(s.Bands[band]) / float64(total) * 100
+			}
+			label := fmt.Sprintf("%d-%d°C", band, band+bandWidth)
+			fmt.Fprintf(w, "    %-10s %12s %6.2f%% %s\n", label, s.Bands[band].Round(time.Second), share, strings.Repeat("#", int(share/5)))
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,260 @@
+package telemetry
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirion/fanmi/app/status"
+	"github.com/sirion/fanmi/app/units"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+var csvHeader = []string{"time", "device", "temperature", "temperatures", "targetSpeed", "speed", "rpm", "powerMode", "curve", "state"}
+
+// Record is one line of the telemetry file, speeds are in percent
+type Record struct {
+	Time         time.Time          `json:"time"`
+	Device       string             `json:"device"`
+	Temperature  float32            `json:"temperature"`
+	Temperatures map[string]float32 `json:"temperatures"`
+	TargetSpeed  float32            `json:"targetSpeed"`
+	Speed        float32            `json:"speed"`
+	RPM          int                `json:"rpm"`
+	PowerMode    string             `json:"powerMode"`
+	Curve        string             `json:"curve"`
+	State        status.State       `json:"state"`
+}
+
+// Writer appends one record per device and check cycle to a file, which is rotated when it exceeds maxSize
+type Writer struct {
+	filePath string
+	format   string
+	maxSize  int64
+	maxFiles int
+
+	mutex  sync.Mutex
+	file   *os.File
+	size   int64
+	errors func(error)
+}
+
+// NewWriter opens the telemetry file. maxSize is in bytes (0 disables rotation), maxFiles is the number of rotated
+// files kept. Errors while writing are reported to the errors function.
+func NewWriter(filePath, format string, maxSize int64, maxFiles int, errors func(error)) (*Writer, error) {
+	if format != FormatCSV && format != FormatJSONL {
+		return nil, fmt.Errorf("unknown telemetry format '%s'", format)
+	}
+
+	w := &Writer{
+		filePath: filePath,
+		format:   format,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		errors:   errors,
+	}
+	return w, w.open()
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening telemetry file %s: %s", w.filePath, err.Error())
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error opening telemetry file %s: %s", w.filePath, err.Error())
+	}
+
+	w.file = file
+	w.size = stat.Size()
+
+	if w.size == 0 && w.format == FormatCSV {
+		return w.write(encodeCSV(csvHeader))
+	}
+	return nil
+}
+
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.file.Close()
+}
+
+// Update implements status.Listener
+func (w *Writer) Update(s status.Status) {
+	record := NewRecord(s)
+
+	var line []byte
+	if w.format == FormatCSV {
+		line = encodeCSV(record.csv())
+	} else {
+		data, err := json.Marshal(record)
+		if err != nil {
+			w.errors(err)
+			return
+		}
+		line = append(data, '\n')
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		err := w.rotate()
+		if err != nil {
+			w.errors(err)
+			return
+		}
+	}
+
+	err := w.write(line)
+	if err != nil {
+		w.errors(err)
+	}
+}
+
+func (w *Writer) write(data []byte) error {
+	n, err := w.file.Write(data)
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("error writing telemetry file %s: %s", w.filePath, err.Error())
+	}
+	return nil
+}
+
+// rotate renames file to file.1, file.1 to file.2 and so on and removes the oldest file
+func (w *Writer) rotate() error {
+	w.file.Close()
+
+	if w.maxFiles > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", w.filePath, w.maxFiles))
+		for i := w.maxFiles - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", w.filePath, i), fmt.Sprintf("%s.%d", w.filePath, i+1))
+		}
+		err := os.Rename(w.filePath, w.filePath+".1")
+		if err != nil {
+			return fmt.Errorf("error rotating telemetry file %s: %s", w.filePath, err.Error())
+		}
+	} else {
+		os.Remove(w.filePath)
+	}
+
+	return w.open()
+}
+
+func NewRecord(s status.Status) Record {
+	record := Record{
+		Time:         s.Time,
+		Device:       s.Device,
+		Temperature:  s.Temperature,
+		Temperatures: make(map[string]float32, len(s.Temperatures)),
+		TargetSpeed:  units.Percent(s.TargetSpeed),
+		Speed:        units.Percent(s.Speed),
+		RPM:          s.RPM,
+		PowerMode:    s.PowerMode,
+		Curve:        s.Curve,
+		State:        s.State,
+	}
+	for _, temp := range s.Temperatures {
+		record.Temperatures[temp.Channel] = temp.Value
+	}
+	return record
+}
+
+func (r Record) csv() []string {
+	channels := make([]string, 0, len(r.Temperatures))
+	for channel := range r.Temperatures {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	for i, channel := range channels {
+		channels[i] = channel + "=" + formatFloat(r.Temperatures[channel])
+	}
+
+	return []string{
+		r.Time.Format(time.RFC3339Nano),
+		r.Device,
+		formatFloat(r.Temperature),
+		strings.Join(channels, ";"),
+		formatFloat(r.TargetSpeed),
+		formatFloat(r.Speed),
+		strconv.Itoa(r.RPM),
+		r.PowerMode,
+		r.Curve,
+		string(r.State),
+	}
+}
+
+// parseCSV reads a record written by Record.csv
+func parseCSV(fields []string) (Record, error) {
+	if len(fields) != len(csvHeader) {
+		return Record{}, fmt.Errorf("expected %d fields, found %d", len(csvHeader), len(fields))
+	}
+
+	record := Record{
+		Device:       fields[1],
+		Temperatures: make(map[string]float32),
+		PowerMode:    fields[7],
+		Curve:        fields[8],
+		State:        status.State(fields[9]),
+	}
+
+	var err error
+	record.Time, err = time.Parse(time.RFC3339Nano, fields[0])
+	if err != nil {
+		return record, err
+	}
+	if fields[3] != "" {
+		for _, channel := range strings.Split(fields[3], ";") {
+			name, value, _ := strings.Cut(channel, "=")
+			record.Temperatures[name], err = parseFloat(value)
+			if err != nil {
+				return record, err
+			}
+		}
+	}
+	record.RPM, err = strconv.Atoi(fields[6])
+	if err != nil {
+		return record, err
+	}
+	record.Temperature, err = parseFloat(fields[2])
+	if err != nil {
+		return record, err
+	}
+	record.TargetSpeed, err = parseFloat(fields[4])
+	if err != nil {
+		return record, err
+	}
+	record.Speed, err = parseFloat(fields[5])
+	return record, err
+}
+
+func encodeCSV(fields []string) []byte {
+	builder := &strings.Builder{}
+	writer := csv.NewWriter(builder)
+	writer.Write(fields)
+	writer.Flush()
+	return []byte(builder.String())
+}
+
+func formatFloat(value float32) string {
+	return strconv.FormatFloat(float64(value), 'f', 2, 32)
+}
+
+func parseFloat(value string) (float32, error) {
+	f, err := strconv.ParseFloat(value, 32)
+	return float32(f), err
+}
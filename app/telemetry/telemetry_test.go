@@ -0,0 +1,113 @@
+package telemetry
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/sirion/fanmi/app/status"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func testStatus(seconds int, temp, speed float32) status.Status {
+	return status.Status{
+		Device:       "card0",
+		Time:         start.Add(time.Duration(seconds) * time.Second),
+		Temperature:  temp,
+		Temperatures: []status.Temperature{{Channel: "edge", Value: temp}, {Channel: "junction", Value: temp + 10}},
+		TargetSpeed:  speed,
+		Speed:        speed,
+		RPM:          1000,
+		PowerMode:    "auto",
+		Curve:        "default",
+		State:        status.StateActive,
+	}
+}
+
+func TestWriter(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatJSONL} {
+		t.Run(format, func(t *testing.T) {
+			filePath := path.Join(t.TempDir(), "telemetry."+format)
+			writer, err := NewWriter(filePath, format, 0, 0, func(err error) { t.Error(err) })
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			writer.Update(testStatus(0, 45, 0.2))
+			writer.Update(testStatus(3, 46.5, 0.25))
+			writer.Close()
+
+			file, err := os.Open(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			records, err := ReadRecords(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(records) != 2 {
+				t.Fatalf("ReadRecords() returned %d records, want 2", len(records))
+			}
+			record := records[1]
+			if !record.Time.Equal(start.Add(3*time.Second)) || record.Device != "card0" || record.Temperature != 46.5 ||
+				record.Temperatures["junction"] != 56.5 || record.Speed != 25 || record.RPM != 1000 || record.Curve != "default" {
+				t.Errorf("ReadRecords() = %+v", record)
+			}
+		})
+	}
+}
+
+func TestWriter_rotate(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "telemetry.csv")
+	writer, err := NewWriter(filePath, FormatCSV, 300, 2, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		writer.Update(testStatus(i, 50, 0.5))
+	}
+	writer.Close()
+
+	for _, name := range []string{"telemetry.csv", "telemetry.csv.1", "telemetry.csv.2"} {
+		stat, err := os.Stat(path.Join(path.Dir(filePath), name))
+		if err != nil {
+			t.Errorf("rotated file %s missing: %s", name, err.Error())
+		} else if stat.Size() > 300 {
+			t.Errorf("file %s is bigger than maximum size: %d", name, stat.Size())
+		}
+	}
+	if _, err := os.Stat(filePath + ".3"); err == nil {
+		t.Errorf("more rotated files than configured")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	records := []Record{
+		NewRecord(testStatus(0, 45, 0.25)),
+		NewRecord(testStatus(10, 52, 0.25)),
+		NewRecord(testStatus(40, 58, 0.5)),
+		// fanmi was not running for an hour
+		NewRecord(testStatus(3640, 61, 0.75)),
+		NewRecord(testStatus(3650, 61, 0.75)),
+	}
+
+	stats := Summarize(records, 10)
+	if len(stats) != 1 {
+		t.Fatalf("Summarize() returned %d devices, want 1", len(stats))
+	}
+	s := stats[0]
+
+	wantBands := map[int]time.Duration{40: 10 * time.Second, 50: 30 * time.Second, 60: 10 * time.Second}
+	for band, want := range wantBands {
+		if s.Bands[band] != want {
+			t.Errorf("Summarize() band %d = %s, want %s", band, s.Bands[band], want)
+		}
+	}
+	if s.Records != 5 || s.SpeedChanges != 2 || s.PeakSpeed != 75 || s.AverageSpeed != 50 {
+		t.Errorf("Summarize() = %+v", s)
+	}
+}
@@ -1,25 +1,111 @@
 package ui
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/debug"
+	"github.com/sirion/fanmi/app/history"
+	"github.com/sirion/fanmi/app/keys"
+	"github.com/sirion/fanmi/app/status"
+	"github.com/sirion/fanmi/app/units"
 	"golang.org/x/term"
 )
 
+// Size used if the terminal size cannot be read
+const (
+	consoleWidth  = 80
+	consoleHeight = 24
+)
+
+// Descriptions of the actions in the help overlay
+var consoleActionDescriptions = map[string]string{
+	"select-previous":         "Select the previous card",
+	"select-next":             "Select the next card",
+	"toggle":                  "Activate/deactivate the selected card",
+	"next-curve":              "Switch the selected card to the next curve",
+	"previous-curve":          "Switch the selected card to the previous curve",
+	"power-auto":              "Set power mode to \"auto\"",
+	"power-low":               "Set power mode to \"low\"",
+	"power-high":              "Set power mode to \"high\"",
+	"speed-up":                "Raise the speed of the selected card, ignoring its curve",
+	"speed-down":              "Lower the speed of the selected card, ignoring its curve",
+	"power-cap-up":            "Raise the power limit of the selected card",
+	"power-cap-down":          "Lower the power limit of the selected card",
+	"next-profile":            "Switch the selected card to the next profile",
+	"next-power-profile-mode": "Switch the selected card to the next power profile mode",
+	"resume":                  "Let the curve control the selected card again",
+	"pause":                   "Hand the fan of the selected card to the driver for a while",
+	"help":                    "Show/hide this help",
+	"quit":                    "Quit",
+}
+
+// Actions shown together in the help line
+var consoleHelpGroups = []struct {
+	actions []string
+	label   string
+}{
+	{[]string{"select-previous", "select-next"}, "select"},
+	{[]string{"toggle"}, "on/off"},
+	{[]string{"next-curve", "previous-curve"}, "curve"},
+	{[]string{"power-auto", "power-low", "power-high"}, "power mode"},
+	{[]string{"speed-up", "speed-down"}, "speed"},
+	{[]string{"power-cap-up", "power-cap-down"}, "power limit"},
+	{[]string{"next-profile"}, "profile"},
+	{[]string{"next-power-profile-mode"}, "power profile"},
+	{[]string{"resume"}, "resume"},
+	{[]string{"pause"}, "pause"},
+	{[]string{"help"}, "help"},
+	{[]string{"quit"}, "quit"},
+}
+
+// ConsoleUI shows a table with one row per device at the bottom of the terminal, messages scroll above it
 type ConsoleUI struct {
-	temp    float32
-	speed   float32
 	done    chan bool
 	running chan bool
 	config  *configuration.Configuration
+	output  sync.Mutex
+
+	// Last status of each device by name
+	devices map[string]status.Status
+	// Devices switched off, kept here because the configuration must not be locked while drawing
+	inactive map[string]bool
+	// End of the pause of paused devices
+	paused map[string]time.Time
+	// End of the manual speed of devices
+	manualUntil map[string]time.Time
+	// Keys bound to each action, for the same reason
+	bindings map[string][]string
+	// The help overlay is shown instead of the table
+	help bool
+	// Device the keys act on
+	selected string
+	// First line of the table, the lines above are the scroll region for messages
+	tableTop int
+	// The table is no longer drawn after Exit
+	closed bool
 }
 
 func (ui *ConsoleUI) Init(conf *configuration.Configuration) chan bool {
 	ui.config = conf
 	ui.done = make(chan bool, 2)
 	ui.running = make(chan bool, 2)
+	ui.devices = make(map[string]status.Status)
+	ui.inactive = make(map[string]bool)
+	ui.paused = make(map[string]time.Time)
+	ui.manualUntil = make(map[string]time.Time)
+	ui.bindings = ui.readBindings()
+
+	// Log records are printed above the table
+	debug.SetOutput(ui)
 
 	go (func() {
 		// switch stdin into 'raw' mode
@@ -30,41 +116,30 @@ func (ui *ConsoleUI) Init(conf *configuration.Configuration) chan bool {
 		}
 		defer term.Restore(int(os.Stdin.Fd()), oldState)
 
-		bt := make([]byte, 4)
+		// Deferred calls do not run on os.Exit, the terminal is given back first
+		exit := func(message string) {
+			ui.Message(message)
+			ui.Exit()
+			term.Restore(int(os.Stdin.Fd()), oldState)
+			os.Exit(configuration.ExitCodeReadStdIn)
+		}
+
+		buffer := make([]byte, 64)
+		pending := []byte{}
 		for ui.config.Running {
-			n, err := os.Stdin.Read(bt)
+			n, err := os.Stdin.Read(buffer)
 			if err != nil {
-				ui.Message(fmt.Sprintf("Error reading from standard input: %s\n", err.Error()))
-				os.Exit(configuration.ExitCodeReadStdIn)
+				exit(fmt.Sprintf("Error reading from standard input: %s\n", err.Error()))
 			}
-			fmt.Printf("\n\r%x\n", bt[0:n])
 			if n == 0 {
-				ui.Message("End of input from console.\n")
-				os.Exit(configuration.ExitCodeReadStdIn)
-
-			} else if bt[0] == ' ' {
-				// Space toggles active/inactive
-				ui.config.Active = !ui.config.Active
-				ui.update()
-			} else if bt[0] == 'a' {
-				ui.config.SetPowerMode("auto")
-			} else if bt[0] == 'l' {
-				ui.config.SetPowerMode("low")
-			} else if bt[0] == 'h' {
-				ui.config.SetPowerMode("high")
-			} else if bt[0] == 'c' {
-				ui.config.NextCurve()
-			} else if bt[0] == 'q' {
-				ui.Message("Exiting\n")
-				ui.config.Running = false
-				ui.config.Active = false
-				ui.Exit()
-			} else if bt[0] == 3 {
-				// Ctrl-C
-				ui.Message("Ctrl-c caught - Exiting\n")
-				ui.config.Running = false
-				ui.config.Active = false
-				ui.Exit()
+				exit("End of input from console.\n")
+			}
+
+			// Escape sequences might be split between reads
+			var names []string
+			names, pending = keys.Split(append(pending, buffer[:n]...))
+			for _, key := range names {
+				ui.key(key)
 			}
 			ui.update()
 		}
@@ -78,6 +153,16 @@ func (ui *ConsoleUI) Run() {
 }
 
 func (ui *ConsoleUI) Exit() {
+	debug.SetOutput(os.Stderr)
+
+	// Give the whole terminal back and continue below the table
+	ui.output.Lock()
+	_, height := terminalSize()
+	fmt.Printf("\x1b[r\x1b[%d;1H\r\n", height)
+	ui.tableTop = 0
+	ui.closed = true
+	ui.output.Unlock()
+
 	ui.done <- true
 	ui.running <- true
 	os.Stderr.Sync()
@@ -89,40 +174,405 @@ func (*ConsoleUI) Fatal(exitCode int, message string) {
 	os.Exit(exitCode)
 }
 
-func (ui *ConsoleUI) Temperature(temp float32) {
-	ui.temp = temp
-	ui.update()
+func (ui *ConsoleUI) Temperature(device string, temp float32) {
+	// Shown with the next status update
+	ui.output.Lock()
+	defer ui.output.Unlock()
+
+	s := ui.devices[device]
+	s.Device = device
+	s.Temperature = temp
+	ui.devices[device] = s
+}
+
+func (ui *ConsoleUI) Speed(device string, speed float32) {
+	// Shown with the next status update
+	ui.output.Lock()
+	defer ui.output.Unlock()
+
+	s := ui.devices[device]
+	s.Device = device
+	s.Speed = speed
+	ui.devices[device] = s
+}
+
+func (*ConsoleUI) PowerMode(device string, mode string) {
+	// Shown from the status update
+}
+
+func (*ConsoleUI) History(*history.Buffer) {
+	// Ignored, the table has no room for charts
 }
 
-func (ui *ConsoleUI) Speed(speed float32) {
-	ui.speed = speed
-	ui.update()
+// Update shows the status of a device
+func (ui *ConsoleUI) Update(s status.Status) {
+	active := ui.config.DeviceActive(s.Device)
+	pausedUntil := ui.config.DevicePausedUntil(s.Device)
+	manualUntil := ui.config.DeviceSpeedUntil(s.Device)
+
+	ui.output.Lock()
+	defer ui.output.Unlock()
+
+	ui.devices[s.Device] = s
+	ui.inactive[s.Device] = !active
+	ui.paused[s.Device] = pausedUntil
+	ui.manualUntil[s.Device] = manualUntil
+	if ui.selected == "" {
+		ui.selected = s.Device
+	}
+	ui.draw()
+}
+
+func (ui *ConsoleUI) Message(message string) {
+	ui.output.Lock()
+	defer ui.output.Unlock()
+
+	ui.print(message)
 }
 
-func (*ConsoleUI) PowerMode(mode string) {
-	// Ignored for now
+// Write prints (log) output above the table
+func (ui *ConsoleUI) Write(p []byte) (int, error) {
+	ui.output.Lock()
+	defer ui.output.Unlock()
+
+	ui.print(string(p))
+	return len(p), nil
 }
 
-func (*ConsoleUI) Message(message string) {
-	fmt.Printf("\x1b[0K\r\n")
-	fmt.Print(message)
+// print writes the text at the bottom of the scroll region, so the table stays in place
+func (ui *ConsoleUI) print(text string) {
+	if ui.tableTop > 0 {
+		fmt.Printf("\x1b[%d;1H", ui.tableTop-1)
+	}
+	// The terminal is in raw mode, so line feeds need a carriage return
+	text = strings.ReplaceAll(strings.TrimRight(text, "\n"), "\n", "\r\n")
+	fmt.Printf("\r\n\x1b[0K%s", text)
 }
 
 func (ui *ConsoleUI) update() {
-	speedPercent := float32(int(ui.speed*10000)) / 100
-	prefix := ""
-	if !ui.config.Active {
-		prefix = "[INACTIVE] "
+	ui.output.Lock()
+	defer ui.output.Unlock()
+
+	ui.draw()
+}
+
+// sortedDevices returns the names of all devices, the output lock must be held
+func (ui *ConsoleUI) sortedDevices() []string {
+	names := make([]string, 0, len(ui.devices))
+	for name := range ui.devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (ui *ConsoleUI) selectedDevice() string {
+	ui.output.Lock()
+	defer ui.output.Unlock()
+
+	return ui.selected
+}
+
+func (ui *ConsoleUI) setInactive(device string) {
+	active := ui.config.DeviceActive(device)
+	pausedUntil := ui.config.DevicePausedUntil(device)
+
+	ui.output.Lock()
+	defer ui.output.Unlock()
+
+	ui.inactive[device] = !active
+	ui.paused[device] = pausedUntil
+}
+
+// readBindings returns the keys of each action from the configuration
+func (ui *ConsoleUI) readBindings() map[string][]string {
+	ui.config.RLock()
+	defer ui.config.RUnlock()
+
+	bindings := make(map[string][]string)
+	for key, action := range ui.config.Console.Keys {
+		if action != "" {
+			bindings[action] = append(bindings[action], key)
+		}
+	}
+	for _, keyNames := range bindings {
+		// Arrow keys and short names first, they are shown in the help line
+		sort.Slice(keyNames, func(i, j int) bool {
+			a, b := keys.Symbol(keyNames[i]) != keyNames[i], keys.Symbol(keyNames[j]) != keyNames[j]
+			if a != b {
+				return a
+			}
+			if len(keyNames[i]) != len(keyNames[j]) {
+				return len(keyNames[i]) < len(keyNames[j])
+			}
+			return keyNames[i] < keyNames[j]
+		})
+	}
+	return bindings
+}
+
+// key executes the action bound to the key
+func (ui *ConsoleUI) key(key string) {
+	ui.config.RLock()
+	action := ui.config.Console.Keys[key]
+	pause := time.Duration(ui.config.Console.PauseMinutes) * time.Minute
+	speedStep := units.FromPercent(ui.config.Console.SpeedStep)
+	manual := time.Duration(ui.config.Console.ManualMinutes) * time.Minute
+	powerCapStep := ui.config.Console.PowerCapStep
+	ui.config.RUnlock()
+	bindings := ui.readBindings()
+
+	ui.output.Lock()
+	ui.bindings = bindings
+	device := ui.selected
+	s := ui.devices[device]
+	if ui.help && action != "quit" && key != "ctrl-c" {
+		// Any key closes the help
+		ui.help = false
+		action = ""
 	}
-	powerMode := ""
-	if ui.config.PowerMode != "" {
-		powerMode = fmt.Sprintf("\t(Profile: %s)", ui.config.PowerMode)
+	ui.output.Unlock()
+
+	if key == "ctrl-c" {
+		// Raw mode does not send signals, so ctrl-c must always work
+		ui.Message("Ctrl-c caught - Exiting\n")
+		action = "quit"
+	} else if action == "quit" {
+		ui.Message("Exiting\n")
 	}
 
-	mode := ""
-	if len(ui.config.Curves) > 1 {
-		mode = fmt.Sprintf("\t(Curve: %s)", ui.config.CurrentCurve)
+	switch action {
+	case "select-previous":
+		ui.moveSelection(-1)
+	case "select-next":
+		ui.moveSelection(1)
+	case "power-auto":
+		ui.config.SetPowerMode("auto")
+	case "power-low":
+		ui.config.SetPowerMode("low")
+	case "power-high":
+		ui.config.SetPowerMode("high")
+	case "help":
+		ui.output.Lock()
+		ui.help = true
+		ui.output.Unlock()
+	case "quit":
+		ui.config.Running = false
+		ui.config.SetActive(false)
+		ui.Exit()
 	}
 
-	fmt.Printf("\r\x1b[0K%sTemperature: %2.0f°\tSpeed: %3.2f%%%s%s\r", prefix, ui.temp, speedPercent, powerMode, mode)
+	if device == "" {
+		return
+	}
+	switch action {
+	case "toggle":
+		ui.config.SetDeviceActive(device, !ui.config.DeviceActive(device))
+	case "next-curve":
+		ui.nextCurve(device, 1)
+	case "previous-curve":
+		ui.nextCurve(device, -1)
+	case "speed-up", "speed-down":
+		speed, ok := ui.config.DeviceSpeed(device)
+		if !ok {
+			speed = s.Speed
+		}
+		if action == "speed-down" {
+			speedStep = -speedStep
+		}
+		ui.config.SetDeviceSpeed(device, speed+speedStep, manual)
+	case "power-cap-up", "power-cap-down":
+		if s.PowerCap <= 0 {
+			ui.Message(fmt.Sprintf("The power limit of %s cannot be changed\n", device))
+			break
+		}
+		if action == "power-cap-down" {
+			powerCapStep = -powerCapStep
+		}
+		ui.config.SetDevicePowerCap(device, stepPowerCap(s, ui.config.DevicePowerCap(device), powerCapStep))
+	case "next-profile":
+		names := ui.config.ProfileNames()
+		if len(names) == 0 {
+			ui.Message("No profiles configured\n")
+			break
+		}
+		err := ui.config.ApplyProfile(device, cycle(names, ui.config.DeviceProfile(device), 1))
+		if err != nil {
+			ui.Message(err.Error() + "\n")
+		}
+	case "next-power-profile-mode":
+		if len(s.PowerProfileModes) == 0 {
+			ui.Message(fmt.Sprintf("%s has no power profile modes\n", device))
+			break
+		}
+		ui.config.SetDevicePowerProfileMode(device, cycle(s.PowerProfileModes, s.PowerProfileMode, 1))
+	case "resume":
+		ui.config.ResetDeviceSpeed(device)
+		ui.config.SetDeviceActive(device, true)
+	case "pause":
+		ui.config.PauseDevice(device, pause)
+	}
+	ui.setInactive(device)
+}
+
+// moveSelection selects the previous (-1) or next (1) device
+func (ui *ConsoleUI) moveSelection(step int) {
+	ui.output.Lock()
+	defer ui.output.Unlock()
+
+	names := ui.sortedDevices()
+	if len(names) == 0 {
+		return
+	}
+	i := slices.Index(names, ui.selected) + step
+	ui.selected = names[(i+len(names))%len(names)]
+}
+
+// nextCurve selects the curve after (1) or before (-1) the one used by the device
+func (ui *ConsoleUI) nextCurve(device string, step int) {
+	current, _ := ui.config.DeviceCurve(device)
+	ui.config.RLock()
+	names := slices.Clone(ui.config.CurveNames)
+	ui.config.RUnlock()
+	if len(names) == 0 {
+		return
+	}
+	ui.config.SetDeviceCurve(device, cycle(names, current, step))
+}
+
+// draw renders the table in the lines below the scroll region, the output lock must be held
+func (ui *ConsoleUI) draw() {
+	if ui.config == nil || ui.closed {
+		return
+	}
+	width, height := terminalSize()
+	lines := ui.table()
+	if ui.help {
+		lines = ui.helpOverlay()
+	}
+	lines = append(lines, ui.helpLine())
+
+	top := max(2, height-len(lines)+1)
+	if ui.tableTop == 0 {
+		// Scroll the existing output above the table
+		fmt.Print(strings.Repeat("\r\n", len(lines)))
+	} else if ui.tableTop > top {
+		// Scroll the messages up to make room for new rows
+		fmt.Printf("\x1b[%d;1H%s", ui.tableTop-1, strings.Repeat("\r\n", ui.tableTop-top))
+	} else if ui.tableTop < top {
+		// Remove the rows that become part of the scroll region
+		for row := ui.tableTop; row < top; row++ {
+			fmt.Printf("\x1b[%d;1H\x1b[2K", row)
+		}
+	}
+	if top != ui.tableTop {
+		// Messages are printed at the bottom of the scroll region
+		fmt.Printf("\x1b[1;%dr", top-1)
+		ui.tableTop = top
+	}
+
+	buffer := &bytes.Buffer{}
+	// Save the cursor in the scroll region and restore it after drawing
+	buffer.WriteString("\x1b7")
+	for i, line := range lines {
+		if top+i > height {
+			break
+		}
+		if len([]rune(line)) > width {
+			line = string([]rune(line)[:width])
+		}
+		fmt.Fprintf(buffer, "\x1b[%d;1H\x1b[2K%s", top+i, line)
+	}
+	buffer.WriteString("\x1b8")
+	os.Stdout.Write(buffer.Bytes())
+}
+
+// table returns the header and one row per device
+func (ui *ConsoleUI) table() []string {
+	buffer := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buffer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  DEVICE\tTEMPERATURES\tSPEED\tRPM\tWATTS\tCURVE\tPOWER\tSTATE")
+	for _, name := range ui.sortedDevices() {
+		s := ui.devices[name]
+
+		cursor := " "
+		if name == ui.selected {
+			cursor = ">"
+		}
+
+		temps := fmt.Sprintf("%.0f°", s.Temperature)
+		if len(s.Temperatures) > 0 {
+			channels := make([]string, 0, len(s.Temperatures))
+			for _, t := range s.Temperatures {
+				channels = append(channels, fmt.Sprintf("%s %.0f°", t.Channel, t.Value))
+			}
+			temps = strings.Join(channels, " ")
+		}
+
+		rpm := "-"
+		if s.RPM >= 0 && s.Time.Unix() > 0 {
+			rpm = fmt.Sprintf("%d", s.RPM)
+		}
+
+		state := string(s.State)
+		if until := ui.paused[name]; !until.IsZero() {
+			state = "paused until " + until.Format("15:04")
+		} else if ui.inactive[name] {
+			state = "inactive"
+		} else if until := ui.manualUntil[name]; s.State == status.StateManual && !until.IsZero() {
+			state = "manual until " + until.Format("15:04")
+		}
+
+		fmt.Fprintf(w, "%s %s\t%s\t%.1f%%\t%s\t%s\t%s\t%s\t%s\n", cursor, name, temps, units.Percent(s.Speed), rpm, powerLabel(s), s.Curve, strings.TrimSpace(s.PowerMode+" "+s.PowerProfileMode), state)
+	}
+	w.Flush()
+
+	return strings.Split(strings.TrimRight(buffer.String(), "\n"), "\n")
+}
+
+// helpOverlay lists the keys of every action, the output lock must be held
+func (ui *ConsoleUI) helpOverlay() []string {
+	buffer := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buffer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  KEYS\tACTION")
+	for _, action := range configuration.ConsoleActions {
+		keyNames := ui.bindings[action]
+		if len(keyNames) == 0 {
+			continue
+		}
+		symbols := make([]string, len(keyNames))
+		for i, key := range keyNames {
+			symbols[i] = keys.Symbol(key)
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", strings.Join(symbols, ", "), consoleActionDescriptions[action])
+	}
+	w.Flush()
+
+	lines := strings.Split(strings.TrimRight(buffer.String(), "\n"), "\n")
+	return append(lines, "  Press any key to close the help")
+}
+
+// helpLine shows the main keys, the output lock must be held
+func (ui *ConsoleUI) helpLine() string {
+	parts := []string{}
+	for _, group := range consoleHelpGroups {
+		symbols := []string{}
+		for _, action := range group.actions {
+			if keyNames := ui.bindings[action]; len(keyNames) > 0 {
+				symbols = append(symbols, keys.Symbol(keyNames[0]))
+			}
+		}
+		if len(symbols) > 0 {
+			parts = append(parts, strings.Join(symbols, "/")+" "+group.label)
+		}
+	}
+	return strings.Join(parts, "  ")
+}
+
+func terminalSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return consoleWidth, consoleHeight
+	}
+	return width, height
 }
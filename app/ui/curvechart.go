@@ -0,0 +1,384 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/units"
+)
+
+const (
+	chartPadding    = 10
+	chartAxisWidth  = 40
+	chartAxisHeight = 20
+	chartPointSize  = 10
+	chartMaxTemp    = 100
+)
+
+// CurveChart draws a fan curve as line chart. Points can be dragged, added by tapping and deleted with a secondary
+// tap (right click), temperatures and speeds snap to multiples of Snap.
+type CurveChart struct {
+	widget.BaseWidget
+
+	// Step in °C and % (or 10 rpm) points snap to
+	Snap float32
+	// Called after the curve was changed by the user
+	OnChanged func(curve configuration.Values)
+	// Called when a point is selected or moved, nil if no point is selected
+	OnSelected func(entry *configuration.Entry)
+
+	curve    configuration.Values
+	selected int
+	dragging bool
+	// Current temperature and speed, temp is negative if unknown
+	temp  float32
+	speed float32
+}
+
+func NewCurveChart() *CurveChart {
+	chart := &CurveChart{Snap: 1, selected: -1, temp: -1}
+	chart.ExtendBaseWidget(chart)
+	return chart
+}
+
+// SetCurve shows a copy of the curve
+func (c *CurveChart) SetCurve(curve configuration.Values) {
+	c.curve = append(configuration.Values{}, curve...)
+	c.selected = -1
+	c.dragging = false
+	c.Refresh()
+	c.selectedChanged()
+}
+
+// Curve returns the edited curve
+func (c *CurveChart) Curve() configuration.Values {
+	return append(configuration.Values{}, c.curve...)
+}
+
+// SetOperatingPoint shows the current temperature and fan speed
+func (c *CurveChart) SetOperatingPoint(temp, speed float32) {
+	c.temp = temp
+	c.speed = speed
+	c.Refresh()
+}
+
+func (c *CurveChart) MinSize() fyne.Size {
+	return fyne.NewSize(400, 250)
+}
+
+func (c *CurveChart) CreateRenderer() fyne.WidgetRenderer {
+	r := &curveChartRenderer{chart: c}
+	r.Refresh()
+	return r
+}
+
+func (c *CurveChart) Tapped(event *fyne.PointEvent) {
+	if i := c.pointAt(event.Position); i >= 0 {
+		c.selected = i
+		c.Refresh()
+		c.selectedChanged()
+		return
+	}
+
+	entry := c.entryAt(event.Position)
+	for _, existing := range c.curve {
+		if existing.Temp == entry.Temp {
+			return
+		}
+	}
+	c.curve = append(c.curve, entry)
+	c.sort()
+	for i := range c.curve {
+		if c.curve[i] == entry {
+			c.selected = i
+		}
+	}
+	c.changed()
+}
+
+func (c *CurveChart) TappedSecondary(event *fyne.PointEvent) {
+	i := c.pointAt(event.Position)
+	if i < 0 || len(c.curve) <= 1 {
+		return
+	}
+	c.curve = append(c.curve[:i], c.curve[i+1:]...)
+	c.selected = -1
+	c.changed()
+}
+
+func (c *CurveChart) Dragged(event *fyne.DragEvent) {
+	if !c.dragging {
+		start := event.Position.Subtract(event.Dragged)
+		c.selected = c.pointAt(start)
+		c.dragging = c.selected >= 0
+		if !c.dragging {
+			return
+		}
+	}
+
+	entry := c.entryAt(event.Position)
+	// Points cannot pass their neighbours, so the order and unique temperatures are kept
+	if c.selected > 0 {
+		entry.Temp = max(entry.Temp, c.curve[c.selected-1].Temp+c.Snap)
+	}
+	if c.selected < len(c.curve)-1 {
+		entry.Temp = min(entry.Temp, c.curve[c.selected+1].Temp-c.Snap)
+	}
+	c.curve[c.selected] = entry
+	c.Refresh()
+	c.selectedChanged()
+}
+
+func (c *CurveChart) DragEnd() {
+	if c.dragging {
+		c.dragging = false
+		c.changed()
+	}
+}
+
+func (c *CurveChart) changed() {
+	c.Refresh()
+	c.selectedChanged()
+	if c.OnChanged != nil {
+		c.OnChanged(c.Curve())
+	}
+}
+
+func (c *CurveChart) selectedChanged() {
+	if c.OnSelected == nil {
+		return
+	}
+	if c.selected < 0 || c.selected >= len(c.curve) {
+		c.OnSelected(nil)
+		return
+	}
+	entry := c.curve[c.selected]
+	c.OnSelected(&entry)
+}
+
+func (c *CurveChart) sort() {
+	for i := 1; i < len(c.curve); i++ {
+		for j := i; j > 0 && c.curve[j].Temp < c.curve[j-1].Temp; j-- {
+			c.curve.Swap(j, j-1)
+		}
+	}
+}
+
+// pointAt returns the index of the point at the position, -1 if there is none
+func (c *CurveChart) pointAt(position fyne.Position) int {
+	for i, entry := range c.curve {
+		p := c.position(entry.Temp, entry.Value())
+		if math.Abs(float64(p.X-position.X)) <= chartPointSize && math.Abs(float64(p.Y-position.Y)) <= chartPointSize {
+			return i
+		}
+	}
+	return -1
+}
+
+// maxTemp is the highest temperature shown
+func (c *CurveChart) maxTemp() float32 {
+	return curveMaxTemp(c.curve)
+}
+
+// maxValue is the highest speed (1) or RPM shown
+func (c *CurveChart) maxValue() float32 {
+	return curveMaxValue(c.curve)
+}
+
+// curveMaxTemp is the highest temperature shown in charts of the curve
+func curveMaxTemp(curve configuration.Values) float32 {
+	maxTemp := float32(chartMaxTemp)
+	for _, entry := range curve {
+		maxTemp = max(maxTemp, float32(math.Ceil(float64(entry.Temp)/10)*10))
+	}
+	return maxTemp
+}
+
+// curveMaxValue is the highest speed (1) or RPM shown in charts of the curve
+func curveMaxValue(curve configuration.Values) float32 {
+	if !curve.IsRPM() {
+		return 1
+	}
+	maxRPM := float32(1000)
+	for _, entry := range curve {
+		maxRPM = max(maxRPM, float32(math.Ceil(float64(entry.RPM)*1.2/500)*500))
+	}
+	return maxRPM
+}
+
+// plot returns the area of the chart without axis labels
+func (c *CurveChart) plot() (fyne.Position, fyne.Size) {
+	size := c.Size()
+	return fyne.NewPos(chartAxisWidth, chartPadding),
+		fyne.NewSize(size.Width-chartAxisWidth-chartPadding, size.Height-chartAxisHeight-chartPadding)
+}
+
+// position converts a temperature and value (speed or RPM) to a position in the widget
+func (c *CurveChart) position(temp, value float32) fyne.Position {
+	origin, size := c.plot()
+	return fyne.NewPos(
+		origin.X+temp/c.maxTemp()*size.Width,
+		origin.Y+size.Height-value/c.maxValue()*size.Height,
+	)
+}
+
+// entryAt converts a position to a curve entry, snapped and limited to the chart
+func (c *CurveChart) entryAt(position fyne.Position) configuration.Entry {
+	origin, size := c.plot()
+	temp := (position.X - origin.X) / size.Width * c.maxTemp()
+	value := (origin.Y + size.Height - position.Y) / size.Height * c.maxValue()
+
+	entry := configuration.Entry{Temp: clamp(snap(temp, c.Snap), 0, c.maxTemp())}
+	if c.curve.IsRPM() {
+		entry.RPM = int(clamp(snap(value, c.Snap*10), 0, c.maxValue()))
+		entry.InRPM = true
+	} else {
+		entry.Speed = units.FromPercent(clamp(snap(units.Percent(value), c.Snap), 0, 100))
+	}
+	return entry
+}
+
+func snap(value, step float32) float32 {
+	if step <= 0 {
+		return value
+	}
+	return float32(math.Round(float64(value/step))) * step
+}
+
+func clamp(value, low, high float32) float32 {
+	return max(low, min(high, value))
+}
+
+type curveChartRenderer struct {
+	chart   *CurveChart
+	objects []fyne.CanvasObject
+}
+
+func (r *curveChartRenderer) Layout(fyne.Size) {
+	r.Refresh()
+}
+
+func (r *curveChartRenderer) MinSize() fyne.Size {
+	return r.chart.MinSize()
+}
+
+// Refresh creates all objects again, a curve has only a few points
+func (r *curveChartRenderer) Refresh() {
+	c := r.chart
+	r.objects = make([]fyne.CanvasObject, 0)
+	origin, size := c.plot()
+	if size.Width <= 0 || size.Height <= 0 {
+		return
+	}
+
+	grid := theme.DisabledColor()
+	foreground := theme.ForegroundColor()
+	maxTemp := c.maxTemp()
+	maxValue := c.maxValue()
+
+	// Temperature grid every 10 °C, labelled every 20 °C
+	for temp := float32(0); temp <= maxTemp; temp += 10 {
+		bottom := c.position(temp, 0)
+		r.line(bottom, c.position(temp, maxValue), grid, 1)
+		if int(temp)%20 == 0 {
+			r.label(fmt.Sprintf("%.0f°", temp), fyne.NewPos(bottom.X-10, origin.Y+size.Height+2), foreground)
+		}
+	}
+	// Speed grid every 10 %, labelled every 20 %
+	for step := 0; step <= 10; step++ {
+		value := maxValue * float32(step) / 10
+		left := c.position(0, value)
+		r.line(left, c.position(maxTemp, value), grid, 1)
+		if step%2 == 0 {
+			text := fmt.Sprintf("%.0f%%", units.Percent(value))
+			if c.curve.IsRPM() {
+				text = fmt.Sprintf("%.0f", value)
+			}
+			r.label(text, fyne.NewPos(2, left.Y-8), foreground)
+		}
+	}
+
+	if len(c.curve) > 0 {
+		// The first and last value are kept beyond the curve
+		first, last := c.curve[0], c.curve[len(c.curve)-1]
+		r.line(c.position(0, first.Value()), c.position(first.Temp, first.Value()), theme.PrimaryColor(), 2)
+		r.line(c.position(last.Temp, last.Value()), c.position(maxTemp, last.Value()), theme.PrimaryColor(), 2)
+		for i := 1; i < len(c.curve); i++ {
+			r.line(c.position(c.curve[i-1].Temp, c.curve[i-1].Value()), c.position(c.curve[i].Temp, c.curve[i].Value()), theme.PrimaryColor(), 2)
+		}
+		for i, entry := range c.curve {
+			pointColor := theme.PrimaryColor()
+			if i == c.selected {
+				pointColor = foreground
+			}
+			r.point(c.position(entry.Temp, entry.Value()), pointColor, chartPointSize)
+		}
+	}
+
+	if c.temp >= 0 {
+		value := c.speed
+		if c.curve.IsRPM() {
+			value = c.valueAt(c.temp)
+		}
+		r.line(c.position(c.temp, 0), c.position(c.temp, maxValue), theme.ErrorColor(), 1)
+		r.point(c.position(c.temp, value), theme.ErrorColor(), chartPointSize*0.8)
+	}
+
+	canvas.Refresh(c)
+}
+
+// valueAt interpolates the curve at the temperature
+func (c *CurveChart) valueAt(temp float32) float32 {
+	return curveValueAt(c.curve, temp)
+}
+
+// curveValueAt interpolates the speed or RPM of a sorted curve at the temperature
+func curveValueAt(curve configuration.Values, temp float32) float32 {
+	if len(curve) == 0 {
+		return 0
+	}
+	if temp <= curve[0].Temp {
+		return curve[0].Value()
+	}
+	for i := 1; i < len(curve); i++ {
+		low, high := curve[i-1], curve[i]
+		if temp <= high.Temp {
+			return low.Value() + (temp-low.Temp)/(high.Temp-low.Temp)*(high.Value()-low.Value())
+		}
+	}
+	return curve[len(curve)-1].Value()
+}
+
+func (r *curveChartRenderer) line(from, to fyne.Position, lineColor color.Color, width float32) {
+	line := canvas.NewLine(lineColor)
+	line.StrokeWidth = width
+	line.Position1 = from
+	line.Position2 = to
+	r.objects = append(r.objects, line)
+}
+
+func (r *curveChartRenderer) point(center fyne.Position, pointColor color.Color, size float32) {
+	point := canvas.NewCircle(pointColor)
+	point.Resize(fyne.NewSize(size, size))
+	point.Move(center.SubtractXY(size/2, size/2))
+	r.objects = append(r.objects, point)
+}
+
+func (r *curveChartRenderer) label(text string, position fyne.Position, textColor color.Color) {
+	label := canvas.NewText(text, textColor)
+	label.TextSize = theme.CaptionTextSize()
+	label.Move(position)
+	r.objects = append(r.objects, label)
+}
+
+func (r *curveChartRenderer) Objects() []fyne.CanvasObject {
+	return r.objects
+}
+
+func (r *curveChartRenderer) Destroy() {}
@@ -0,0 +1,221 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/units"
+)
+
+// Curve created by the "New" button
+var newCurve = configuration.Values{{Temp: 40, Speed: 0}, {Temp: 90, Speed: 1}}
+
+// curveEditor is a window to edit, create, duplicate, rename and delete fan curves
+type curveEditor struct {
+	ui  *FyneUI
+	win fyne.Window
+
+	// Name of the edited curve
+	name   string
+	curves *widget.Select
+	chart  *CurveChart
+	point  *widget.Label
+	active *widget.Button
+	delete *widget.Button
+}
+
+func (ui *FyneUI) showCurveEditor() {
+	if ui.editor != nil {
+		ui.editor.win.RequestFocus()
+		return
+	}
+
+	e := &curveEditor{ui: ui}
+	ui.editor = e
+
+	e.chart = NewCurveChart()
+	e.chart.OnChanged = e.curveChanged
+	e.chart.OnSelected = e.pointSelected
+	e.point = widget.NewLabel("")
+	e.curves = widget.NewSelect(nil, e.edit)
+	e.active = widget.NewButtonWithIcon("Use", theme.ConfirmIcon(), func() {
+		ui.config.SetCurve(e.name)
+		ui.curvesChanged()
+	})
+	e.delete = widget.NewButtonWithIcon("", theme.DeleteIcon(), e.deleteCurve)
+
+	snap := widget.NewCheck("snap to 5", func(b bool) {
+		e.chart.Snap = 1
+		if b {
+			e.chart.Snap = 5
+		}
+	})
+
+	content := container.NewBorder(
+		container.NewHBox(
+			e.curves,
+			widget.NewButtonWithIcon("", theme.ContentAddIcon(), e.createCurve),
+			widget.NewButtonWithIcon("", theme.ContentCopyIcon(), e.duplicateCurve),
+			widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), e.renameCurve),
+			e.delete,
+			layout.NewSpacer(),
+			e.active,
+		),
+		container.NewHBox(
+			e.point,
+			layout.NewSpacer(),
+			snap,
+		),
+		nil, nil,
+		e.chart,
+	)
+
+	e.win = ui.app.NewWindow("Fan Curves")
+	e.win.SetContent(content)
+	e.win.SetOnClosed(func() {
+		ui.editor = nil
+	})
+
+	ui.config.RLock()
+	e.name = ui.config.CurrentCurve
+	ui.config.RUnlock()
+	e.update()
+
+	e.win.Show()
+}
+
+// update shows the curve names and the edited curve
+func (e *curveEditor) update() {
+	e.ui.config.RLock()
+	names := slices.Clone(e.ui.config.CurveNames)
+	curve, ok := e.ui.config.Curves[e.name]
+	current := e.ui.config.CurrentCurve
+	e.ui.config.RUnlock()
+
+	if !ok && e.name != current {
+		e.edit(current)
+		return
+	}
+
+	e.curves.Options = names
+	e.curves.Selected = e.name
+	e.curves.Refresh()
+	e.chart.SetCurve(curve)
+
+	if e.name == current {
+		e.active.Disable()
+		e.delete.Disable()
+	} else {
+		e.active.Enable()
+		e.delete.Enable()
+	}
+}
+
+// edit shows the curve with the name
+func (e *curveEditor) edit(name string) {
+	if name == e.name && e.curves.Selected == name {
+		return
+	}
+	e.name = name
+	e.update()
+}
+
+func (e *curveEditor) curveChanged(curve configuration.Values) {
+	err := e.ui.config.PutCurve(e.name, curve)
+	if err != nil {
+		dialog.ShowError(err, e.win)
+		e.update()
+		return
+	}
+	e.ui.changed()
+}
+
+func (e *curveEditor) pointSelected(entry *configuration.Entry) {
+	switch {
+	case entry == nil:
+		e.point.SetText("Drag points, tap to add, right click to delete")
+	case entry.InRPM:
+		e.point.SetText(fmt.Sprintf("%.0f °C: %d rpm", entry.Temp, entry.RPM))
+	default:
+		e.point.SetText(fmt.Sprintf("%.0f °C: %.0f %%", entry.Temp, units.Percent(entry.Speed)))
+	}
+}
+
+// operatingPoint shows the current temperature and speed in the chart
+func (e *curveEditor) operatingPoint(temp, speed float32) {
+	e.chart.SetOperatingPoint(temp, speed)
+}
+
+func (e *curveEditor) createCurve() {
+	e.askName("New Curve", "", func(name string) error {
+		return e.ui.config.PutCurve(name, slices.Clone(newCurve))
+	})
+}
+
+func (e *curveEditor) duplicateCurve() {
+	e.askName("Duplicate Curve", e.name+" copy", func(name string) error {
+		return e.ui.config.PutCurve(name, e.chart.Curve())
+	})
+}
+
+func (e *curveEditor) renameCurve() {
+	oldName := e.name
+	e.askName("Rename Curve", oldName, func(name string) error {
+		return e.ui.config.RenameCurve(oldName, name)
+	})
+}
+
+func (e *curveEditor) deleteCurve() {
+	name := e.name
+	dialog.ShowConfirm("Delete Curve", fmt.Sprintf("Delete curve '%s'?", name), func(ok bool) {
+		if !ok {
+			return
+		}
+		err := e.ui.config.DeleteCurve(name)
+		if err != nil {
+			dialog.ShowError(err, e.win)
+			return
+		}
+		e.ui.config.RLock()
+		e.name = e.ui.config.CurrentCurve
+		e.ui.config.RUnlock()
+		e.ui.curvesChanged()
+	}, e.win)
+}
+
+// askName asks for a curve name and shows the curve after apply succeeded
+func (e *curveEditor) askName(title, name string, apply func(name string) error) {
+	entry := widget.NewEntry()
+	entry.SetText(name)
+	dialog.ShowForm(title, "OK", "Cancel", []*widget.FormItem{widget.NewFormItem("Name", entry)}, func(ok bool) {
+		if !ok {
+			return
+		}
+		if entry.Text == name && name == e.name {
+			// Renamed to the same name
+			return
+		}
+		e.ui.config.RLock()
+		_, exists := e.ui.config.Curves[entry.Text]
+		e.ui.config.RUnlock()
+		if exists {
+			dialog.ShowError(fmt.Errorf("curve '%s' already exists", entry.Text), e.win)
+			return
+		}
+
+		err := apply(entry.Text)
+		if err != nil {
+			dialog.ShowError(err, e.win)
+			return
+		}
+		e.name = entry.Text
+		e.ui.curvesChanged()
+	}, e.win)
+}
@@ -0,0 +1,317 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/sirion/fanmi/app/status"
+	"github.com/sirion/fanmi/app/units"
+)
+
+// Durations of the manual speed, 0 keeps it until it is switched off
+var (
+	manualDurations     = []time.Duration{0, 5 * time.Minute, 10 * time.Minute, 30 * time.Minute, time.Hour}
+	manualDurationNames = []string{"until off", "5 min", "10 min", "30 min", "60 min"}
+)
+
+// devicePanel shows temperature and speed of one graphics card with its own curve and active toggle
+type devicePanel struct {
+	ui   *FyneUI
+	name string
+
+	temp    *canvas.Text
+	speed   *canvas.Text
+	curve   *widget.Select
+	profile *widget.Select
+	active  *widget.Check
+
+	// Power draw and power limit, only shown if the driver has a power limit
+	power    *widget.Label
+	powerCap *widget.Slider
+	powerRow fyne.CanvasObject
+	// Entries of pp_power_profile_mode, only shown if the card offers them
+	powerProfileMode *widget.Select
+	powerProfileRow  fyne.CanvasObject
+
+	// Fixed speed instead of the curve
+	manual      *widget.Check
+	manualSpeed *widget.Slider
+	manualLabel *widget.Label
+	manualFor   *widget.Select
+
+	content fyne.CanvasObject
+	// Last measured values
+	lastTemp  float32
+	lastSpeed float32
+	powerMode string
+}
+
+func newDevicePanel(ui *FyneUI, name string) *devicePanel {
+	p := &devicePanel{ui: ui, name: name}
+
+	p.temp = NewBigText("0")
+	p.temp.Alignment = fyne.TextAlignTrailing
+	p.speed = NewBigText("0")
+	p.speed.Alignment = fyne.TextAlignTrailing
+
+	p.curve = widget.NewSelect(nil, func(curveName string) {
+		if current, _ := ui.config.DeviceCurve(name); curveName != current {
+			ui.config.SetDeviceCurve(name, curveName)
+			ui.curvesChanged()
+		}
+	})
+	p.profile = widget.NewSelect(nil, func(profileName string) {
+		if profileName == "" || profileName == ui.config.DeviceProfile(name) {
+			return
+		}
+		err := ui.config.ApplyProfile(name, profileName)
+		if err != nil {
+			ui.Message(err.Error() + "\n")
+		}
+		ui.curvesChanged()
+	})
+	p.profile.PlaceHolder = "(no profile)"
+	p.active = widget.NewCheck("", func(b bool) {
+		ui.config.SetDeviceActive(name, b)
+		p.updateColors()
+		if ui.tray != nil {
+			ui.tray.update()
+		}
+	})
+	p.active.Checked = true
+	p.updateCurve()
+
+	p.power = widget.NewLabel("-")
+	p.powerCap = widget.NewSlider(0, 0)
+	p.powerCap.Step = 5
+	p.powerCap.OnChanged = func(watts float64) {
+		ui.config.SetDevicePowerCap(name, float32(watts))
+		p.updateProfile()
+		ui.changedLater()
+	}
+	p.powerRow = container.NewBorder(nil, nil, widget.NewLabel("Power limit"), p.power, p.powerCap)
+	p.powerRow.Hide()
+
+	p.powerProfileMode = widget.NewSelect(nil, func(mode string) {
+		if mode != "" && mode != ui.config.DevicePowerProfileMode(name) {
+			ui.config.SetDevicePowerProfileMode(name, mode)
+			ui.changed()
+		}
+	})
+	p.powerProfileRow = container.NewBorder(nil, nil, widget.NewLabel("Power profile"), nil, p.powerProfileMode)
+	p.powerProfileRow.Hide()
+
+	p.manualSpeed = widget.NewSlider(0, 100)
+	p.manualSpeed.Step = 1
+	p.manualSpeed.OnChanged = func(float64) {
+		if p.manual.Checked {
+			p.applyManual()
+		} else {
+			p.updateManual()
+		}
+	}
+	p.manualLabel = widget.NewLabel("")
+	p.manualFor = widget.NewSelect(manualDurationNames, func(string) {
+		if p.manual.Checked {
+			p.applyManual()
+		}
+	})
+	p.manualFor.Selected = manualDurationNames[0]
+	p.manual = widget.NewCheck("Manual", func(b bool) {
+		if b {
+			// Start at the current speed
+			p.manualSpeed.Value = float64(units.Percent(p.lastSpeed))
+			p.manualSpeed.Refresh()
+		}
+		p.applyManual()
+	})
+	p.updateManual()
+
+	p.content = container.NewVBox(
+		container.NewHBox(
+			widget.NewLabelWithStyle(name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			layout.NewSpacer(),
+			p.profile,
+			p.curve,
+			p.active,
+		),
+		container.NewHBox(
+			container.NewVBox(
+				NewBigText("Temperature:"),
+				layout.NewSpacer(),
+				NewBigText("Fan Speed:"),
+			),
+			layout.NewSpacer(),
+			container.NewVBox(
+				p.temp,
+				layout.NewSpacer(),
+				p.speed,
+			),
+			container.NewVBox(
+				NewBigText("°C"),
+				layout.NewSpacer(),
+				NewBigText("%"),
+			),
+		),
+		p.powerRow,
+		p.powerProfileRow,
+		container.NewBorder(nil, nil, p.manual, container.NewHBox(p.manualLabel, p.manualFor), p.manualSpeed),
+	)
+	p.updateColors()
+
+	return p
+}
+
+// updateCurve shows the curve names and the curve used by the device
+func (p *devicePanel) updateCurve() {
+	p.ui.config.RLock()
+	p.curve.Options = slices.Clone(p.ui.config.CurveNames)
+	p.ui.config.RUnlock()
+	p.curve.Selected, _ = p.ui.config.DeviceCurve(p.name)
+	p.curve.Refresh()
+	p.updateProfile()
+}
+
+// updateProfile shows the profile names and the profile matching the settings of the device
+func (p *devicePanel) updateProfile() {
+	p.profile.Options = p.ui.config.ProfileNames()
+	p.profile.Selected = p.ui.config.DeviceProfile(p.name)
+	if len(p.profile.Options) == 0 {
+		p.profile.Hide()
+	} else {
+		p.profile.Show()
+	}
+	p.profile.Refresh()
+}
+
+// setPower shows power draw and power limit, the slider covers the range of the driver
+func (p *devicePanel) setPower(s status.Status) {
+	p.power.SetText(powerLabel(s))
+	if s.PowerCap <= 0 {
+		p.powerRow.Hide()
+		return
+	}
+	if p.powerCap.Max == 0 {
+		p.powerCap.Min, p.powerCap.Max = float64(s.PowerCapMin), float64(s.PowerCapMax)
+		if s.PowerCapMax <= s.PowerCapMin {
+			// The driver does not report its range
+			p.powerCap.Min, p.powerCap.Max = 0, float64(2*s.PowerCap)
+		}
+	}
+	watts := p.ui.config.DevicePowerCap(p.name)
+	if watts <= 0 {
+		watts = s.PowerCap
+	}
+	if p.powerCap.Value != float64(watts) {
+		p.powerCap.Value = float64(watts)
+		p.powerCap.Refresh()
+	}
+	p.powerRow.Show()
+}
+
+// updateColors greys out the values if the device is not controlled
+func (p *devicePanel) updateColors() {
+	textColor := theme.ForegroundColor()
+	if !p.ui.config.DeviceActive(p.name) {
+		textColor = theme.DisabledColor()
+	}
+	p.temp.Color = textColor
+	p.speed.Color = textColor
+	p.temp.Refresh()
+	p.speed.Refresh()
+}
+
+// applyManual fixes the speed of the device or lets the curve control it again
+func (p *devicePanel) applyManual() {
+	if !p.manual.Checked {
+		p.ui.config.ResetDeviceSpeed(p.name)
+		p.updateManual()
+		return
+	}
+	duration := manualDurations[max(0, slices.Index(manualDurationNames, p.manualFor.Selected))]
+	p.ui.config.SetDeviceSpeed(p.name, units.FromPercent(float32(p.manualSpeed.Value)), duration)
+	p.updateManual()
+}
+
+// updateManual shows the fixed speed, it might have been set in another way or expired
+func (p *devicePanel) updateManual() {
+	speed, ok := p.ui.config.DeviceSpeed(p.name)
+	if ok != p.manual.Checked {
+		p.manual.Checked = ok
+		p.manual.Refresh()
+	}
+	if ok && float32(p.manualSpeed.Value) != units.Percent(speed) {
+		p.manualSpeed.Value = float64(units.Percent(speed))
+		p.manualSpeed.Refresh()
+	}
+
+	text := fmt.Sprintf("%3.0f %%", p.manualSpeed.Value)
+	if until := p.ui.config.DeviceSpeedUntil(p.name); ok && !until.IsZero() {
+		text += " until " + until.Format("15:04")
+	}
+	p.manualLabel.SetText(text)
+}
+
+// setPowerProfileMode shows the entries of pp_power_profile_mode offered by the card
+func (p *devicePanel) setPowerProfileMode(s status.Status) {
+	if len(s.PowerProfileModes) == 0 {
+		p.powerProfileRow.Hide()
+		return
+	}
+	if !slices.Equal(p.powerProfileMode.Options, s.PowerProfileModes) || p.powerProfileMode.Selected != s.PowerProfileMode {
+		p.powerProfileMode.Options = s.PowerProfileModes
+		p.powerProfileMode.Selected = s.PowerProfileMode
+		p.powerProfileMode.Refresh()
+	}
+	p.powerProfileRow.Show()
+}
+
+func (p *devicePanel) setTemperature(temp float32) {
+	p.lastTemp = temp
+	p.temp.Text = fmt.Sprintf("%2.0f", temp)
+	p.temp.Refresh()
+}
+
+func (p *devicePanel) setSpeed(speed float32) {
+	p.lastSpeed = speed
+	p.speed.Text = fmt.Sprintf("%2.1f", units.Percent(speed))
+	p.speed.Refresh()
+	p.updateManual()
+}
+
+// device returns the panel of the device, it is added to the main window on first use
+func (ui *FyneUI) device(name string) *devicePanel {
+	ui.devicesMutex.Lock()
+	defer ui.devicesMutex.Unlock()
+
+	p, ok := ui.devices[name]
+	if !ok {
+		p = newDevicePanel(ui, name)
+		ui.devices[name] = p
+		ui.deviceBox.Add(p.content)
+	}
+	return p
+}
+
+// panels returns the panels of all devices sorted by name
+func (ui *FyneUI) panels() []*devicePanel {
+	ui.devicesMutex.Lock()
+	defer ui.devicesMutex.Unlock()
+
+	panels := make([]*devicePanel, 0, len(ui.devices))
+	for _, p := range ui.devices {
+		panels = append(panels, p)
+	}
+	sort.Slice(panels, func(a, b int) bool {
+		return panels[a].name < panels[b].name
+	})
+	return panels
+}
@@ -3,7 +3,10 @@ package ui
 import (
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
+	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -13,6 +16,8 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/history"
+	"github.com/sirion/fanmi/app/status"
 )
 
 /// Helper Functions
@@ -34,12 +39,35 @@ type FyneUI struct {
 	done   chan bool
 	config *configuration.Configuration
 
-	app   fyne.App
-	win   fyne.Window
-	temp  *canvas.Text
-	speed *canvas.Text
+	app fyne.App
+	win fyne.Window
+
+	// Panels of all devices by name, added by the first update of a device
+	devices      map[string]*devicePanel
+	devicesMutex sync.Mutex
+	deviceBox    *fyne.Container
+
+	// Recent status of all devices
+	history *history.Buffer
+	chart   *HistoryChart
+
+	active *widget.Check
+	// System tray icon, nil if not supported
+	tray *tray
+	// Main window is hidden in the system tray
+	hidden bool
+
+	// Curve selection in the settings window, nil if it is not open
+	curveSelect *widget.Select
+	// Curve editor window, nil if it is not open
+	editor *curveEditor
+	// Pending save of changedLater
+	saveTimer *time.Timer
 }
 
+// Delay of saves after values that change in quick succession
+const saveDelay = time.Second
+
 func (ui *FyneUI) Init(config *configuration.Configuration) chan bool {
 	ui.config = config
 
@@ -49,58 +77,55 @@ func (ui *FyneUI) Init(config *configuration.Configuration) chan bool {
 
 	ui.win = ui.app.NewWindow("FanMi")
 	ui.win.SetMaster()
-	ui.win.SetFixedSize(true)
 	ui.win.SetOnClosed(ui.win.Close)
-	ui.win.Resize(fyne.NewSize(300, 100))
-
-	ui.temp = NewBigText("0")
-	ui.temp.Alignment = fyne.TextAlignTrailing
+	ui.win.SetCloseIntercept(func() {
+		if ui.config.MinimizesToTray() && ui.tray != nil {
+			ui.hideWindow()
+		} else {
+			ui.win.Close()
+		}
+	})
+	ui.win.Resize(fyne.NewSize(420, 320))
 
-	ui.speed = NewBigText("0")
-	ui.speed.Alignment = fyne.TextAlignTrailing
+	ui.devices = make(map[string]*devicePanel)
+	ui.deviceBox = container.NewVBox()
 
+	// Switches all devices, each panel has its own toggle
 	chkActive := widget.NewCheck("active", func(b bool) {
-		config.Active = b
-		if b {
-			ui.temp.Color = theme.ForegroundColor()
-			ui.speed.Color = theme.ForegroundColor()
-		} else {
-			ui.temp.Color = theme.DisabledColor()
-			ui.speed.Color = theme.DisabledColor()
+		config.SetActive(b)
+		for _, p := range ui.panels() {
+			p.updateColors()
+		}
+		if ui.tray != nil {
+			ui.tray.update()
 		}
-		ui.temp.Refresh()
-		ui.speed.Refresh()
 	})
 	chkActive.SetChecked(ui.config.Active)
+	ui.active = chkActive
 
-	content := container.NewVBox(
-		container.NewHBox(
-			container.NewVBox(
-				NewBigText("Temperature:"),
-				layout.NewSpacer(),
-				NewBigText("Fan Speed:"),
-			),
-			layout.NewSpacer(),
-			container.NewVBox(
-				ui.temp,
-				layout.NewSpacer(),
-				ui.speed,
-			),
-			container.NewVBox(
-				NewBigText("°C"),
-				layout.NewSpacer(),
-				NewBigText("%"),
-			),
-		),
+	ui.chart = NewHistoryChart(ui.history)
+	historyRange := widget.NewSelect(HistoryRangeNames, func(name string) {
+		ui.chart.Range = HistoryRanges[name]
+		ui.chart.Refresh()
+	})
+	historyRange.Selected = HistoryRangeNames[0]
+
+	content := container.NewBorder(
+		ui.deviceBox,
 		container.NewHBox(
 			chkActive,
 			layout.NewSpacer(),
+			historyRange,
 			widget.NewButtonWithIcon("", iconSettings, ui.showSettingsWindow),
 		),
+		nil, nil,
+		ui.chart,
 	)
 
 	ui.win.SetContent(content)
 
+	ui.tray = newTray(ui)
+
 	ui.win.Show()
 
 	ui.done = make(chan bool)
@@ -108,26 +133,15 @@ func (ui *FyneUI) Init(config *configuration.Configuration) chan bool {
 }
 
 func (ui *FyneUI) showSettingsWindow() {
-	var curveText *canvas.Text
-	var curve *widget.Select
-
 	content := container.NewVBox()
 	form := container.New(layout.NewFormLayout())
 	content.Add(form)
 
 	// Switch Power profile
 	modeLabel := canvas.NewText("Power:", theme.ForegroundColor())
-	mode := widget.NewSelect([]string{
-		"auto",
-		"low",
-		"high",
-		// "manual",
-		// "profile_standard",
-		// "profile_min_sclk",
-		// "profile_min_mclk",
-		// "profile_peak",
-	}, func(mode string) {
+	mode := widget.NewSelect(configuration.PowerModes, func(mode string) {
 		ui.config.SetPowerMode(mode)
+		ui.changed()
 	})
 	mode.Selected = ui.config.PowerMode
 	form.Add(modeLabel)
@@ -135,42 +149,143 @@ func (ui *FyneUI) showSettingsWindow() {
 	AddSpacer(form)
 
 	// Set Change Interval
-	AddIntegerField(form, &ui.config.CheckIntervalMs, "Interval (ms):")
+	ui.config.RLock()
+	interval, minChange := ui.config.CheckIntervalMs, ui.config.MinChange
+	stepUp, stepDown := ui.config.MaxStepUp, ui.config.MaxStepDown
+	ui.config.RUnlock()
+	AddIntegerField(form, interval, "Interval (ms):", ui.config.SetCheckIntervalMs, ui.changedLater)
 
 	// Set Minimal Temperature Change
-	AddDecimalField(form, &ui.config.MinChange, "Min Change (°):")
+	AddDecimalField(form, minChange, "Min Change (°):", ui.config.SetMinChange, ui.changedLater)
 	AddSpacer(form)
 
 	// Set Minimal Up/Down Steps
-	AddDecimalField(form, &ui.config.MaxStepUp, "Max Step Up (%):")
-	AddDecimalField(form, &ui.config.MaxStepDown, "Max Step Down (%):")
+	AddDecimalField(form, stepUp, "Max Step Up (%):", func(up float32) {
+		ui.config.RLock()
+		down := ui.config.MaxStepDown
+		ui.config.RUnlock()
+		ui.config.SetMaxSteps(up, down)
+	}, ui.changedLater)
+	AddDecimalField(form, stepDown, "Max Step Down (%):", func(down float32) {
+		ui.config.RLock()
+		up := ui.config.MaxStepUp
+		ui.config.RUnlock()
+		ui.config.SetMaxSteps(up, down)
+	}, ui.changedLater)
 	AddSpacer(form)
 
 	// Switch Curve
-	curve = widget.NewSelect(ui.config.CurveNames, func(string) {})
-	curve.OnChanged = func(name string) {
+	curve := widget.NewSelect(nil, func(name string) {
 		ui.config.SetCurve(name)
-		curve.Selected = name
-	}
-	curve.Selected = ui.config.CurrentCurve
-	curveText = canvas.NewText("Curve:", theme.ForegroundColor())
-
-	form.Add(curveText)
-	form.Add(curve) // TODO: Move to settings, remove from ui
+		ui.curvesChanged()
+	})
 
-	if len(ui.config.Curves) <= 1 {
-		curve.Disable()
-		curveText.Color = theme.DisabledColor()
+	form.Add(canvas.NewText("Curve:", theme.ForegroundColor()))
+	form.Add(container.NewBorder(nil, nil, nil,
+		widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), ui.showCurveEditor),
+		curve,
+	))
+	ui.curveSelect = curve
+	ui.updateCurveSelect()
+
+	// Save settings to the configuration file
+	autoSave := widget.NewCheck("save automatically", func(b bool) {
+		ui.config.SetAutoSave(b)
+		ui.changed()
+	})
+	autoSave.Checked = ui.config.AutoSave
+	minimizeToTray := widget.NewCheck("minimize to tray", func(b bool) {
+		ui.config.SetMinimizeToTray(b)
+		ui.changed()
+	})
+	minimizeToTray.Checked = ui.config.MinimizesToTray()
+	if ui.tray == nil {
+		minimizeToTray.Disable()
 	}
+	content.Add(minimizeToTray)
+	content.Add(container.NewHBox(
+		autoSave,
+		layout.NewSpacer(),
+		widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), ui.save),
+	))
 
 	win := ui.app.NewWindow("Settings")
 	win.SetContent(content)
+	win.SetOnClosed(func() {
+		ui.curveSelect = nil
+	})
 	// win.Resize(fyne.NewSize(300, 100))
 	// win.SetFixedSize(true)
 
 	win.Show()
 }
 
+// curvesChanged updates the windows showing curves after curves were edited or selected
+func (ui *FyneUI) curvesChanged() {
+	if ui.curveSelect != nil {
+		ui.updateCurveSelect()
+	}
+	for _, p := range ui.panels() {
+		p.updateCurve()
+	}
+	if ui.editor != nil {
+		ui.editor.update()
+	}
+	if ui.tray != nil {
+		ui.tray.update()
+	}
+	ui.changed()
+}
+
+func (ui *FyneUI) hideWindow() {
+	ui.hidden = true
+	ui.win.Hide()
+	ui.tray.update()
+}
+
+func (ui *FyneUI) showWindow() {
+	ui.hidden = false
+	ui.win.Show()
+	ui.win.RequestFocus()
+	ui.tray.update()
+}
+
+func (ui *FyneUI) updateCurveSelect() {
+	ui.config.RLock()
+	ui.curveSelect.Options = slices.Clone(ui.config.CurveNames)
+	ui.curveSelect.Selected = ui.config.CurrentCurve
+	ui.config.RUnlock()
+
+	if len(ui.curveSelect.Options) <= 1 {
+		ui.curveSelect.Disable()
+	} else {
+		ui.curveSelect.Enable()
+	}
+	ui.curveSelect.Refresh()
+}
+
+// changed saves the settings if auto-save is enabled
+func (ui *FyneUI) changed() {
+	if ui.config.AutoSave {
+		ui.save()
+	}
+}
+
+// changedLater saves the settings like changed once no further change followed for saveDelay
+func (ui *FyneUI) changedLater() {
+	if ui.saveTimer != nil {
+		ui.saveTimer.Stop()
+	}
+	ui.saveTimer = time.AfterFunc(saveDelay, ui.changed)
+}
+
+func (ui *FyneUI) save() {
+	err := ui.config.Save()
+	if err != nil {
+		ui.Message(err.Error() + "\n")
+	}
+}
+
 func (ui *FyneUI) Run() {
 	ui.app.Run()
 	ui.done <- true
@@ -187,17 +302,48 @@ func (*FyneUI) Fatal(exitCode int, message string) {
 	fmt.Fprint(os.Stderr, message)
 	os.Exit(exitCode)
 }
-func (ui *FyneUI) Temperature(temp float32) {
-	ui.temp.Text = fmt.Sprintf("%2.0f", temp)
-	ui.temp.Refresh()
+func (ui *FyneUI) Temperature(device string, temp float32) {
+	ui.device(device).setTemperature(temp)
+	if ui.tray != nil {
+		ui.tray.temperature(ui.panels())
+	}
+}
+
+func (ui *FyneUI) Speed(device string, speed float32) {
+	p := ui.device(device)
+	p.setSpeed(speed)
+	if ui.editor != nil {
+		if curveName, _ := ui.config.DeviceCurve(device); curveName == ui.editor.name {
+			ui.editor.operatingPoint(p.lastTemp, speed)
+		}
+	}
+	ui.chart.Refresh()
+	if ui.tray != nil {
+		ui.tray.status(ui.panels())
+	}
+}
+
+func (ui *FyneUI) PowerMode(device string, mode string) {
+	// Only shown in settings and the tray menu
+	p := ui.device(device)
+	if mode != p.powerMode {
+		p.powerMode = mode
+		if ui.tray != nil {
+			ui.tray.update()
+		}
+	}
 }
 
-func (ui *FyneUI) Speed(speed float32) {
-	ui.speed.Text = fmt.Sprintf("%2.1f", speed*100)
-	ui.speed.Refresh()
+// Update implements status.Listener to show power draw and power profile mode
+func (ui *FyneUI) Update(s status.Status) {
+	p := ui.device(s.Device)
+	p.setPower(s)
+	p.setPowerProfileMode(s)
 }
 
-func (ui *FyneUI) PowerMode(mode string) {} // Ignored, only shown in settings
+func (ui *FyneUI) History(buffer *history.Buffer) {
+	ui.history = buffer
+}
 
 func (*FyneUI) Message(message string) {
 	fmt.Print(message)
@@ -205,47 +351,55 @@ func (*FyneUI) Message(message string) {
 
 /// Helper
 
-func AddIntegerField(form *fyne.Container, configValue *uint32, label string) {
+// AddIntegerField adds an input for a number, set is called with every valid value that is submitted
+func AddIntegerField(form *fyne.Container, value uint32, label string, set func(uint32), changed func()) {
 	input := widget.NewEntry()
-	input.Text = fmt.Sprintf("%03d", *configValue)
-	input.OnChanged = func(value string) {
+	input.Text = fmt.Sprintf("%03d", value)
+	input.OnChanged = func(string) {
 		input.TextStyle.Bold = true
 		input.TextStyle.Italic = true
 		input.Refresh()
 	}
-	input.OnSubmitted = func(value string) {
-		temp, err := strconv.ParseUint(value, 10, 32)
+	input.OnSubmitted = func(text string) {
+		temp, err := strconv.ParseUint(text, 10, 32)
 		if err != nil {
-			input.Text = fmt.Sprintf("%03d", *configValue)
+			input.Text = fmt.Sprintf("%03d", value)
+		} else {
+			value = uint32(temp)
+			set(value)
 		}
-		*configValue = uint32(temp)
 
 		input.TextStyle.Bold = false
 		input.TextStyle.Italic = false
 		input.Refresh()
+		changed()
 	}
 	form.Add(canvas.NewText(label, theme.ForegroundColor()))
 	form.Add(input)
 }
 
-func AddDecimalField(form *fyne.Container, configValue *float32, label string) {
+// AddDecimalField adds an input for a decimal number, set is called with every valid value that is submitted
+func AddDecimalField(form *fyne.Container, value float32, label string, set func(float32), changed func()) {
 	input := widget.NewEntry()
-	input.Text = fmt.Sprintf("%2.1f", *configValue)
-	input.OnChanged = func(value string) {
+	input.Text = fmt.Sprintf("%2.1f", value)
+	input.OnChanged = func(string) {
 		input.TextStyle.Bold = true
 		input.TextStyle.Italic = true
 		input.Refresh()
 	}
-	input.OnSubmitted = func(value string) {
-		temp, err := strconv.ParseFloat(value, 32)
+	input.OnSubmitted = func(text string) {
+		temp, err := strconv.ParseFloat(text, 32)
 		if err != nil {
-			input.Text = fmt.Sprintf("%2.1f", *configValue)
+			input.Text = fmt.Sprintf("%2.1f", value)
+		} else {
+			value = float32(temp)
+			set(value)
 		}
-		*configValue = float32(temp)
 
 		input.TextStyle.Bold = false
 		input.TextStyle.Italic = false
 		input.Refresh()
+		changed()
 	}
 	form.Add(canvas.NewText(label, theme.ForegroundColor()))
 	form.Add(input)
@@ -0,0 +1,186 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/sirion/fanmi/app/history"
+)
+
+// Time ranges the history chart can show
+var HistoryRanges = map[string]time.Duration{
+	"5 min":  5 * time.Minute,
+	"15 min": 15 * time.Minute,
+	"60 min": 60 * time.Minute,
+}
+
+// Names of HistoryRanges in display order
+var HistoryRangeNames = []string{"5 min", "15 min", "60 min"}
+
+// Colors of the temperature channels, the fan speed is drawn in the primary color
+var historyColors = []color.Color{
+	color.NRGBA{R: 0xf4, G: 0x43, B: 0x36, A: 0xff},
+	color.NRGBA{R: 0xff, G: 0x98, B: 0x00, A: 0xff},
+	color.NRGBA{R: 0x9c, G: 0x27, B: 0xb0, A: 0xff},
+	color.NRGBA{R: 0x4c, G: 0xaf, B: 0x50, A: 0xff},
+}
+
+// HistoryChart draws the temperature channels and fan speed of all devices over time, power mode and curve changes
+// are marked with vertical lines. Temperatures use the left axis (°C), speeds the right axis (%).
+type HistoryChart struct {
+	widget.BaseWidget
+
+	// Time range shown up to now
+	Range time.Duration
+
+	buffer *history.Buffer
+}
+
+func NewHistoryChart(buffer *history.Buffer) *HistoryChart {
+	chart := &HistoryChart{Range: 5 * time.Minute, buffer: buffer}
+	chart.ExtendBaseWidget(chart)
+	return chart
+}
+
+func (c *HistoryChart) MinSize() fyne.Size {
+	return fyne.NewSize(300, 160)
+}
+
+func (c *HistoryChart) CreateRenderer() fyne.WidgetRenderer {
+	r := &historyChartRenderer{chart: c}
+	r.Refresh()
+	return r
+}
+
+type historyChartRenderer struct {
+	chart   *HistoryChart
+	objects []fyne.CanvasObject
+}
+
+func (r *historyChartRenderer) Layout(fyne.Size) {
+	r.Refresh()
+}
+
+func (r *historyChartRenderer) MinSize() fyne.Size {
+	return r.chart.MinSize()
+}
+
+// Refresh creates all objects again from the current history
+func (r *historyChartRenderer) Refresh() {
+	c := r.chart
+	r.objects = make([]fyne.CanvasObject, 0)
+
+	size := c.Size()
+	left, right, top, bottom := float32(30), size.Width-30, float32(chartPadding), size.Height-chartAxisHeight
+	if right <= left || bottom <= top || c.buffer == nil {
+		return
+	}
+
+	now := time.Now()
+	since := now.Add(-c.Range)
+	x := func(t time.Time) float32 {
+		return left + float32(t.Sub(since))/float32(c.Range)*(right-left)
+	}
+	// Temperatures from 0 to 100 °C and speeds from 0 to 100 % share the grid
+	y := func(fraction float32) float32 {
+		return bottom - max(0, min(1, fraction))*(bottom-top)
+	}
+
+	foreground := theme.ForegroundColor()
+	for step := 0; step <= 4; step++ {
+		fraction := float32(step) / 4
+		r.line(fyne.NewPos(left, y(fraction)), fyne.NewPos(right, y(fraction)), theme.DisabledColor(), 1)
+		r.label(fmt.Sprintf("%.0f°", fraction*100), fyne.NewPos(0, y(fraction)-8), foreground)
+		r.label(fmt.Sprintf("%.0f%%", fraction*100), fyne.NewPos(right+2, y(fraction)-8), theme.PrimaryColor())
+	}
+	r.label(fmt.Sprintf("-%.0f min", c.Range.Minutes()), fyne.NewPos(left, bottom+2), foreground)
+	r.label("now", fyne.NewPos(right-20, bottom+2), foreground)
+
+	for _, event := range c.buffer.Events(since) {
+		ex := x(event.Time)
+		r.line(fyne.NewPos(ex, top), fyne.NewPos(ex, bottom), foreground, 1)
+		r.label(event.Value, fyne.NewPos(ex+2, top), foreground)
+	}
+
+	// At most one point per 2 pixels
+	bucket := c.Range / time.Duration(max(1, (right-left)/2))
+	legend := float32(left + 4)
+	channelColors := make(map[string]color.Color)
+	for _, device := range c.buffer.Devices() {
+		samples := c.buffer.Samples(device, since)
+		samples = downsample(samples, bucket)
+
+		for i := 1; i < len(samples); i++ {
+			p1, p2 := samples[i-1], samples[i]
+			for j, temp := range p2.Temperatures {
+				if j >= len(p1.Temperatures) || p1.Temperatures[j].Channel != temp.Channel {
+					continue
+				}
+				name := device + " " + temp.Channel
+				if _, ok := channelColors[name]; !ok {
+					channelColors[name] = historyColors[len(channelColors)%len(historyColors)]
+					r.label(name, fyne.NewPos(legend, top), channelColors[name])
+					legend += float32(len(name))*6 + 10
+				}
+				r.line(
+					fyne.NewPos(x(p1.Time), y(p1.Temperatures[j].Value/100)),
+					fyne.NewPos(x(p2.Time), y(temp.Value/100)),
+					channelColors[name], 1.5,
+				)
+			}
+			r.line(
+				fyne.NewPos(x(p1.Time), y(p1.Speed)),
+				fyne.NewPos(x(p2.Time), y(p2.Speed)),
+				theme.PrimaryColor(), 2,
+			)
+		}
+	}
+
+	canvas.Refresh(c)
+}
+
+// downsample keeps the last sample of every time bucket
+func downsample(samples []history.Sample, bucket time.Duration) []history.Sample {
+	if bucket <= 0 || len(samples) == 0 {
+		return samples
+	}
+	result := make([]history.Sample, 0, len(samples))
+	current := int64(math.MinInt64)
+	for _, sample := range samples {
+		b := sample.Time.UnixNano() / int64(bucket)
+		if b == current {
+			result[len(result)-1] = sample
+			continue
+		}
+		current = b
+		result = append(result, sample)
+	}
+	return result
+}
+
+func (r *historyChartRenderer) line(from, to fyne.Position, lineColor color.Color, width float32) {
+	line := canvas.NewLine(lineColor)
+	line.StrokeWidth = width
+	line.Position1 = from
+	line.Position2 = to
+	r.objects = append(r.objects, line)
+}
+
+func (r *historyChartRenderer) label(text string, position fyne.Position, textColor color.Color) {
+	label := canvas.NewText(text, textColor)
+	label.TextSize = theme.CaptionTextSize()
+	label.Move(position)
+	r.objects = append(r.objects, label)
+}
+
+func (r *historyChartRenderer) Objects() []fyne.CanvasObject {
+	return r.objects
+}
+
+func (r *historyChartRenderer) Destroy() {}
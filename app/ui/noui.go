@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/history"
 )
 
 type NoUI struct {
@@ -30,7 +31,8 @@ func (*NoUI) Fatal(exitCode int, message string) {
 	fmt.Fprint(os.Stderr, message)
 	os.Exit(exitCode)
 }
-func (*NoUI) Temperature(float32)   {}
-func (*NoUI) Speed(float32)         {}
-func (*NoUI) Message(string)        {}
-func (*NoUI) PowerMode(mode string) {}
+func (*NoUI) Temperature(string, float32) {}
+func (*NoUI) Speed(string, float32)       {}
+func (*NoUI) Message(string)              {}
+func (*NoUI) PowerMode(string, string)    {}
+func (*NoUI) History(*history.Buffer)     {}
@@ -0,0 +1,158 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/systray"
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/units"
+)
+
+// Temperatures in °C from which the tray icon is shown as warm and hot
+const (
+	trayWarmTemp = 60
+	trayHotTemp  = 80
+)
+
+// Tray icons by temperature band
+var trayIcons = []fyne.Resource{
+	trayIcon("trayCool.png", color.NRGBA{R: 0x4c, G: 0xaf, B: 0x50, A: 0xff}),
+	trayIcon("trayWarm.png", color.NRGBA{R: 0xff, G: 0x98, B: 0x00, A: 0xff}),
+	trayIcon("trayHot.png", color.NRGBA{R: 0xf4, G: 0x43, B: 0x36, A: 0xff}),
+}
+
+// tray is the system tray icon with its menu
+type tray struct {
+	ui   *FyneUI
+	desk desktop.App
+	// Index in trayIcons of the shown icon, -1 before the first temperature
+	band int
+}
+
+// newTray shows the tray icon, nil if the driver has no system tray
+func newTray(ui *FyneUI) *tray {
+	desk, ok := ui.app.(desktop.App)
+	if !ok {
+		return nil
+	}
+	t := &tray{ui: ui, desk: desk, band: -1}
+	t.update()
+	return t
+}
+
+// update creates the menu again from the configuration
+func (t *tray) update() {
+	ui := t.ui
+
+	active := fyne.NewMenuItem("Active", func() {
+		ui.active.SetChecked(!ui.config.Active)
+	})
+	active.Checked = ui.config.Active
+
+	ui.config.RLock()
+	curves := make([]*fyne.MenuItem, 0, len(ui.config.CurveNames))
+	for _, name := range ui.config.CurveNames {
+		name := name
+		item := fyne.NewMenuItem(name, func() {
+			ui.config.SetCurve(name)
+			ui.curvesChanged()
+		})
+		item.Checked = name == ui.config.CurrentCurve
+		curves = append(curves, item)
+	}
+	ui.config.RUnlock()
+	curve := fyne.NewMenuItem("Curve", nil)
+	curve.ChildMenu = fyne.NewMenu("", curves...)
+
+	modes := make([]*fyne.MenuItem, 0, len(configuration.PowerModes))
+	for _, mode := range configuration.PowerModes {
+		mode := mode
+		item := fyne.NewMenuItem(mode, func() {
+			ui.config.SetPowerMode(mode)
+			ui.changed()
+			t.update()
+		})
+		item.Checked = mode == ui.config.PowerMode
+		modes = append(modes, item)
+	}
+	powerMode := fyne.NewMenuItem("Power Mode", nil)
+	powerMode.ChildMenu = fyne.NewMenu("", modes...)
+
+	window := fyne.NewMenuItem("Hide Window", func() {
+		if ui.hidden {
+			ui.showWindow()
+		} else {
+			ui.hideWindow()
+		}
+	})
+	if ui.hidden {
+		window.Label = "Show Window"
+	}
+
+	quit := fyne.NewMenuItem("Quit", ui.app.Quit)
+	quit.IsQuit = true
+
+	t.desk.SetSystemTrayMenu(fyne.NewMenu("FanMi",
+		active,
+		curve,
+		powerMode,
+		fyne.NewMenuItemSeparator(),
+		window,
+		fyne.NewMenuItemSeparator(),
+		quit,
+	))
+}
+
+// temperature changes the icon color if the hottest device is in a different band
+func (t *tray) temperature(panels []*devicePanel) {
+	var temp float32
+	for _, p := range panels {
+		temp = max(temp, p.lastTemp)
+	}
+	band := 0
+	if temp >= trayHotTemp {
+		band = 2
+	} else if temp >= trayWarmTemp {
+		band = 1
+	}
+	if band != t.band {
+		t.band = band
+		t.desk.SetSystemTrayIcon(trayIcons[band])
+	}
+}
+
+// status shows temperature and speed of all devices as tooltip
+func (t *tray) status(panels []*devicePanel) {
+	lines := make([]string, 0, len(panels))
+	for _, p := range panels {
+		lines = append(lines, fmt.Sprintf("%s: %.0f °C, %.1f %%", p.name, p.lastTemp, units.Percent(p.lastSpeed)))
+	}
+	systray.SetTooltip("FanMi\n" + strings.Join(lines, "\n"))
+}
+
+// trayIcon creates a round icon in the color
+func trayIcon(name string, fill color.Color) fyne.Resource {
+	const size = 32
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float32(x)-size/2+0.5, float32(y)-size/2+0.5
+			if dx*dx+dy*dy <= (size/2-1)*(size/2-1) {
+				img.Set(x, y, fill)
+			}
+		}
+	}
+	var buffer bytes.Buffer
+	err := png.Encode(&buffer, img)
+	if err != nil {
+		panic(err)
+	}
+	return fyne.NewStaticResource(name, buffer.Bytes())
+}
@@ -0,0 +1,716 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/debug"
+	"github.com/sirion/fanmi/app/history"
+	"github.com/sirion/fanmi/app/keys"
+	"github.com/sirion/fanmi/app/status"
+	"github.com/sirion/fanmi/app/units"
+	"golang.org/x/term"
+)
+
+// Panes of the TUI, Tab moves the focus to the next one
+const (
+	tuiPaneDevices = iota
+	tuiPaneCurve
+	tuiPaneSettings
+	tuiPanes
+)
+
+var tuiPaneNames = []string{"Devices", "Curve", "Settings"}
+
+var tuiHelp = []string{
+	"↑/↓ select  space on/off  c curve  p profile  [/] power limit  m power profile",
+	"←/→ select point  ↑/↓ speed  [/] temperature  i insert  x delete",
+	"↑/↓ select  ←/→ change",
+}
+
+const (
+	// Time range of the sparklines
+	tuiHistorySpan = 5 * time.Minute
+	// Minimal terminal size
+	tuiMinWidth  = 60
+	tuiMinHeight = 16
+	// Number of messages kept for the message line
+	tuiMessages = 50
+)
+
+// tuiSetting is a field of the settings pane
+type tuiSetting struct {
+	label string
+	value func(c *configuration.Configuration) string
+	// change steps the value forwards (1) or backwards (-1)
+	change func(ui *TUI, step int)
+}
+
+// The same fields as the settings window of the GUI
+var tuiSettings = []tuiSetting{
+	{"Power", func(c *configuration.Configuration) string { return c.PowerMode }, func(ui *TUI, step int) {
+		ui.config.RLock()
+		mode := ui.config.PowerMode
+		ui.config.RUnlock()
+		ui.config.SetPowerMode(cycle(configuration.PowerModes, mode, step))
+	}},
+	{"Interval (ms)", func(c *configuration.Configuration) string { return fmt.Sprintf("%d", c.CheckIntervalMs) }, func(ui *TUI, step int) {
+		ui.config.RLock()
+		interval := int(ui.config.CheckIntervalMs)
+		ui.config.RUnlock()
+		ui.config.SetCheckIntervalMs(uint32(max(configuration.MinCheckIntervalMs, interval+step*100)))
+	}},
+	{"Min Change (°)", func(c *configuration.Configuration) string { return fmt.Sprintf("%2.1f", c.MinChange) }, func(ui *TUI, step int) {
+		ui.config.RLock()
+		minChange := ui.config.MinChange
+		ui.config.RUnlock()
+		ui.config.SetMinChange(max(0, minChange+float32(step)*0.5))
+	}},
+	{"Max Step Up (%)", func(c *configuration.Configuration) string { return fmt.Sprintf("%2.1f", c.MaxStepUp) }, func(ui *TUI, step int) {
+		ui.config.RLock()
+		up, down := ui.config.MaxStepUp, ui.config.MaxStepDown
+		ui.config.RUnlock()
+		ui.config.SetMaxSteps(clamp(up+float32(step)*0.5, 0.5, 100), down)
+	}},
+	{"Max Step Down (%)", func(c *configuration.Configuration) string { return fmt.Sprintf("%2.1f", c.MaxStepDown) }, func(ui *TUI, step int) {
+		ui.config.RLock()
+		up, down := ui.config.MaxStepUp, ui.config.MaxStepDown
+		ui.config.RUnlock()
+		ui.config.SetMaxSteps(up, clamp(down+float32(step)*0.5, 0.5, 100))
+	}},
+	{"Curve", func(c *configuration.Configuration) string { return c.CurrentCurve }, func(ui *TUI, step int) {
+		ui.config.RLock()
+		names := slices.Clone(ui.config.CurveNames)
+		current := ui.config.CurrentCurve
+		ui.config.RUnlock()
+		ui.config.SetCurve(cycle(names, current, step))
+	}},
+	{"Save automatically", func(c *configuration.Configuration) string { return fmt.Sprintf("%t", c.AutoSave) }, func(ui *TUI, step int) {
+		ui.config.RLock()
+		autoSave := ui.config.AutoSave
+		ui.config.RUnlock()
+		ui.config.SetAutoSave(!autoSave)
+	}},
+}
+
+// TUI is a full-screen terminal interface with sparklines of all devices, the curve of the selected device and the
+// settings. The terminal is restored on every exit path.
+type TUI struct {
+	done    chan bool
+	running chan bool
+	config  *configuration.Configuration
+	history *history.Buffer
+
+	// Guards the state below and the output
+	mutex sync.Mutex
+	// Last status of each device by name
+	devices  map[string]status.Status
+	selected string
+	focus    int
+	point    int
+	setting  int
+	closed   bool
+	oldState *term.State
+
+	messagesMutex sync.Mutex
+	messages      []string
+
+	redraw   chan bool
+	exitOnce sync.Once
+}
+
+func (ui *TUI) Init(config *configuration.Configuration) chan bool {
+	ui.config = config
+	ui.done = make(chan bool, 2)
+	ui.running = make(chan bool, 2)
+	ui.redraw = make(chan bool, 1)
+	ui.devices = make(map[string]status.Status)
+	ui.messages = make([]string, 0, tuiMessages)
+
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		ui.Fatal(configuration.ExitCodeReadStdIn, fmt.Sprintf("The TUI needs a terminal: %s\n", err.Error()))
+	}
+	ui.oldState = oldState
+	// Alternate screen without cursor
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+
+	// Log records are shown in the message line
+	debug.SetOutput(ui)
+
+	go ui.readKeys()
+	go ui.render()
+
+	return ui.done
+}
+
+func (ui *TUI) Run() {
+	<-ui.running
+}
+
+func (ui *TUI) Exit() {
+	ui.exitOnce.Do(func() {
+		ui.restore()
+		ui.done <- true
+		ui.running <- true
+	})
+}
+
+func (ui *TUI) Fatal(exitCode int, message string) {
+	ui.restore()
+	fmt.Fprint(os.Stderr, message)
+	os.Exit(exitCode)
+}
+
+// restore switches back to the normal screen and the original terminal mode
+func (ui *TUI) restore() {
+	ui.mutex.Lock()
+	defer ui.mutex.Unlock()
+
+	if ui.closed {
+		return
+	}
+	ui.closed = true
+	debug.SetOutput(os.Stderr)
+	if ui.oldState != nil {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		term.Restore(int(os.Stdin.Fd()), ui.oldState)
+	}
+	// Show the last messages, they were only visible in the message line
+	ui.messagesMutex.Lock()
+	for _, message := range ui.messages[max(0, len(ui.messages)-5):] {
+		fmt.Println(message)
+	}
+	ui.messagesMutex.Unlock()
+}
+
+func (ui *TUI) Temperature(device string, temp float32) {
+	ui.mutex.Lock()
+	defer ui.mutex.Unlock()
+
+	s := ui.devices[device]
+	s.Device = device
+	s.Temperature = temp
+	ui.devices[device] = s
+}
+
+func (ui *TUI) Speed(device string, speed float32) {
+	ui.mutex.Lock()
+	defer ui.mutex.Unlock()
+
+	s := ui.devices[device]
+	s.Device = device
+	s.Speed = speed
+	ui.devices[device] = s
+}
+
+func (*TUI) PowerMode(device string, mode string) {
+	// Shown from the status update
+}
+
+func (ui *TUI) History(buffer *history.Buffer) {
+	ui.history = buffer
+}
+
+// Update shows the status of a device
+func (ui *TUI) Update(s status.Status) {
+	ui.mutex.Lock()
+	ui.devices[s.Device] = s
+	if ui.selected == "" {
+		ui.selected = s.Device
+	}
+	ui.mutex.Unlock()
+
+	ui.requestRedraw()
+}
+
+// Message is shown in the message line, after exiting it is printed
+func (ui *TUI) Message(message string) {
+	ui.mutex.Lock()
+	closed := ui.closed
+	ui.mutex.Unlock()
+	if closed {
+		fmt.Print(message)
+		return
+	}
+
+	ui.messagesMutex.Lock()
+	for _, line := range strings.Split(strings.TrimRight(message, "\n"), "\n") {
+		ui.messages = append(ui.messages, line)
+	}
+	if len(ui.messages) > tuiMessages {
+		ui.messages = slices.Clone(ui.messages[len(ui.messages)-tuiMessages:])
+	}
+	ui.messagesMutex.Unlock()
+
+	ui.requestRedraw()
+}
+
+// Write shows log output in the message line
+func (ui *TUI) Write(p []byte) (int, error) {
+	ui.Message(string(p))
+	return len(p), nil
+}
+
+func (ui *TUI) requestRedraw() {
+	select {
+	case ui.redraw <- true:
+	default:
+	}
+}
+
+// render draws the screen on changes and every second until fanmi stops
+func (ui *TUI) render() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for ui.config.Running {
+		ui.draw()
+		select {
+		case <-ticker.C:
+		case <-ui.redraw:
+		}
+	}
+	ui.running <- true
+}
+
+func (ui *TUI) readKeys() {
+	bt := make([]byte, 8)
+	pending := []byte{}
+	for ui.config.Running {
+		n, err := os.Stdin.Read(bt)
+		if err != nil {
+			ui.Fatal(configuration.ExitCodeReadStdIn, fmt.Sprintf("Error reading from standard input: %s\n", err.Error()))
+		}
+		if n == 0 {
+			ui.Fatal(configuration.ExitCodeReadStdIn, "End of input from console.\n")
+		}
+		pending = ui.input(append(pending, bt[:n]...))
+		ui.requestRedraw()
+	}
+}
+
+// input handles all keys of the input and returns an incomplete escape sequence at its end. Keys typed quickly
+// arrive in one read, escape sequences might be split between reads.
+func (ui *TUI) input(input []byte) []byte {
+	names, rest := keys.Split(input)
+	for _, key := range names {
+		ui.key(key)
+	}
+	return rest
+}
+
+// key handles a key press by name (see keys.Split), global keys first, then the keys of the focused pane
+func (ui *TUI) key(key string) {
+	switch key {
+	case "q", "ctrl-c":
+		ui.config.Running = false
+		ui.config.SetActive(false)
+		return
+	case "tab":
+		ui.mutex.Lock()
+		ui.focus = (ui.focus + 1) % tuiPanes
+		ui.mutex.Unlock()
+		return
+	case "shift-tab":
+		ui.mutex.Lock()
+		ui.focus = (ui.focus + tuiPanes - 1) % tuiPanes
+		ui.mutex.Unlock()
+		return
+	case "s":
+		ui.save()
+		return
+	}
+
+	ui.mutex.Lock()
+	focus := ui.focus
+	device := ui.selected
+	ui.mutex.Unlock()
+
+	switch focus {
+	case tuiPaneDevices:
+		ui.deviceKey(key, device)
+	case tuiPaneCurve:
+		ui.curveKey(key, device)
+	case tuiPaneSettings:
+		ui.settingsKey(key)
+	}
+}
+
+func (ui *TUI) deviceKey(key, device string) {
+	switch key {
+	case "up", "k":
+		ui.moveSelection(-1)
+	case "down", "j":
+		ui.moveSelection(1)
+	case "space":
+		if device != "" {
+			ui.config.SetDeviceActive(device, !ui.config.DeviceActive(device))
+		}
+	case "c":
+		if device != "" {
+			ui.config.RLock()
+			names := slices.Clone(ui.config.CurveNames)
+			ui.config.RUnlock()
+			current, _ := ui.config.DeviceCurve(device)
+			ui.config.SetDeviceCurve(device, cycle(names, current, 1))
+			ui.pointChanged(0)
+		}
+	case "p":
+		names := ui.config.ProfileNames()
+		if device != "" && len(names) > 0 {
+			err := ui.config.ApplyProfile(device, cycle(names, ui.config.DeviceProfile(device), 1))
+			if err != nil {
+				ui.Message(err.Error())
+			}
+			ui.pointChanged(0)
+		}
+	case "m":
+		ui.mutex.Lock()
+		s := ui.devices[device]
+		ui.mutex.Unlock()
+		if len(s.PowerProfileModes) > 0 {
+			ui.config.SetDevicePowerProfileMode(device, cycle(s.PowerProfileModes, s.PowerProfileMode, 1))
+		}
+	case "[", "]":
+		ui.mutex.Lock()
+		s := ui.devices[device]
+		ui.mutex.Unlock()
+		if device == "" || s.PowerCap <= 0 {
+			return
+		}
+		ui.config.RLock()
+		step := ui.config.Console.PowerCapStep
+		ui.config.RUnlock()
+		if key == "[" {
+			step = -step
+		}
+		ui.config.SetDevicePowerCap(device, stepPowerCap(s, ui.config.DevicePowerCap(device), step))
+	}
+}
+
+// curveKey edits the curve used by the device
+func (ui *TUI) curveKey(key, device string) {
+	curveName, curve := ui.config.DeviceCurve(device)
+	curve = append(configuration.Values{}, curve...)
+	if len(curve) == 0 {
+		return
+	}
+	ui.mutex.Lock()
+	i := min(ui.point, len(curve)-1)
+	ui.mutex.Unlock()
+
+	// Points cannot pass their neighbours
+	lowTemp, highTemp := float32(0), curveMaxTemp(curve)
+	if i > 0 {
+		lowTemp = curve[i-1].Temp + 1
+	}
+	if i < len(curve)-1 {
+		highTemp = curve[i+1].Temp - 1
+	}
+
+	switch key {
+	case "left", "h":
+		ui.pointChanged(max(0, i-1))
+		return
+	case "right", "l":
+		ui.pointChanged(min(len(curve)-1, i+1))
+		return
+	case "up", "k", "down", "j":
+		step := float32(1)
+		if key == "down" || key == "j" {
+			step = -1
+		}
+		if curve.IsRPM() {
+			curve[i].RPM = max(0, curve[i].RPM+int(step)*50)
+		} else {
+			curve[i].Speed = units.FromPercent(clamp(units.Percent(curve[i].Speed)+step, 0, 100))
+		}
+	case "[":
+		curve[i].Temp = clamp(curve[i].Temp-1, lowTemp, highTemp)
+	case "]":
+		curve[i].Temp = clamp(curve[i].Temp+1, lowTemp, highTemp)
+	case "i":
+		// Insert between the selected and the next point, after the last point 5° higher
+		entry := curve[i]
+		if i < len(curve)-1 {
+			entry.Temp = float32(int((curve[i].Temp + curve[i+1].Temp) / 2))
+			entry.Speed = (curve[i].Speed + curve[i+1].Speed) / 2
+			entry.RPM = (curve[i].RPM + curve[i+1].RPM) / 2
+		} else {
+			entry.Temp += 5
+		}
+		if entry.Temp <= curve[i].Temp {
+			return
+		}
+		curve = slices.Insert(curve, i+1, entry)
+		i++
+	case "x", "delete":
+		if len(curve) <= 1 {
+			return
+		}
+		curve = slices.Delete(curve, i, i+1)
+		i = min(i, len(curve)-1)
+	default:
+		return
+	}
+
+	err := ui.config.PutCurve(curveName, curve)
+	if err != nil {
+		ui.Message(err.Error())
+		return
+	}
+	ui.pointChanged(i)
+	ui.changed()
+}
+
+func (ui *TUI) settingsKey(key string) {
+	ui.mutex.Lock()
+	i := ui.setting
+	ui.mutex.Unlock()
+
+	switch key {
+	case "up", "k":
+		i = max(0, i-1)
+	case "down", "j":
+		i = min(len(tuiSettings)-1, i+1)
+	case "left", "h":
+		tuiSettings[i].change(ui, -1)
+		ui.changed()
+	case "right", "l", "space", "enter":
+		tuiSettings[i].change(ui, 1)
+		ui.changed()
+	}
+
+	ui.mutex.Lock()
+	ui.setting = i
+	ui.mutex.Unlock()
+}
+
+func (ui *TUI) pointChanged(i int) {
+	ui.mutex.Lock()
+	ui.point = i
+	ui.mutex.Unlock()
+}
+
+// moveSelection selects the previous (-1) or next (1) device
+func (ui *TUI) moveSelection(step int) {
+	ui.mutex.Lock()
+	defer ui.mutex.Unlock()
+
+	names := ui.sortedDevices()
+	if len(names) == 0 {
+		return
+	}
+	i := slices.Index(names, ui.selected) + step
+	ui.selected = names[(i+len(names))%len(names)]
+	ui.point = 0
+}
+
+// sortedDevices returns the names of all devices, the mutex must be held
+func (ui *TUI) sortedDevices() []string {
+	names := make([]string, 0, len(ui.devices))
+	for name := range ui.devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// changed saves the settings if auto-save is enabled
+func (ui *TUI) changed() {
+	if ui.config.AutoSave {
+		ui.save()
+	}
+}
+
+func (ui *TUI) save() {
+	err := ui.config.Save()
+	if err != nil {
+		ui.Message(err.Error())
+	} else {
+		ui.Message("Settings saved")
+	}
+}
+
+// draw renders the whole screen, the configuration is read before the output is locked
+func (ui *TUI) draw() {
+	width, height := terminalSize()
+
+	ui.mutex.Lock()
+	devices := make([]status.Status, 0, len(ui.devices))
+	for _, name := range ui.sortedDevices() {
+		devices = append(devices, ui.devices[name])
+	}
+	selected, focus, point, setting := ui.selected, ui.focus, ui.point, ui.setting
+	ui.mutex.Unlock()
+
+	inactive := make(map[string]bool)
+	for _, s := range devices {
+		inactive[s.Device] = !ui.config.DeviceActive(s.Device)
+	}
+	curveName, curve := ui.config.DeviceCurve(selected)
+	ui.config.RLock()
+	settings := make([]string, len(tuiSettings))
+	for i, field := range tuiSettings {
+		settings[i] = fmt.Sprintf(" %-18s %s", field.label+":", field.value(ui.config))
+	}
+	ui.config.RUnlock()
+
+	lines := make([]string, 0, height)
+	if width < tuiMinWidth || height < tuiMinHeight {
+		lines = append(lines, fmt.Sprintf("Terminal too small, at least %dx%d needed", tuiMinWidth, tuiMinHeight))
+	} else {
+		lines = ui.screen(width, height, devices, inactive, selected, focus, curveName, curve, point, settings, setting)
+	}
+
+	buffer := &bytes.Buffer{}
+	buffer.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			buffer.WriteString("\r\n")
+		}
+		buffer.WriteString(line + "\x1b[K")
+	}
+	buffer.WriteString("\x1b[J")
+
+	ui.mutex.Lock()
+	defer ui.mutex.Unlock()
+	if !ui.closed {
+		os.Stdout.Write(buffer.Bytes())
+	}
+}
+
+// screen returns the lines of all panes
+func (ui *TUI) screen(width, height int, devices []status.Status, inactive map[string]bool, selected string, focus int,
+	curveName string, curve configuration.Values, point int, settings []string, setting int) []string {
+
+	lines := make([]string, 0, height)
+
+	// Title with the focused pane highlighted
+	title := " FanMi "
+	for i, name := range tuiPaneNames {
+		if i == focus {
+			title += fmt.Sprintf(" \x1b[7m %s \x1b[0m", name)
+		} else {
+			title += fmt.Sprintf("  %s ", name)
+		}
+	}
+	// Every pane takes 4 characters more than its name
+	titleWidth := len(" FanMi ") + len(strings.Join(tuiPaneNames, "")) + 4*len(tuiPaneNames)
+	lines = append(lines, title+fit("   Tab: next pane  s: save  q: quit", width-titleWidth))
+
+	// Devices with sparklines of the last minutes
+	now := time.Now()
+	sparkWidth := width - 10
+	for _, s := range devices {
+		cursor := " "
+		if s.Device == selected {
+			cursor = ">"
+		}
+		state := string(s.State)
+		if inactive[s.Device] {
+			state = "inactive"
+		}
+		rpm := ""
+		if s.RPM >= 0 && !s.Time.IsZero() {
+			rpm = fmt.Sprintf("%d rpm", s.RPM)
+		}
+		row := fit(fmt.Sprintf("%s %-8s %3.0f °C  %5.1f %%  %-9s %-9s %-12s %-6s %-14s %s",
+			cursor, s.Device, s.Temperature, units.Percent(s.Speed), rpm, powerLabel(s), s.Curve, s.PowerMode, s.PowerProfileMode, state), width)
+		if s.Device == selected && focus == tuiPaneDevices {
+			row = "\x1b[7m" + row + "\x1b[0m"
+		}
+		lines = append(lines, row)
+
+		var temps, speeds []float32
+		if ui.history != nil {
+			temps, speeds = historyValues(ui.history.Samples(s.Device, now.Add(-tuiHistorySpan)), now, tuiHistorySpan, sparkWidth)
+		}
+		lines = append(lines, fit("   temp  "+sparkline(temps), width))
+		lines = append(lines, fit("   speed "+sparkline(speeds), width))
+	}
+	lines = append(lines, strings.Repeat("─", width))
+
+	// Curve of the selected device next to the settings
+	paneHeight := height - len(lines) - 3
+	settingsWidth := 34
+	curveWidth := width - settingsWidth - 3
+
+	var temp, value float32 = -1, 0
+	for _, s := range devices {
+		if s.Device == selected && !s.Time.IsZero() {
+			temp = s.Temperature
+			value = s.Speed
+			if curve.IsRPM() {
+				value = float32(s.RPM)
+			}
+		}
+	}
+	left := []string{fit(fmt.Sprintf(" Curve: %s", curveName), curveWidth)}
+	if point < len(curve) {
+		entry := curve[point]
+		text := fmt.Sprintf("%.0f °C: %.0f %%", entry.Temp, units.Percent(entry.Speed))
+		if curve.IsRPM() {
+			text = fmt.Sprintf("%.0f °C: %d rpm", entry.Temp, entry.RPM)
+		}
+		left[0] = fit(fmt.Sprintf(" Curve: %s  [%s]", curveName, text), curveWidth)
+	}
+	selectedPoint := -1
+	if focus == tuiPaneCurve {
+		selectedPoint = point
+	}
+	left = append(left, plotCurve(curve, curveWidth, paneHeight-1, temp, value, selectedPoint)...)
+
+	right := []string{" Settings"}
+	for i, line := range settings {
+		line = fit(line, settingsWidth)
+		if i == setting && focus == tuiPaneSettings {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		right = append(right, line)
+	}
+
+	for i := 0; i < paneHeight; i++ {
+		l, r := "", ""
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		lines = append(lines, fit(l, curveWidth)+" │ "+r)
+	}
+
+	// Last message and the keys of the focused pane
+	lines = append(lines, strings.Repeat("─", width))
+	ui.messagesMutex.Lock()
+	message := ""
+	if len(ui.messages) > 0 {
+		message = ui.messages[len(ui.messages)-1]
+	}
+	ui.messagesMutex.Unlock()
+	lines = append(lines, fit(" "+message, width))
+	lines = append(lines, fit(" "+tuiHelp[focus], width))
+
+	return lines
+}
+
+// cycle returns the value step positions after current in values
+func cycle(values []string, current string, step int) string {
+	if len(values) == 0 {
+		return current
+	}
+	i := slices.Index(values, current)
+	if i < 0 {
+		return values[0]
+	}
+	return values[((i+step)%len(values)+len(values))%len(values)]
+}
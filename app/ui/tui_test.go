@@ -0,0 +1,33 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/sirion/fanmi/app/configuration"
+)
+
+func TestTUI_input(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       []string
+		wantSetting int
+		wantFocus   int
+	}{
+		{"Single key", []string{"\x1b[B"}, 1, tuiPaneSettings},
+		{"Several keys in one read", []string{"\x1b[B\x1b[Bj"}, 3, tuiPaneSettings},
+		{"Sequence split between reads", []string{"\x1b[B\x1b[", "B"}, 2, tuiPaneSettings},
+		{"Focus and select", []string{"\x1b[Z\x1b[B"}, 0, tuiPaneCurve},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ui := &TUI{config: &configuration.Configuration{}, focus: tuiPaneSettings}
+			pending := []byte{}
+			for _, input := range tt.input {
+				pending = ui.input(append(pending, input...))
+			}
+			if ui.setting != tt.wantSetting || ui.focus != tt.wantFocus {
+				t.Errorf("input() setting = %d, focus = %d, want %d, %d", ui.setting, ui.focus, tt.wantSetting, tt.wantFocus)
+			}
+		})
+	}
+}
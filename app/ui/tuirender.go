@@ -0,0 +1,177 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/history"
+	"github.com/sirion/fanmi/app/status"
+	"github.com/sirion/fanmi/app/units"
+)
+
+// Characters of the sparklines from low to high
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws values between 0 and 1 with one character each
+func sparkline(values []float32) string {
+	line := make([]rune, len(values))
+	for i, value := range values {
+		value = clamp(value, 0, 1)
+		line[i] = sparks[int(math.Round(float64(value)*float64(len(sparks)-1)))]
+	}
+	return string(line)
+}
+
+// historyValues returns one temperature (fraction of 100 °C) and speed per column for the last span, columns
+// without samples repeat the previous value
+func historyValues(samples []history.Sample, now time.Time, span time.Duration, width int) ([]float32, []float32) {
+	temps := make([]float32, 0, width)
+	speeds := make([]float32, 0, width)
+	if width <= 0 {
+		return temps, speeds
+	}
+
+	since := now.Add(-span)
+	bucket := span / time.Duration(width)
+	i := 0
+	var temp, speed float32
+	for column := 0; column < width; column++ {
+		end := since.Add(bucket * time.Duration(column+1))
+		found := false
+		for ; i < len(samples) && samples[i].Time.Before(end); i++ {
+			found = true
+			speed = samples[i].Speed
+			if len(samples[i].Temperatures) > 0 {
+				temp = samples[i].Temperatures[0].Value / 100
+			}
+		}
+		if !found && len(temps) == 0 {
+			// No data yet
+			continue
+		}
+		temps = append(temps, temp)
+		speeds = append(speeds, speed)
+	}
+	return temps, speeds
+}
+
+// plotCurve draws the curve with temperatures on the x axis and speed or RPM on the y axis. The operating point is
+// marked with "X" if temp is not negative, the selected point with "@" and the other points with "o".
+func plotCurve(curve configuration.Values, width, height int, temp, value float32, selected int) []string {
+	const labelWidth = 6
+	plotWidth, plotHeight := width-labelWidth, height-1
+	if plotWidth < 10 || plotHeight < 3 {
+		return []string{}
+	}
+
+	maxTemp := curveMaxTemp(curve)
+	maxValue := curveMaxValue(curve)
+	column := func(t float32) int {
+		return int(math.Round(float64(clamp(t/maxTemp, 0, 1) * float32(plotWidth-1))))
+	}
+	row := func(v float32) int {
+		return plotHeight - 1 - int(math.Round(float64(clamp(v/maxValue, 0, 1)*float32(plotHeight-1))))
+	}
+
+	grid := make([][]rune, plotHeight)
+	for y := range grid {
+		grid[y] = []rune(strings.Repeat(" ", plotWidth))
+	}
+	if len(curve) > 0 {
+		for x := 0; x < plotWidth; x++ {
+			grid[row(curveValueAt(curve, float32(x)/float32(plotWidth-1)*maxTemp))][x] = '·'
+		}
+	}
+	if temp >= 0 {
+		x := column(temp)
+		for y := range grid {
+			if grid[y][x] == ' ' {
+				grid[y][x] = '¦'
+			}
+		}
+	}
+	for i, entry := range curve {
+		mark := 'o'
+		if i == selected {
+			mark = '@'
+		}
+		grid[row(entry.Value())][column(entry.Temp)] = mark
+	}
+	if temp >= 0 {
+		grid[row(value)][column(temp)] = 'X'
+	}
+
+	lines := make([]string, 0, height)
+	for y, cells := range grid {
+		label := ""
+		switch y {
+		case 0:
+			label = valueLabel(curve, maxValue)
+		case plotHeight / 2:
+			label = valueLabel(curve, maxValue/2)
+		case plotHeight - 1:
+			label = valueLabel(curve, 0)
+		}
+		lines = append(lines, fmt.Sprintf("%*s│", labelWidth-1, label)+string(cells))
+	}
+	axis := []rune(strings.Repeat(" ", plotWidth))
+	free := 0
+	for t := float32(0); t <= maxTemp; t += 20 {
+		text := []rune(fmt.Sprintf("%.0f°", t))
+		x := min(column(t), plotWidth-len(text))
+		if x < free {
+			// Labels must not overlap
+			continue
+		}
+		copy(axis[x:], text)
+		free = x + len(text) + 1
+	}
+	lines = append(lines, strings.Repeat(" ", labelWidth)+string(axis))
+	return lines
+}
+
+func valueLabel(curve configuration.Values, value float32) string {
+	if curve.IsRPM() {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.0f%%", units.Percent(value))
+}
+
+// fit cuts or pads the text to exactly width characters
+func fit(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return text + strings.Repeat(" ", width-len(runes))
+}
+
+// powerLabel shows the power draw and the power limit, e.g. "123/150 W"
+func powerLabel(s status.Status) string {
+	if s.Power < 0 {
+		return "-"
+	}
+	if s.PowerCap > 0 {
+		return fmt.Sprintf("%.0f/%.0f W", s.Power, s.PowerCap)
+	}
+	return fmt.Sprintf("%.0f W", s.Power)
+}
+
+// stepPowerCap changes the power limit of the device by step W within the range of the driver. Without a limit
+// in the configuration the current limit of the driver is changed.
+func stepPowerCap(s status.Status, watts, step float32) float32 {
+	if watts <= 0 {
+		watts = s.PowerCap
+	}
+	watts += step
+	if s.PowerCapMin > 0 {
+		watts = max(watts, s.PowerCapMin)
+	}
+	if s.PowerCapMax > 0 {
+		watts = min(watts, s.PowerCapMax)
+	}
+	return max(watts, 0)
+}
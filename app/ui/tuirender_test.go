@@ -0,0 +1,155 @@
+package ui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/history"
+	"github.com/sirion/fanmi/app/status"
+)
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float32
+		want   string
+	}{
+		{"Empty", []float32{}, ""},
+		{"Range", []float32{0, 0.5, 1}, "▁▅█"},
+		{"Clamped", []float32{-1, 2}, "▁█"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sparkline(tt.values); got != tt.want {
+				t.Errorf("sparkline() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryValues(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	sample := func(seconds int, temp, speed float32) history.Sample {
+		return history.Sample{
+			Time:         now.Add(time.Duration(seconds) * time.Second),
+			Temperatures: []status.Temperature{{Channel: "edge", Value: temp}},
+			Speed:        speed,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		samples    []history.Sample
+		wantTemps  []float32
+		wantSpeeds []float32
+	}{
+		{
+			name:       "One sample per column",
+			samples:    []history.Sample{sample(-35, 40, 0.2), sample(-25, 50, 0.3), sample(-15, 60, 0.4), sample(-5, 70, 0.5)},
+			wantTemps:  []float32{0.4, 0.5, 0.6, 0.7},
+			wantSpeeds: []float32{0.2, 0.3, 0.4, 0.5},
+		},
+		{
+			name:       "Last sample of a column, gaps repeat it",
+			samples:    []history.Sample{sample(-38, 40, 0.2), sample(-32, 50, 0.3), sample(-5, 70, 0.5)},
+			wantTemps:  []float32{0.5, 0.5, 0.5, 0.7},
+			wantSpeeds: []float32{0.3, 0.3, 0.3, 0.5},
+		},
+		{
+			name:       "Starts with the first sample",
+			samples:    []history.Sample{sample(-15, 60, 0.4)},
+			wantTemps:  []float32{0.6, 0.6},
+			wantSpeeds: []float32{0.4, 0.4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			temps, speeds := historyValues(tt.samples, now, 40*time.Second, 4)
+			if !reflect.DeepEqual(temps, tt.wantTemps) {
+				t.Errorf("historyValues() temps = %v, want %v", temps, tt.wantTemps)
+			}
+			if !reflect.DeepEqual(speeds, tt.wantSpeeds) {
+				t.Errorf("historyValues() speeds = %v, want %v", speeds, tt.wantSpeeds)
+			}
+		})
+	}
+}
+
+func TestPlotCurve(t *testing.T) {
+	curve := configuration.Values{{Temp: 0, Speed: 0}, {Temp: 100, Speed: 1}}
+	lines := plotCurve(curve, 26, 6, 50, 0.5, 1)
+
+	want := []string{
+		" 100%│          ¦      ··@",
+		"     │          ¦ ·····   ",
+		"  50%│        ··X·        ",
+		"     │   ·····  ¦         ",
+		"   0%│o··       ¦         ",
+		"      0°  20° 40°    80°  ",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("plotCurve() =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCycle(t *testing.T) {
+	values := []string{"auto", "low", "high"}
+	tests := []struct {
+		current string
+		step    int
+		want    string
+	}{
+		{"auto", 1, "low"},
+		{"high", 1, "auto"},
+		{"auto", -1, "high"},
+		{"", 1, "auto"},
+	}
+	for _, tt := range tests {
+		if got := cycle(values, tt.current, tt.step); got != tt.want {
+			t.Errorf("cycle(%s, %d) = %s, want %s", tt.current, tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestPowerLabel(t *testing.T) {
+	tests := []struct {
+		status status.Status
+		want   string
+	}{
+		{status.Status{Power: -1, PowerCap: 150}, "-"},
+		{status.Status{Power: 123.4}, "123 W"},
+		{status.Status{Power: 123.6, PowerCap: 150}, "124/150 W"},
+	}
+	for _, tt := range tests {
+		if got := powerLabel(tt.status); got != tt.want {
+			t.Errorf("powerLabel(%+v) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestStepPowerCap(t *testing.T) {
+	driver := status.Status{PowerCap: 200, PowerCapMin: 100, PowerCapMax: 250}
+	tests := []struct {
+		name   string
+		status status.Status
+		watts  float32
+		step   float32
+		want   float32
+	}{
+		{"From driver limit", driver, 0, -10, 190},
+		{"From configured limit", driver, 150, 10, 160},
+		{"Minimum", driver, 105, -10, 100},
+		{"Maximum", driver, 245, 10, 250},
+		{"Unknown range", status.Status{PowerCap: 200}, 0, 10, 210},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stepPowerCap(tt.status, tt.watts, tt.step); got != tt.want {
+				t.Errorf("stepPowerCap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
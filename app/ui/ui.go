@@ -1,21 +1,29 @@
 package ui
 
-import "github.com/sirion/fanmi/app/configuration"
+import (
+	"github.com/sirion/fanmi/app/configuration"
+	"github.com/sirion/fanmi/app/history"
+)
 
 type UI interface {
+	// History sets the recent status of all devices, called before Init
+	History(buffer *history.Buffer)
 	Init(config *configuration.Configuration) chan bool
 	Run()
 	Exit()
 	Message(string)
 	Fatal(exitCode int, message string)
-	Temperature(temp float32)
-	Speed(speed float32)
-	PowerMode(mode string)
+	// Temperature, Speed and PowerMode show the values of the device (e.g. "card0")
+	Temperature(device string, temp float32)
+	Speed(device string, speed float32)
+	PowerMode(device string, mode string)
 }
 
 func CreateUI(uiType string) UI {
 	if uiType == "console" {
 		return &ConsoleUI{}
+	} else if uiType == "tui" {
+		return &TUI{}
 	} else if uiType == "none" {
 		return &NoUI{}
 	} else {
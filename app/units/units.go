@@ -0,0 +1,94 @@
+package units
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Fan speeds are handled as fraction (0-1) of the maximum PWM value, these functions convert from and to the
+// units used in sysfs files, the configuration and the user interfaces.
+
+// MaxPWM is the value of pwm1 for full fan speed
+const MaxPWM = 255
+
+// PWM converts a speed to the pwm1 value, speeds outside of 0-1 are clamped
+func PWM(speed float32) int {
+	return int(Clamp(speed) * MaxPWM)
+}
+
+// FromPWM converts a pwm1 value to a speed
+func FromPWM(pwm float32) float32 {
+	return pwm / MaxPWM
+}
+
+// Percent converts a speed to percent
+func Percent(speed float32) float32 {
+	return speed * 100
+}
+
+// FromPercent converts percent to a speed
+func FromPercent(percent float32) float32 {
+	return percent / 100
+}
+
+// RPM converts a speed to revolutions per minute of a fan with the given maximum
+func RPM(speed float32, maxRPM int) int {
+	return int(Clamp(speed) * float32(maxRPM))
+}
+
+// FromRPM converts revolutions per minute of a fan with the given maximum to a speed
+func FromRPM(rpm, maxRPM int) float32 {
+	return float32(rpm) / float32(maxRPM)
+}
+
+// Clamp limits a speed to 0-1
+func Clamp(speed float32) float32 {
+	if speed > 1 {
+		return 1
+	} else if speed < 0 {
+		return 0
+	}
+	return speed
+}
+
+// ParseSpeed reads a speed from JSON, either as fraction (0.45) or as string in percent ("45%") or fraction ("0.45")
+func ParseSpeed(data json.RawMessage) (float32, error) {
+	var value any
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return 0, err
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return float32(v), nil
+	case string:
+		text := strings.TrimSpace(v)
+		percent := strings.HasSuffix(text, "%")
+		f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(text, "%")), 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid speed '%s'", v)
+		}
+		if percent {
+			return FromPercent(float32(f)), nil
+		}
+		return float32(f), nil
+	default:
+		return 0, fmt.Errorf("invalid speed %s", string(data))
+	}
+}
+
+// Watts converts the µW of power1_* files to W
+func Watts(microWatts int64) float32 {
+	return float32(microWatts) / 1e6
+}
+
+// MicroWatts converts W to the µW of power1_* files
+func MicroWatts(watts float32) int64 {
+	return int64(math.Round(float64(watts) * 1e6))
+}
@@ -0,0 +1,54 @@
+package units
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseSpeed(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    float32
+		wantErr bool
+	}{
+		{`0.45`, 0.45, false},
+		{`"45%"`, 0.45, false},
+		{`" 45 % "`, 0.45, false},
+		{`"0.45"`, 0.45, false},
+		{`null`, 0, false},
+		{`"fast"`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			got, err := ParseSpeed(json.RawMessage(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSpeed() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSpeed() = %f, want %f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPWM(t *testing.T) {
+	tests := []struct {
+		speed float32
+		want  int
+	}{
+		{0, 0},
+		{0.5, 127},
+		{1, 255},
+		{1.5, 255},
+		{-0.1, 0},
+	}
+	for _, tt := range tests {
+		if got := PWM(tt.speed); got != tt.want {
+			t.Errorf("PWM(%f) = %d, want %d", tt.speed, got, tt.want)
+		}
+	}
+	if got := FromPWM(51); got != 0.2 {
+		t.Errorf("FromPWM(51) = %f, want 0.2", got)
+	}
+}